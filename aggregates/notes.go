@@ -2,6 +2,7 @@ package aggregates
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
@@ -12,13 +13,8 @@ import (
 	"github.com/rcy/whatever/events"
 )
 
-var location = func() *time.Location {
-	loc, err := time.LoadLocation("America/Creston")
-	if err != nil {
-		panic(err)
-	}
-	return loc
-}()
+// CalendarFunc returns the calendar of the user owner
+type CalendarFunc func(owner string) notesmeta.Calendar
 
 type noteAggregate struct {
 	id          uuid.UUID
@@ -29,10 +25,26 @@ type noteAggregate struct {
 	subcategory string
 	due         *time.Time
 	starred     bool
+
+	// lastDue is the latest due date of an open task, kept when the task
+	// is unscheduled so rescheduling it later counts as a deferral
+	lastDue *time.Time
+
+	recurrence *notesmeta.Recurrence
+
+	// tags added explicitly, #tags in the text are not tracked here
+	tags []string
+
+	// links that were looked up, and the ones with a readable copy saved
+	enriched []string
+	archived []string
+
+	// calendar is used to work out due dates in the owner's time zone
+	calendar CalendarFunc
 }
 
-func NewNoteAggregate(id uuid.UUID) *noteAggregate {
-	return &noteAggregate{id: id}
+func NewNoteAggregate(id uuid.UUID, calendar CalendarFunc) *noteAggregate {
+	return &noteAggregate{id: id, calendar: calendar}
 }
 
 func (a *noteAggregate) HandleCommand(cmd evoke.Command) ([]evoke.Event, error) {
@@ -75,14 +87,7 @@ func (a *noteAggregate) HandleCommand(cmd evoke.Command) ([]evoke.Event, error)
 			},
 		}
 
-		// TODO: better matching here
-		if strings.HasPrefix(text, "http") {
-			eventList = append(eventList, events.NoteEnrichmentRequested{
-				NoteID:      aggregateID,
-				RequestedAt: time.Now(),
-				Text:        text,
-			})
-		}
+		eventList = append(eventList, a.requestEnrichment(text)...)
 
 		return eventList, nil
 	case commands.DeleteNote:
@@ -106,18 +111,12 @@ func (a *noteAggregate) HandleCommand(cmd evoke.Command) ([]evoke.Event, error)
 		}
 
 		eventList := []evoke.Event{events.NoteTextUpdated{
-			NoteID: aggregateID,
-			Text:   c.Text,
+			NoteID:    aggregateID,
+			Text:      c.Text,
+			UpdatedAt: time.Now(),
 		}}
 
-		// TODO: better matching here
-		if strings.HasPrefix(text, "http") {
-			eventList = append(eventList, events.NoteEnrichmentRequested{
-				NoteID:      aggregateID,
-				RequestedAt: time.Now(),
-				Text:        text,
-			})
-		}
+		eventList = append(eventList, a.requestEnrichment(text)...)
 
 		return eventList, nil
 	case commands.SetNoteOwner:
@@ -152,40 +151,193 @@ func (a *noteAggregate) HandleCommand(cmd evoke.Command) ([]evoke.Event, error)
 		}
 
 		if a.subcategory == transition.TargetSlug {
-			return nil, fmt.Errorf("note already set to subcategory: %s", subcategory)
+			return nil, fmt.Errorf("note already set to subcategory: %s", a.subcategory)
+		}
+
+		if transition.TargetSlug == notesmeta.TaskDone && a.category == notesmeta.Task.Slug && a.recurrence != nil {
+			return a.recur(c.Actor), nil
 		}
+
 		eventList := []evoke.Event{events.NoteSubcategoryChanged{
 			NoteID:      aggregateID,
 			Subcategory: transition.TargetSlug,
+			Actor:       c.Actor,
 		}}
 
+		var due *time.Time
 		if transition.DaysUntilDue != nil {
-			now := time.Now().In(location)
-			due := notesmeta.Midnight(now).AddDate(0, 0, transition.DaysUntilDue(now))
-			eventList = append(eventList, events.NoteDueChanged{NoteID: aggregateID, Due: due})
+			cal := a.calendar(a.owner)
+			now := cal.Now()
+			d := notesmeta.Midnight(now).AddDate(0, 0, transition.DaysUntilDue(cal, now))
+			due = &d
+			eventList = append(eventList, events.NoteDueChanged{NoteID: aggregateID, Due: d})
 		} else if a.due != nil {
 			eventList = append(eventList, events.NoteDueCleared{NoteID: aggregateID})
 		}
 
+		if notesmeta.Archivable(a.category, transition.TargetSlug) {
+			eventList = append(eventList, a.requestArchive()...)
+		}
+
+		if a.category == notesmeta.Task.Slug {
+			switch {
+			case transition.TargetSlug == notesmeta.TaskDone:
+				eventList = append(eventList, events.NoteTaskCompleted{NoteID: aggregateID, CompletedAt: time.Now()})
+			case a.subcategory == notesmeta.TaskDone:
+				eventList = append(eventList, events.NoteTaskReopened{NoteID: aggregateID, ReopenedAt: time.Now()})
+			case due != nil || transition.TargetSlug == notesmeta.TaskSomeday:
+				eventList = append(eventList, a.deferred(due)...)
+			}
+		}
+
 		return eventList, nil
+	case commands.SuggestNoteClassification:
+		if c.Category == "" {
+			return nil, fmt.Errorf("category cannot be empty")
+		}
+		return []evoke.Event{events.NoteClassificationSuggested{
+			NoteID:      aggregateID,
+			Category:    c.Category,
+			Subcategory: c.Subcategory,
+			Timeframe:   c.Timeframe,
+			Confidence:  c.Confidence,
+		}}, nil
 	case commands.SetNoteDue:
-		return []evoke.Event{events.NoteDueChanged{
+		// due times are kept to the minute
+		due := c.Due.Truncate(time.Minute)
+
+		var eventList []evoke.Event
+		// a task only shows up as scheduled once it has a due date
+		if a.category == notesmeta.Task.Slug && a.subcategory != notesmeta.TaskScheduled {
+			eventList = append(eventList, events.NoteSubcategoryChanged{
+				NoteID:      aggregateID,
+				Subcategory: notesmeta.TaskScheduled,
+			})
+			if a.subcategory == notesmeta.TaskDone {
+				eventList = append(eventList, events.NoteTaskReopened{NoteID: aggregateID, ReopenedAt: time.Now()})
+			}
+		}
+		eventList = append(eventList, events.NoteDueChanged{
 			NoteID: aggregateID,
-			Due:    c.Due,
-		}}, nil
+			Due:    due,
+		})
+		return append(eventList, a.deferred(&due)...), nil
 	case commands.ClearNoteDue:
-		return []evoke.Event{events.NoteDueCleared{
+		eventList := []evoke.Event{events.NoteDueCleared{
+			NoteID: aggregateID,
+		}}
+		// scheduled tasks without a due date go back to unscheduled
+		if a.category == notesmeta.Task.Slug && a.subcategory == notesmeta.TaskScheduled {
+			eventList = append(eventList, events.NoteSubcategoryChanged{
+				NoteID:      aggregateID,
+				Subcategory: notesmeta.Task.Inbox().Slug,
+			})
+		}
+		return eventList, nil
+	case commands.AddNoteTag:
+		tag, err := notesmeta.NormalizeTag(c.Tag)
+		if err != nil {
+			return nil, err
+		}
+		if slices.Contains(a.tags, tag) {
+			return nil, fmt.Errorf("note already tagged %s", tag)
+		}
+		return []evoke.Event{events.TagNoteAdded{NoteID: aggregateID, Tag: tag}}, nil
+	case commands.RemoveNoteTag:
+		tag, err := notesmeta.NormalizeTag(c.Tag)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(a.tags, tag) {
+			return nil, fmt.Errorf("note not tagged %s", tag)
+		}
+		return []evoke.Event{events.TagNoteRemoved{NoteID: aggregateID, Tag: tag}}, nil
+	case commands.SetNoteRecurrence:
+		if a.category != notesmeta.Task.Slug {
+			return nil, fmt.Errorf("only tasks can recur")
+		}
+		recurrence, err := notesmeta.ParseRecurrence(c.Rule)
+		if err != nil {
+			return nil, err
+		}
+		if a.recurrence != nil && a.recurrence.String() == recurrence.String() {
+			return nil, fmt.Errorf("note already recurs %s", recurrence)
+		}
+		return []evoke.Event{events.NoteRecurrenceSet{
+			NoteID: aggregateID,
+			Rule:   recurrence.String(),
+		}}, nil
+	case commands.ClearNoteRecurrence:
+		if a.recurrence == nil {
+			return nil, fmt.Errorf("note does not recur")
+		}
+		return []evoke.Event{events.NoteRecurrenceCleared{
 			NoteID: aggregateID,
 		}}, nil
 	case commands.CompleteNoteEnrichment:
-		return []evoke.Event{events.NoteEnriched{
+		eventList := []evoke.Event{events.NoteEnriched{
+			NoteID:       aggregateID,
+			URL:          c.URL,
+			Title:        strings.TrimSpace(c.Title),
+			Description:  strings.TrimSpace(c.Description),
+			SiteName:     strings.TrimSpace(c.SiteName),
+			CanonicalURL: c.CanonicalURL,
+			Thumbnail:    c.Thumb,
+			ContentType:  c.ContentType,
+		}}
+		if link := a.eventLink(c.URL); link != "" && notesmeta.Archivable(a.category, a.subcategory) {
+			eventList = append(eventList, events.NoteArchiveRequested{
+				NoteID:      aggregateID,
+				URL:         link,
+				RequestedAt: time.Now(),
+			})
+		}
+		return eventList, nil
+	case commands.CompleteNoteArchive:
+		if c.Hash == "" {
+			return nil, fmt.Errorf("hash cannot be empty")
+		}
+		return []evoke.Event{events.NoteArchived{
+			NoteID:     aggregateID,
+			URL:        c.URL,
+			Hash:       c.Hash,
+			ArchivedAt: c.ArchivedAt,
+		}}, nil
+	case commands.FailNoteArchive:
+		return []evoke.Event{events.NoteArchiveFailed{
 			NoteID: aggregateID,
-			Title:  c.Title,
+			URL:    c.URL,
+			Reason: c.Reason,
 		}}, nil
 	case commands.FailNoteEnrichment:
 		return []evoke.Event{events.NoteEnrichmentFailed{
 			NoteID: aggregateID,
+			URL:    c.URL,
+			Reason: c.Reason,
 		}}, nil
+	case commands.RequestNoteEnrichment:
+		if a.deleted {
+			return nil, fmt.Errorf("note is deleted")
+		}
+		links := notesmeta.Links(a.text)
+		if c.URL != "" {
+			if !slices.Contains(links, c.URL) {
+				return nil, fmt.Errorf("link not in note: %s", c.URL)
+			}
+			links = []string{c.URL}
+		}
+		if len(links) == 0 {
+			return nil, fmt.Errorf("note has no links")
+		}
+		var eventList []evoke.Event
+		for _, link := range links {
+			eventList = append(eventList, events.NoteEnrichmentRequested{
+				NoteID:      aggregateID,
+				RequestedAt: time.Now(),
+				URL:         link,
+			})
+		}
+		return eventList, nil
 	case commands.StarNote:
 		if a.starred {
 			return nil, fmt.Errorf("note already starred")
@@ -201,6 +353,93 @@ func (a *noteAggregate) HandleCommand(cmd evoke.Command) ([]evoke.Event, error)
 	return nil, fmt.Errorf("unhandled")
 }
 
+// recur completes a recurring task and schedules its next occurrence
+// instead of moving it to done
+func (a *noteAggregate) recur(actor string) []evoke.Event {
+	cal := a.calendar(a.owner)
+	now := cal.Now()
+	due := a.recurrence.Next(cal, a.due, now)
+
+	var eventList []evoke.Event
+	if a.subcategory != notesmeta.TaskScheduled {
+		eventList = append(eventList, events.NoteSubcategoryChanged{
+			NoteID:      a.id,
+			Subcategory: notesmeta.TaskScheduled,
+			Actor:       actor,
+		})
+	}
+	return append(eventList,
+		events.NoteTaskCompleted{NoteID: a.id, CompletedAt: now},
+		events.NoteDueChanged{NoteID: a.id, Due: due},
+		events.NoteTaskRecurred{NoteID: a.id, RecurredAt: now, Due: due},
+	)
+}
+
+// deferred returns a NoteTaskDeferred event if scheduling an open task for
+// due pushes it later than it was last due. A nil due puts it off to
+// someday.
+func (a *noteAggregate) deferred(due *time.Time) []evoke.Event {
+	if a.category != notesmeta.Task.Slug || a.subcategory == notesmeta.TaskDone || a.lastDue == nil {
+		return nil
+	}
+	if due != nil && !due.After(*a.lastDue) {
+		return nil
+	}
+	return []evoke.Event{events.NoteTaskDeferred{
+		NoteID:     a.id,
+		DeferredAt: time.Now(),
+		From:       *a.lastDue,
+		To:         due,
+	}}
+}
+
+// requestEnrichment asks for the links in text that are not already in the
+// note to be looked up, so edits that keep the same links fetch nothing
+func (a *noteAggregate) requestEnrichment(text string) []evoke.Event {
+	known := notesmeta.Links(a.text)
+	var eventList []evoke.Event
+	for _, link := range notesmeta.Links(text) {
+		if slices.Contains(known, link) {
+			continue
+		}
+		eventList = append(eventList, events.NoteEnrichmentRequested{
+			NoteID:      a.id,
+			RequestedAt: time.Now(),
+			URL:         link,
+		})
+	}
+	return eventList
+}
+
+// requestArchive asks for a readable copy of the pages at the links in the
+// note that were looked up but not saved yet
+func (a *noteAggregate) requestArchive() []evoke.Event {
+	var eventList []evoke.Event
+	for _, link := range notesmeta.Links(a.text) {
+		if !slices.Contains(a.enriched, link) || slices.Contains(a.archived, link) {
+			continue
+		}
+		eventList = append(eventList, events.NoteArchiveRequested{
+			NoteID:      a.id,
+			URL:         link,
+			RequestedAt: time.Now(),
+		})
+	}
+	return eventList
+}
+
+// eventLink returns link, or for enrichment events recorded before links
+// were enriched one by one, the first link in the note
+func (a *noteAggregate) eventLink(link string) string {
+	if link != "" {
+		return link
+	}
+	if links := notesmeta.Links(a.text); len(links) > 0 {
+		return links[0]
+	}
+	return ""
+}
+
 func (a *noteAggregate) Apply(e evoke.Event) error {
 	switch evt := e.(type) {
 	case events.NoteCreated:
@@ -224,15 +463,44 @@ func (a *noteAggregate) Apply(e evoke.Event) error {
 		a.subcategory = evt.Subcategory
 	case events.NoteDueChanged:
 		a.due = &evt.Due
+		a.lastDue = &evt.Due
 	case events.NoteDueCleared:
 		a.due = nil
+	case events.NoteClassificationSuggested:
 	case events.NoteEnrichmentRequested:
 	case events.NoteEnriched:
+		if link := a.eventLink(evt.URL); link != "" && !slices.Contains(a.enriched, link) {
+			a.enriched = append(a.enriched, link)
+		}
 	case events.NoteEnrichmentFailed:
+	case events.NoteArchiveRequested:
+	case events.NoteArchived:
+		if !slices.Contains(a.archived, evt.URL) {
+			a.archived = append(a.archived, evt.URL)
+		}
+	case events.NoteArchiveFailed:
 	case events.NoteStarred:
 		a.starred = true
 	case events.NoteUnstarred:
 		a.starred = false
+	case events.NoteTaskCompleted:
+		a.lastDue = nil
+	case events.NoteTaskDeferred:
+		a.lastDue = evt.To
+	case events.NoteTaskReopened:
+	case events.NoteTaskRecurred:
+	case events.NoteRecurrenceSet:
+		recurrence, err := notesmeta.ParseRecurrence(evt.Rule)
+		if err != nil {
+			return err
+		}
+		a.recurrence = &recurrence
+	case events.NoteRecurrenceCleared:
+		a.recurrence = nil
+	case events.TagNoteAdded:
+		a.tags = append(a.tags, evt.Tag)
+	case events.TagNoteRemoved:
+		a.tags = slices.DeleteFunc(a.tags, func(t string) bool { return t == evt.Tag })
 	default:
 		return fmt.Errorf("not handled")
 	}
@@ -0,0 +1,158 @@
+package aggregates
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/rcy/evoke"
+	"github.com/rcy/whatever/catalog/notesmeta"
+	"github.com/rcy/whatever/commands"
+	"github.com/rcy/whatever/events"
+)
+
+// ResolveHandleFunc returns the handle of the person that handle of owner
+// is merged into, following merges, or handle itself
+type ResolveHandleFunc func(owner string, handle string) (string, error)
+
+// personAggregate holds what is known about someone @mentioned in notes.
+// People are keyed by commands.PersonID of their owner and handle, and
+// exist as soon as they are mentioned, so there is no create command.
+type personAggregate struct {
+	id          uuid.UUID
+	displayName string
+	notes       string
+	mergedInto  string
+
+	// resolve is used to refuse merges that would go in a circle
+	resolve ResolveHandleFunc
+}
+
+func NewPersonAggregate(id uuid.UUID, resolve ResolveHandleFunc) *personAggregate {
+	return &personAggregate{id: id, resolve: resolve}
+}
+
+func (a *personAggregate) HandleCommand(cmd evoke.Command) ([]evoke.Event, error) {
+	aggregateID := cmd.AggregateID()
+	if aggregateID == uuid.Nil {
+		return nil, fmt.Errorf("no aggregateID: %v", cmd)
+	}
+
+	if a.id != aggregateID {
+		panic("id mismatch")
+	}
+
+	checkHandle := func(owner string, handle string) error {
+		if owner == "" {
+			return fmt.Errorf("owner cannot be empty")
+		}
+		normalized, err := notesmeta.NormalizeHandle(handle)
+		if err != nil {
+			return err
+		}
+		if normalized != handle || commands.PersonID(owner, handle) != aggregateID {
+			return fmt.Errorf("handle does not match person")
+		}
+		return nil
+	}
+	checkNotMerged := func(handle string) error {
+		if a.mergedInto != "" {
+			return fmt.Errorf("@%s is merged into @%s", handle, a.mergedInto)
+		}
+		return nil
+	}
+
+	switch c := cmd.(type) {
+	case commands.SetPersonDisplayName:
+		if err := checkHandle(c.Owner, c.Handle); err != nil {
+			return nil, err
+		}
+		if err := checkNotMerged(c.Handle); err != nil {
+			return nil, err
+		}
+		displayName := strings.TrimSpace(c.DisplayName)
+		if a.displayName == displayName {
+			return nil, fmt.Errorf("display name already set to %q", displayName)
+		}
+		return []evoke.Event{events.PersonDisplayNameSet{
+			PersonID:    aggregateID,
+			Owner:       c.Owner,
+			Handle:      c.Handle,
+			DisplayName: displayName,
+		}}, nil
+	case commands.SetPersonNotes:
+		if err := checkHandle(c.Owner, c.Handle); err != nil {
+			return nil, err
+		}
+		if err := checkNotMerged(c.Handle); err != nil {
+			return nil, err
+		}
+		notes := strings.TrimSpace(c.Notes)
+		if a.notes == notes {
+			return nil, fmt.Errorf("notes unchanged")
+		}
+		return []evoke.Event{events.PersonNotesSet{
+			PersonID: aggregateID,
+			Owner:    c.Owner,
+			Handle:   c.Handle,
+			Notes:    notes,
+		}}, nil
+	case commands.MergePerson:
+		if err := checkHandle(c.Owner, c.Handle); err != nil {
+			return nil, err
+		}
+		into, err := notesmeta.NormalizeHandle(c.Into)
+		if err != nil {
+			return nil, err
+		}
+		if into == c.Handle {
+			return nil, fmt.Errorf("cannot merge @%s into itself", c.Handle)
+		}
+		if a.mergedInto == into {
+			return nil, fmt.Errorf("@%s is already merged into @%s", c.Handle, into)
+		}
+		target, err := a.resolve(c.Owner, into)
+		if err != nil {
+			return nil, err
+		}
+		if target == c.Handle {
+			return nil, fmt.Errorf("cannot merge @%s into @%s, which is merged into @%s", c.Handle, into, c.Handle)
+		}
+		return []evoke.Event{events.PersonMerged{
+			PersonID: aggregateID,
+			Owner:    c.Owner,
+			Handle:   c.Handle,
+			Into:     into,
+		}}, nil
+	case commands.UnmergePerson:
+		if err := checkHandle(c.Owner, c.Handle); err != nil {
+			return nil, err
+		}
+		if a.mergedInto == "" {
+			return nil, fmt.Errorf("@%s is not merged", c.Handle)
+		}
+		return []evoke.Event{events.PersonUnmerged{
+			PersonID: aggregateID,
+			Owner:    c.Owner,
+			Handle:   c.Handle,
+		}}, nil
+	}
+
+	return nil, fmt.Errorf("unhandled")
+}
+
+func (a *personAggregate) Apply(e evoke.Event) error {
+	switch evt := e.(type) {
+	case events.PersonDisplayNameSet:
+		a.displayName = evt.DisplayName
+	case events.PersonNotesSet:
+		a.notes = evt.Notes
+	case events.PersonMerged:
+		a.mergedInto = evt.Into
+	case events.PersonUnmerged:
+		a.mergedInto = ""
+	default:
+		return fmt.Errorf("not handled")
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package aggregates
+
+import (
+	"testing"
+
+	"github.com/rcy/evoke"
+	"github.com/rcy/whatever/commands"
+	"github.com/rcy/whatever/events"
+)
+
+func TestMergePerson(t *testing.T) {
+	// bob is merged into carol
+	merged := map[string]string{"bob": "carol"}
+	resolve := func(owner string, handle string) (string, error) {
+		for merged[handle] != "" {
+			handle = merged[handle]
+		}
+		return handle, nil
+	}
+
+	tests := []struct {
+		name    string
+		handle  string
+		history []evoke.Event
+		into    string
+		wantErr bool
+	}{
+		{name: "merge", handle: "alice", into: "bob"},
+		{name: "into a handle written with @", handle: "alice", into: "@Dave"},
+		{name: "into itself", handle: "alice", into: "alice", wantErr: true},
+		{name: "into someone merged into it", handle: "carol", into: "bob", wantErr: true},
+		{name: "into an invalid handle", handle: "alice", into: "not a handle", wantErr: true},
+		{
+			name:    "again",
+			handle:  "alice",
+			history: []evoke.Event{events.PersonMerged{Owner: "o", Handle: "alice", Into: "bob"}},
+			into:    "bob",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := commands.PersonID("o", tt.handle)
+			a := NewPersonAggregate(id, resolve)
+			for _, e := range tt.history {
+				if err := a.Apply(e); err != nil {
+					t.Fatal(err)
+				}
+			}
+			evts, err := a.HandleCommand(commands.MergePerson{PersonID: id, Owner: "o", Handle: tt.handle, Into: tt.into})
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("got %v, want error", evts)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(evts) != 1 {
+				t.Fatalf("got %v, want one event", evts)
+			}
+			if _, ok := evts[0].(events.PersonMerged); !ok {
+				t.Errorf("got %T, want PersonMerged", evts[0])
+			}
+		})
+	}
+}
+
+func TestMergePersonOfSomeoneElse(t *testing.T) {
+	id := commands.PersonID("o", "alice")
+	a := NewPersonAggregate(id, nil)
+	_, err := a.HandleCommand(commands.MergePerson{PersonID: id, Owner: "other", Handle: "alice", Into: "bob"})
+	if err == nil {
+		t.Error("merged the person of another owner")
+	}
+}
+
+func TestUnmergePerson(t *testing.T) {
+	id := commands.PersonID("o", "alice")
+	a := NewPersonAggregate(id, nil)
+	cmd := commands.UnmergePerson{PersonID: id, Owner: "o", Handle: "alice"}
+
+	_, err := a.HandleCommand(cmd)
+	if err == nil {
+		t.Error("unmerged someone who was not merged")
+	}
+
+	err = a.Apply(events.PersonMerged{PersonID: id, Owner: "o", Handle: "alice", Into: "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = a.HandleCommand(commands.SetPersonDisplayName{PersonID: id, Owner: "o", Handle: "alice", DisplayName: "Alice"})
+	if err == nil {
+		t.Error("named someone who is merged")
+	}
+	evts, err := a.HandleCommand(cmd)
+	if err != nil || len(evts) != 1 {
+		t.Errorf("got %v %v, want PersonUnmerged", evts, err)
+	}
+}
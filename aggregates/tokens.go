@@ -0,0 +1,85 @@
+package aggregates
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rcy/evoke"
+	"github.com/rcy/whatever/commands"
+	"github.com/rcy/whatever/events"
+)
+
+type apiTokenAggregate struct {
+	id      uuid.UUID
+	created bool
+	owner   string
+	revoked bool
+}
+
+func NewAPITokenAggregate(id uuid.UUID) *apiTokenAggregate {
+	return &apiTokenAggregate{id: id}
+}
+
+func (a *apiTokenAggregate) HandleCommand(cmd evoke.Command) ([]evoke.Event, error) {
+	aggregateID := cmd.AggregateID()
+	if aggregateID == uuid.Nil {
+		return nil, fmt.Errorf("no aggregateID: %v", cmd)
+	}
+
+	if a.id != aggregateID {
+		panic("id mismatch")
+	}
+
+	switch c := cmd.(type) {
+	case commands.CreateAPIToken:
+		if a.created {
+			return nil, fmt.Errorf("token already exists")
+		}
+		if c.Owner == "" {
+			return nil, fmt.Errorf("owner cannot be empty")
+		}
+		name := strings.TrimSpace(c.Name)
+		if name == "" {
+			return nil, fmt.Errorf("name cannot be empty")
+		}
+		if c.Hash == "" {
+			return nil, fmt.Errorf("hash cannot be empty")
+		}
+		return []evoke.Event{events.APITokenCreated{
+			TokenID:   aggregateID,
+			Owner:     c.Owner,
+			Name:      name,
+			Hash:      c.Hash,
+			ReadOnly:  c.ReadOnly,
+			CreatedAt: time.Now(),
+		}}, nil
+	case commands.RevokeAPIToken:
+		if !a.created || a.owner != c.Owner {
+			return nil, fmt.Errorf("token not found")
+		}
+		if a.revoked {
+			return nil, fmt.Errorf("token already revoked")
+		}
+		return []evoke.Event{events.APITokenRevoked{
+			TokenID:   aggregateID,
+			RevokedAt: time.Now(),
+		}}, nil
+	}
+
+	return nil, fmt.Errorf("unhandled")
+}
+
+func (a *apiTokenAggregate) Apply(e evoke.Event) error {
+	switch evt := e.(type) {
+	case events.APITokenCreated:
+		a.created = true
+		a.owner = evt.Owner
+	case events.APITokenRevoked:
+		a.revoked = true
+	default:
+		return fmt.Errorf("not handled")
+	}
+	return nil
+}
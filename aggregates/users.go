@@ -0,0 +1,167 @@
+package aggregates
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rcy/evoke"
+	"github.com/rcy/whatever/catalog/notesmeta"
+	"github.com/rcy/whatever/commands"
+	"github.com/rcy/whatever/events"
+)
+
+// userAggregate holds a user's profile and preferences. Users are keyed by
+// commands.UserID of their owner id. Preferences can be set before the user
+// is created, for owners that only use the cli.
+type userAggregate struct {
+	id                 uuid.UUID
+	created            bool
+	displayName        string
+	timeZone           string
+	weekStart          time.Weekday
+	captureCategory    string
+	classifierDisabled bool
+}
+
+func NewUserAggregate(id uuid.UUID) *userAggregate {
+	return &userAggregate{id: id, captureCategory: notesmeta.Inbox.Slug}
+}
+
+func (a *userAggregate) HandleCommand(cmd evoke.Command) ([]evoke.Event, error) {
+	aggregateID := cmd.AggregateID()
+	if aggregateID == uuid.Nil {
+		return nil, fmt.Errorf("no aggregateID: %v", cmd)
+	}
+
+	if a.id != aggregateID {
+		panic("id mismatch")
+	}
+
+	checkOwner := func(owner string) error {
+		if owner == "" || commands.UserID(owner) != aggregateID {
+			return fmt.Errorf("owner does not match user")
+		}
+		return nil
+	}
+
+	switch c := cmd.(type) {
+	case commands.CreateUser:
+		if err := checkOwner(c.Owner); err != nil {
+			return nil, err
+		}
+		if a.created {
+			return nil, fmt.Errorf("user already exists")
+		}
+		return []evoke.Event{events.UserCreated{
+			UserID:      aggregateID,
+			Owner:       c.Owner,
+			DisplayName: strings.TrimSpace(c.DisplayName),
+			Email:       c.Email,
+			CreatedAt:   time.Now(),
+		}}, nil
+	case commands.SetUserDisplayName:
+		if err := checkOwner(c.Owner); err != nil {
+			return nil, err
+		}
+		displayName := strings.TrimSpace(c.DisplayName)
+		if displayName == "" {
+			return nil, fmt.Errorf("display name cannot be empty")
+		}
+		if a.displayName == displayName {
+			return nil, fmt.Errorf("display name already set to %s", displayName)
+		}
+		return []evoke.Event{events.UserDisplayNameSet{
+			UserID:      aggregateID,
+			Owner:       c.Owner,
+			DisplayName: displayName,
+		}}, nil
+	case commands.SetUserTimeZone:
+		if err := checkOwner(c.Owner); err != nil {
+			return nil, err
+		}
+		timeZone := strings.TrimSpace(c.TimeZone)
+		// time.LoadLocation also accepts "" and "Local", which are not zone names
+		if timeZone == "" || timeZone == "Local" {
+			return nil, fmt.Errorf("time zone cannot be empty")
+		}
+		if _, err := time.LoadLocation(timeZone); err != nil {
+			return nil, fmt.Errorf("unknown time zone: %s", timeZone)
+		}
+		if a.timeZone == timeZone {
+			return nil, fmt.Errorf("time zone already set to %s", timeZone)
+		}
+		return []evoke.Event{events.UserTimeZoneSet{
+			UserID:   aggregateID,
+			Owner:    c.Owner,
+			TimeZone: timeZone,
+		}}, nil
+	case commands.SetUserWeekStart:
+		if err := checkOwner(c.Owner); err != nil {
+			return nil, err
+		}
+		if c.WeekStart < time.Sunday || c.WeekStart > time.Saturday {
+			return nil, fmt.Errorf("invalid week start: %d", c.WeekStart)
+		}
+		if a.weekStart == c.WeekStart {
+			return nil, fmt.Errorf("week already starts on %s", c.WeekStart)
+		}
+		return []evoke.Event{events.UserWeekStartSet{
+			UserID:    aggregateID,
+			Owner:     c.Owner,
+			WeekStart: c.WeekStart,
+		}}, nil
+	case commands.SetUserCaptureCategory:
+		if err := checkOwner(c.Owner); err != nil {
+			return nil, err
+		}
+		if !slices.ContainsFunc(notesmeta.RefileCategories, func(cat notesmeta.Category) bool { return cat.Slug == c.Category }) {
+			return nil, fmt.Errorf("unknown category: %s", c.Category)
+		}
+		if a.captureCategory == c.Category {
+			return nil, fmt.Errorf("capture category already set to %s", c.Category)
+		}
+		return []evoke.Event{events.UserCaptureCategorySet{
+			UserID:   aggregateID,
+			Owner:    c.Owner,
+			Category: c.Category,
+		}}, nil
+	case commands.SetUserClassifierEnabled:
+		if err := checkOwner(c.Owner); err != nil {
+			return nil, err
+		}
+		if a.classifierDisabled == !c.Enabled {
+			return nil, fmt.Errorf("classifier enabled already set to %v", c.Enabled)
+		}
+		return []evoke.Event{events.UserClassifierEnabledSet{
+			UserID:  aggregateID,
+			Owner:   c.Owner,
+			Enabled: c.Enabled,
+		}}, nil
+	}
+
+	return nil, fmt.Errorf("unhandled")
+}
+
+func (a *userAggregate) Apply(e evoke.Event) error {
+	switch evt := e.(type) {
+	case events.UserCreated:
+		a.created = true
+		a.displayName = evt.DisplayName
+	case events.UserDisplayNameSet:
+		a.displayName = evt.DisplayName
+	case events.UserTimeZoneSet:
+		a.timeZone = evt.TimeZone
+	case events.UserWeekStartSet:
+		a.weekStart = evt.WeekStart
+	case events.UserCaptureCategorySet:
+		a.captureCategory = evt.Category
+	case events.UserClassifierEnabledSet:
+		a.classifierDisabled = !evt.Enabled
+	default:
+		return fmt.Errorf("not handled")
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+// Package api holds the types exchanged over the json api served at
+// /api/v1, and a client for it.
+package api
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rcy/whatever/catalog/notesmeta"
+)
+
+type Note struct {
+	ID          uuid.UUID   `json:"id"`
+	CreatedAt   time.Time   `json:"createdAt"`
+	Text        string      `json:"text"`
+	DisplayText string      `json:"displayText"` // Text with the title of its link in front
+	Category    string      `json:"category"`
+	Subcategory string      `json:"subcategory"`
+	Due         *time.Time  `json:"due"`
+	Starred     bool        `json:"starred"`
+	Status      string      `json:"status"`
+	Deleted     bool        `json:"deleted"`
+	Suggestion  *Suggestion `json:"suggestion,omitempty"`
+	Link        *Link       `json:"link,omitempty"`
+
+	CompletedAt   *time.Time `json:"completedAt,omitempty"`
+	DeferredCount int        `json:"deferredCount"`
+	ReopenedCount int        `json:"reopenedCount"`
+	Recurrence    string     `json:"recurrence,omitempty"`
+
+	// Tags and Links are only set when a single note is returned
+	Tags  []string `json:"tags,omitempty"`
+	Links []Link   `json:"links,omitempty"`
+}
+
+// Link is what enrichment found at a link in a note
+type Link struct {
+	Title        string `json:"title"`
+	Description  string `json:"description,omitempty"`
+	SiteName     string `json:"siteName,omitempty"`
+	URL          string `json:"url,omitempty"`
+	CanonicalURL string `json:"canonicalUrl,omitempty"`
+	Thumbnail    string `json:"thumbnail,omitempty"`
+	ContentType  string `json:"contentType,omitempty"`
+	// Status is "enriching" or "failure" until the link is enriched, and
+	// Error says why it failed. They are only set in Note.Links.
+	Status string `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+	// ArchivedAt is when a readable copy of the page was saved, for notes
+	// to read, watch or bookmark
+	ArchivedAt *time.Time `json:"archivedAt,omitempty"`
+}
+
+// SearchResult is a note matching a search
+type SearchResult struct {
+	Note Note `json:"note"`
+	// Snippet is the html escaped part of the note that best matches the
+	// search, with matching words in <mark> elements. It is empty when the
+	// search only has filters.
+	Snippet string `json:"snippet,omitempty"`
+}
+
+// Person is someone @mentioned in notes
+type Person struct {
+	Handle        string     `json:"handle"`
+	DisplayName   string     `json:"displayName,omitempty"`
+	Notes         string     `json:"notes,omitempty"`
+	Aliases       []string   `json:"aliases,omitempty"` // handles merged into this one
+	Mentions      int        `json:"mentions"`
+	LastMentioned *time.Time `json:"lastMentioned,omitempty"`
+	OpenTasks     int        `json:"openTasks"`
+}
+
+// TagCount is a tag and the number of notes that have it
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// TaskEvent is an entry in the completion history of a task
+type TaskEvent struct {
+	Kind string     `json:"kind"` // "completed", "deferred", "reopened" or "recurred"
+	At   time.Time  `json:"at"`
+	From *time.Time `json:"from,omitempty"` // due date before a deferral
+	To   *time.Time `json:"to,omitempty"`   // due date after a deferral or recurrence, if any
+}
+
+type Suggestion struct {
+	Category    string `json:"category"`
+	Subcategory string `json:"subcategory,omitempty"`
+	Timeframe   string `json:"timeframe,omitempty"`
+}
+
+// Classification is the outcome of running the classifier on one note. Note
+// is where the note ended up, Filed reports whether it was moved there.
+type Classification struct {
+	Note        Note    `json:"note"`
+	Category    string  `json:"category"`
+	Subcategory string  `json:"subcategory,omitempty"`
+	Timeframe   string  `json:"timeframe,omitempty"`
+	Confidence  float64 `json:"confidence"`
+	Filed       bool    `json:"filed"`
+}
+
+// Me is the authenticated user and their preferences
+type Me struct {
+	ID                string `json:"id"`
+	DisplayName       string `json:"displayName"`
+	Email             string `json:"email"`
+	TimeZone          string `json:"timeZone"`
+	WeekStart         string `json:"weekStart"`
+	CaptureCategory   string `json:"captureCategory"`
+	ClassifierEnabled bool   `json:"classifierEnabled"`
+}
+
+// Preferences is a partial update of the user's preferences, nil fields
+// are left unchanged
+type Preferences struct {
+	DisplayName       *string `json:"displayName,omitempty"`
+	TimeZone          *string `json:"timeZone,omitempty"`
+	WeekStart         *string `json:"weekStart,omitempty"`
+	CaptureCategory   *string `json:"captureCategory,omitempty"`
+	ClassifierEnabled *bool   `json:"classifierEnabled,omitempty"`
+}
+
+// Location returns the user's time zone
+func (m Me) Location() (*time.Location, error) {
+	return time.LoadLocation(m.TimeZone)
+}
+
+// Calendar returns how the user counts days and weeks
+func (m Me) Calendar() (notesmeta.Calendar, error) {
+	loc, err := m.Location()
+	if err != nil {
+		return notesmeta.Calendar{}, err
+	}
+	weekStart, err := notesmeta.ParseWeekday(m.WeekStart)
+	if err != nil {
+		return notesmeta.Calendar{}, err
+	}
+	return notesmeta.Calendar{Location: loc, WeekStart: weekStart}, nil
+}
+
+// ErrorResponse is the body of every error response
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+type ErrorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error is returned by the client when the server responds with an error
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
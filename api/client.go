@@ -0,0 +1,329 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client talks to the json api of a whatever server
+type Client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+// NewClient returns a client for the server at baseURL authenticating with
+// a personal api token
+func NewClient(baseURL string, token string) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/") + "/api/v1",
+		token:   token,
+		http:    &http.Client{Timeout: 2 * time.Minute},
+	}
+}
+
+// NewLocalClient returns a client that serves requests in process with
+// handler, which must serve the api routes at its root
+func NewLocalClient(handler http.Handler) *Client {
+	return &Client{
+		baseURL: "http://local",
+		http:    &http.Client{Transport: handlerTransport{handler}},
+	}
+}
+
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	w := &responseWriter{header: http.Header{}}
+	t.handler.ServeHTTP(w, req)
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", w.status, http.StatusText(w.status)),
+		StatusCode:    w.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        w.header,
+		Body:          io.NopCloser(&w.body),
+		ContentLength: int64(w.body.Len()),
+		Request:       req,
+	}, nil
+}
+
+// responseWriter keeps the response of a handler served in process
+type responseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *responseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *responseWriter) Write(data []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(data)
+}
+
+func (c *Client) do(method string, path string, body any, out any) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("Marshal: %w", err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, reqBody)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		var errResp ErrorResponse
+		err := json.NewDecoder(resp.Body).Decode(&errResp)
+		if err != nil || errResp.Error.Message == "" {
+			return &Error{Status: resp.StatusCode, Code: "http", Message: resp.Status}
+		}
+		return &Error{Status: resp.StatusCode, Code: errResp.Error.Code, Message: errResp.Error.Message}
+	}
+
+	if out == nil {
+		return nil
+	}
+	err = json.NewDecoder(resp.Body).Decode(out)
+	if err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// Me returns the user the client is authenticated as
+func (c *Client) Me() (Me, error) {
+	var me Me
+	err := c.do(http.MethodGet, "/me", nil, &me)
+	return me, err
+}
+
+// SetTimeZone sets the time zone used to work out due dates
+func (c *Client) SetTimeZone(timeZone string) (Me, error) {
+	var me Me
+	err := c.do(http.MethodPut, "/me/timezone", map[string]string{"timeZone": timeZone}, &me)
+	return me, err
+}
+
+// UpdatePreferences changes the preferences that are set in prefs
+func (c *Client) UpdatePreferences(prefs Preferences) (Me, error) {
+	var me Me
+	err := c.do(http.MethodPatch, "/me", prefs, &me)
+	return me, err
+}
+
+// ListNotes returns notes matching the filter query parameters, newest first
+func (c *Client) ListNotes(filter url.Values) ([]Note, error) {
+	var resp struct {
+		Notes []Note `json:"notes"`
+	}
+	path := "/notes"
+	if len(filter) > 0 {
+		path += "?" + filter.Encode()
+	}
+	err := c.do(http.MethodGet, path, nil, &resp)
+	return resp.Notes, err
+}
+
+// ListDeletedNotes returns deleted notes, newest first
+func (c *Client) ListDeletedNotes() ([]Note, error) {
+	var resp struct {
+		Notes []Note `json:"notes"`
+	}
+	err := c.do(http.MethodGet, "/notes/deleted", nil, &resp)
+	return resp.Notes, err
+}
+
+func (c *Client) GetNote(id string) (Note, error) {
+	return c.noteRequest(http.MethodGet, "/notes/"+url.PathEscape(id), nil)
+}
+
+// CreateNote creates a note. Empty category and subcategory file the note
+// in the inbox.
+func (c *Client) CreateNote(text string, category string, subcategory string) (Note, error) {
+	return c.noteRequest(http.MethodPost, "/notes", map[string]string{
+		"text":        text,
+		"category":    category,
+		"subcategory": subcategory,
+	})
+}
+
+func (c *Client) EditNote(id string, text string) (Note, error) {
+	return c.noteRequest(http.MethodPatch, "/notes/"+url.PathEscape(id), map[string]string{"text": text})
+}
+
+func (c *Client) DeleteNote(id string) (Note, error) {
+	return c.noteRequest(http.MethodDelete, "/notes/"+url.PathEscape(id), nil)
+}
+
+func (c *Client) UndeleteNote(id string) (Note, error) {
+	return c.noteRequest(http.MethodPost, "/notes/"+url.PathEscape(id)+"/undelete", nil)
+}
+
+// EnrichNote looks up the link url in a note again, or all of its links
+// when url is empty
+func (c *Client) EnrichNote(id string, link string) (Note, error) {
+	return c.noteRequest(http.MethodPost, "/notes/"+url.PathEscape(id)+"/enrich", map[string]string{"url": link})
+}
+
+func (c *Client) SetNoteCategory(id string, category string) (Note, error) {
+	return c.noteRequest(http.MethodPut, "/notes/"+url.PathEscape(id)+"/category", map[string]string{"category": category})
+}
+
+// TransitionNote applies a subcategory transition event to a note
+func (c *Client) TransitionNote(id string, event string) (Note, error) {
+	return c.noteRequest(http.MethodPost, "/notes/"+url.PathEscape(id)+"/transitions", map[string]string{"event": event})
+}
+
+func (c *Client) StarNote(id string) (Note, error) {
+	return c.noteRequest(http.MethodPut, "/notes/"+url.PathEscape(id)+"/star", nil)
+}
+
+func (c *Client) UnstarNote(id string) (Note, error) {
+	return c.noteRequest(http.MethodDelete, "/notes/"+url.PathEscape(id)+"/star", nil)
+}
+
+func (c *Client) SetNoteDue(id string, due time.Time) (Note, error) {
+	return c.noteRequest(http.MethodPut, "/notes/"+url.PathEscape(id)+"/due", map[string]time.Time{"due": due})
+}
+
+func (c *Client) ClearNoteDue(id string) (Note, error) {
+	return c.noteRequest(http.MethodDelete, "/notes/"+url.PathEscape(id)+"/due", nil)
+}
+
+// SetNoteRecurrence makes a task recur by rule, eg "weekly mon,thu"
+func (c *Client) SetNoteRecurrence(id string, rule string) (Note, error) {
+	return c.noteRequest(http.MethodPut, "/notes/"+url.PathEscape(id)+"/recurrence", map[string]string{"rule": rule})
+}
+
+func (c *Client) ClearNoteRecurrence(id string) (Note, error) {
+	return c.noteRequest(http.MethodDelete, "/notes/"+url.PathEscape(id)+"/recurrence", nil)
+}
+
+// NoteHistory returns the completions, deferrals and reopenings of a task,
+// oldest first
+func (c *Client) NoteHistory(id string) ([]TaskEvent, error) {
+	var resp struct {
+		History []TaskEvent `json:"history"`
+	}
+	err := c.do(http.MethodGet, "/notes/"+url.PathEscape(id)+"/history", nil, &resp)
+	return resp.History, err
+}
+
+// TagNote adds an explicit tag to a note
+func (c *Client) TagNote(id string, tag string) (Note, error) {
+	return c.noteRequest(http.MethodPut, "/notes/"+url.PathEscape(id)+"/tags/"+url.PathEscape(tag), nil)
+}
+
+// UntagNote removes an explicit tag from a note
+func (c *Client) UntagNote(id string, tag string) (Note, error) {
+	return c.noteRequest(http.MethodDelete, "/notes/"+url.PathEscape(id)+"/tags/"+url.PathEscape(tag), nil)
+}
+
+// Tags returns each tag in use and how many notes have it
+func (c *Client) Tags() ([]TagCount, error) {
+	var resp struct {
+		Tags []TagCount `json:"tags"`
+	}
+	err := c.do(http.MethodGet, "/tags", nil, &resp)
+	return resp.Tags, err
+}
+
+// Search returns the notes matching query, best matches first, see
+// note.Projection.Search for the syntax
+func (c *Client) Search(query string) ([]SearchResult, error) {
+	var resp struct {
+		Results []SearchResult `json:"results"`
+	}
+	err := c.do(http.MethodGet, "/search?"+url.Values{"q": {query}}.Encode(), nil, &resp)
+	return resp.Results, err
+}
+
+// People returns everyone mentioned in notes, most recently mentioned first
+func (c *Client) People() ([]Person, error) {
+	var resp struct {
+		People []Person `json:"people"`
+	}
+	err := c.do(http.MethodGet, "/people", nil, &resp)
+	return resp.People, err
+}
+
+// GetPerson returns the person with handle, or the one it is an alias of
+func (c *Client) GetPerson(handle string) (Person, error) {
+	var p Person
+	err := c.do(http.MethodGet, "/people/"+url.PathEscape(handle), nil, &p)
+	return p, err
+}
+
+// UpdatePerson changes the details of a person that are not nil
+func (c *Client) UpdatePerson(handle string, displayName *string, notes *string) (Person, error) {
+	body := map[string]*string{"displayName": displayName, "notes": notes}
+	var p Person
+	err := c.do(http.MethodPatch, "/people/"+url.PathEscape(handle), body, &p)
+	return p, err
+}
+
+// MergePerson makes handle an alias of the person with handle into
+func (c *Client) MergePerson(handle string, into string) (Person, error) {
+	var p Person
+	err := c.do(http.MethodPut, "/people/"+url.PathEscape(handle)+"/merge", map[string]string{"into": into}, &p)
+	return p, err
+}
+
+func (c *Client) UnmergePerson(handle string) (Person, error) {
+	var p Person
+	err := c.do(http.MethodDelete, "/people/"+url.PathEscape(handle)+"/merge", nil, &p)
+	return p, err
+}
+
+// Classify runs the classifier on notes that have not been filed yet
+func (c *Client) Classify() ([]Classification, error) {
+	var resp struct {
+		Classifications []Classification `json:"classifications"`
+	}
+	err := c.do(http.MethodPost, "/classify", nil, &resp)
+	return resp.Classifications, err
+}
+
+func (c *Client) noteRequest(method string, path string, body any) (Note, error) {
+	var n Note
+	err := c.do(method, path, body, &n)
+	return n, err
+}
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
@@ -8,9 +9,13 @@ import (
 	"github.com/google/uuid"
 	"github.com/rcy/evoke"
 	"github.com/rcy/whatever/aggregates"
+	"github.com/rcy/whatever/blobs"
 	"github.com/rcy/whatever/commands"
 	"github.com/rcy/whatever/events"
+	"github.com/rcy/whatever/jobs"
 	"github.com/rcy/whatever/projections/note"
+	"github.com/rcy/whatever/projections/user"
+	"github.com/rcy/whatever/workers/archive"
 	"github.com/rcy/whatever/workers/classify"
 	"github.com/rcy/whatever/workers/enrich"
 )
@@ -18,18 +23,47 @@ import (
 type App struct {
 	Commander     evoke.CommandSender
 	Notes         *note.Projection
+	Users         *user.Projection
 	EventDebugger interface {
 		DebugEvents() ([]evoke.RecordedEvent, error)
 	}
-	classify *classify.Worker
+	Jobs       *jobs.Queue
+	Blobs      *blobs.Store
+	Classifier classify.Classifier
 }
 
-func New(filename string) (*App, error) {
-	if filename == "" {
+type Config struct {
+	// EventFile is the sqlite file holding the event log
+	EventFile string
+	// NotesFile is an optional sqlite file for the note projection. When
+	// empty the projection is kept in memory and rebuilt on every start.
+	NotesFile string
+	// UsersFile is an optional sqlite file for the user projection, kept
+	// in memory when empty like NotesFile
+	UsersFile string
+	// JobsFile is the sqlite file holding the background job queue
+	JobsFile string
+	// BlobsDir is the directory holding archived copies of linked pages
+	BlobsDir string
+	// Classifier sorts new inbox notes into tasks and references
+	Classifier classify.Classifier
+}
+
+func New(cfg Config) (*App, error) {
+	if cfg.EventFile == "" {
 		return nil, errors.New("filename is empty")
 	}
+	if cfg.JobsFile == "" {
+		return nil, errors.New("jobs filename is empty")
+	}
+	if cfg.BlobsDir == "" {
+		return nil, errors.New("blobs directory is empty")
+	}
+	if cfg.Classifier == nil {
+		return nil, errors.New("classifier is nil")
+	}
 
-	eventStore, err := evoke.NewFileStore(filename)
+	eventStore, err := evoke.NewFileStore(cfg.EventFile)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -42,19 +76,88 @@ func New(filename string) (*App, error) {
 	evoke.RegisterEvent(eventStore, &events.NoteTextUpdated{})
 	evoke.RegisterEvent(eventStore, &events.NoteCategoryChanged{})
 	evoke.RegisterEvent(eventStore, &events.NoteSubcategoryChanged{})
+	evoke.RegisterEvent(eventStore, &events.NoteClassificationSuggested{})
 	evoke.RegisterEvent(eventStore, &events.NoteDueChanged{})
 	evoke.RegisterEvent(eventStore, &events.NoteDueCleared{})
 	evoke.RegisterEvent(eventStore, &events.NoteEnriched{})
 	evoke.RegisterEvent(eventStore, &events.NoteEnrichmentFailed{})
+	evoke.RegisterEvent(eventStore, &events.NoteArchiveRequested{})
+	evoke.RegisterEvent(eventStore, &events.NoteArchived{})
+	evoke.RegisterEvent(eventStore, &events.NoteArchiveFailed{})
 	evoke.RegisterEvent(eventStore, &events.NoteStarred{})
 	evoke.RegisterEvent(eventStore, &events.NoteUnstarred{})
+	evoke.RegisterEvent(eventStore, &events.NoteTaskCompleted{})
+	evoke.RegisterEvent(eventStore, &events.NoteTaskDeferred{})
+	evoke.RegisterEvent(eventStore, &events.NoteTaskReopened{})
+	evoke.RegisterEvent(eventStore, &events.NoteRecurrenceSet{})
+	evoke.RegisterEvent(eventStore, &events.NoteRecurrenceCleared{})
+	evoke.RegisterEvent(eventStore, &events.NoteTaskRecurred{})
+	evoke.RegisterEvent(eventStore, &events.TagNoteAdded{})
+	evoke.RegisterEvent(eventStore, &events.TagNoteRemoved{})
+	evoke.RegisterEvent(eventStore, &events.PersonDisplayNameSet{})
+	evoke.RegisterEvent(eventStore, &events.PersonNotesSet{})
+	evoke.RegisterEvent(eventStore, &events.PersonMerged{})
+	evoke.RegisterEvent(eventStore, &events.PersonUnmerged{})
+	evoke.RegisterEvent(eventStore, &events.APITokenCreated{})
+	evoke.RegisterEvent(eventStore, &events.APITokenRevoked{})
+	evoke.RegisterEvent(eventStore, &events.UserCreated{})
+	evoke.RegisterEvent(eventStore, &events.UserDisplayNameSet{})
+	evoke.RegisterEvent(eventStore, &events.UserTimeZoneSet{})
+	evoke.RegisterEvent(eventStore, &events.UserWeekStartSet{})
+	evoke.RegisterEvent(eventStore, &events.UserCaptureCategorySet{})
+	evoke.RegisterEvent(eventStore, &events.UserClassifierEnabledSet{})
+
+	//
+	// PROJECTIONS
+	//
+	var noteProjection *note.Projection
+	if cfg.NotesFile == "" {
+		noteProjection, err = note.New()
+	} else {
+		noteProjection, err = note.Open(cfg.NotesFile)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	blobStore, err := blobs.Open(cfg.BlobsDir)
+	if err != nil {
+		return nil, err
+	}
+	noteProjection.UseBlobs(blobStore)
+	notes, err := newProjector(noteProjection, eventStore)
+	if err != nil {
+		return nil, err
+	}
+	subscribeNotes(notes)
+
+	var userProjection *user.Projection
+	if cfg.UsersFile == "" {
+		userProjection, err = user.New()
+	} else {
+		userProjection, err = user.Open(cfg.UsersFile)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	users, err := newProjector(userProjection, eventStore)
+	if err != nil {
+		return nil, err
+	}
+	users.Subscribe(events.APITokenCreated{})
+	users.Subscribe(events.APITokenRevoked{})
+	users.Subscribe(events.UserCreated{})
+	users.Subscribe(events.UserDisplayNameSet{})
+	users.Subscribe(events.UserTimeZoneSet{})
+	users.Subscribe(events.UserWeekStartSet{})
+	users.Subscribe(events.UserCaptureCategorySet{})
+	users.Subscribe(events.UserClassifierEnabledSet{})
 
 	//
 	// COMMANDS
 	//
 	commandBus := evoke.NewCommandBus()
 
-	noteFactory := func(id uuid.UUID) evoke.Aggregate { return aggregates.NewNoteAggregate(id) }
+	noteFactory := func(id uuid.UUID) evoke.Aggregate { return aggregates.NewNoteAggregate(id, userProjection.Calendar) }
 	noteHandler := evoke.NewAggregateHandler(eventStore, noteFactory)
 	commandBus.RegisterHandler(commands.CreateNote{}, noteHandler)
 	commandBus.RegisterHandler(commands.SetNoteOwner{}, noteHandler)
@@ -63,52 +166,123 @@ func New(filename string) (*App, error) {
 	commandBus.RegisterHandler(commands.UpdateNoteText{}, noteHandler)
 	commandBus.RegisterHandler(commands.SetNoteCategory{}, noteHandler)
 	commandBus.RegisterHandler(commands.TransitionNoteSubcategory{}, noteHandler)
+	commandBus.RegisterHandler(commands.SuggestNoteClassification{}, noteHandler)
 	commandBus.RegisterHandler(commands.SetNoteDue{}, noteHandler)
 	commandBus.RegisterHandler(commands.ClearNoteDue{}, noteHandler)
 	commandBus.RegisterHandler(commands.CompleteNoteEnrichment{}, noteHandler)
 	commandBus.RegisterHandler(commands.FailNoteEnrichment{}, noteHandler)
+	commandBus.RegisterHandler(commands.RequestNoteEnrichment{}, noteHandler)
+	commandBus.RegisterHandler(commands.CompleteNoteArchive{}, noteHandler)
+	commandBus.RegisterHandler(commands.FailNoteArchive{}, noteHandler)
 	commandBus.RegisterHandler(commands.StarNote{}, noteHandler)
 	commandBus.RegisterHandler(commands.UnstarNote{}, noteHandler)
+	commandBus.RegisterHandler(commands.SetNoteRecurrence{}, noteHandler)
+	commandBus.RegisterHandler(commands.ClearNoteRecurrence{}, noteHandler)
+	commandBus.RegisterHandler(commands.AddNoteTag{}, noteHandler)
+	commandBus.RegisterHandler(commands.RemoveNoteTag{}, noteHandler)
+
+	personFactory := func(id uuid.UUID) evoke.Aggregate {
+		return aggregates.NewPersonAggregate(id, noteProjection.ResolveHandle)
+	}
+	personHandler := evoke.NewAggregateHandler(eventStore, personFactory)
+	commandBus.RegisterHandler(commands.SetPersonDisplayName{}, personHandler)
+	commandBus.RegisterHandler(commands.SetPersonNotes{}, personHandler)
+	commandBus.RegisterHandler(commands.MergePerson{}, personHandler)
+	commandBus.RegisterHandler(commands.UnmergePerson{}, personHandler)
+
+	apiTokenFactory := func(id uuid.UUID) evoke.Aggregate { return aggregates.NewAPITokenAggregate(id) }
+	apiTokenHandler := evoke.NewAggregateHandler(eventStore, apiTokenFactory)
+	commandBus.RegisterHandler(commands.CreateAPIToken{}, apiTokenHandler)
+	commandBus.RegisterHandler(commands.RevokeAPIToken{}, apiTokenHandler)
+
+	userFactory := func(id uuid.UUID) evoke.Aggregate { return aggregates.NewUserAggregate(id) }
+	userHandler := evoke.NewAggregateHandler(eventStore, userFactory)
+	commandBus.RegisterHandler(commands.CreateUser{}, userHandler)
+	commandBus.RegisterHandler(commands.SetUserDisplayName{}, userHandler)
+	commandBus.RegisterHandler(commands.SetUserTimeZone{}, userHandler)
+	commandBus.RegisterHandler(commands.SetUserWeekStart{}, userHandler)
+	commandBus.RegisterHandler(commands.SetUserCaptureCategory{}, userHandler)
+	commandBus.RegisterHandler(commands.SetUserClassifierEnabled{}, userHandler)
 
 	//
-	// PROJECTIONS
+	// REPLAY
 	//
-	eventBus := evoke.NewEventBus()
+	projectors := []*projector{notes, users}
 
-	noteProjection, err := note.New()
-	if err != nil {
-		log.Fatal(err)
+	// replay events from the oldest checkpoint, each projector skips the
+	// events its projection has already seen
+	position := notes.position
+	for _, p := range projectors {
+		position = min(position, p.position)
 	}
-	eventBus.Subscribe(events.NoteCreated{}, noteProjection)
-	eventBus.Subscribe(events.NoteOwnerSet{}, noteProjection)
-	eventBus.Subscribe(events.NoteDeleted{}, noteProjection)
-	eventBus.Subscribe(events.NoteUndeleted{}, noteProjection)
-	eventBus.Subscribe(events.NoteTextUpdated{}, noteProjection)
-	eventBus.Subscribe(events.NoteCategoryChanged{}, noteProjection)
-	eventBus.Subscribe(events.NoteSubcategoryChanged{}, noteProjection)
-	eventBus.Subscribe(events.NoteDueChanged{}, noteProjection)
-	eventBus.Subscribe(events.NoteDueCleared{}, noteProjection)
-	eventBus.Subscribe(events.NoteEnrichmentRequested{}, noteProjection)
-	eventBus.Subscribe(events.NoteEnriched{}, noteProjection)
-	eventBus.Subscribe(events.NoteEnrichmentFailed{}, noteProjection)
-	eventBus.Subscribe(events.NoteStarred{}, noteProjection)
-	eventBus.Subscribe(events.NoteUnstarred{}, noteProjection)
-
-	// replay old events through the bus
-	err = eventStore.ReplayFrom(0, eventBus.Publish)
+	err = eventStore.ReplayFrom(position+1, func(rec evoke.RecordedEvent, replay bool) error {
+		for _, p := range projectors {
+			err := p.Publish(rec, replay)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 	if err != nil {
 		log.Fatal(fmt.Errorf("ReplayFrom: %w", err))
 	}
 
-	// connect the event bus to the store for live events
-	eventStore.RegisterPublisher(eventBus)
+	// connect the projections to the store for live events
+	for _, p := range projectors {
+		eventStore.RegisterPublisher(p)
+	}
 
-	// live-only, async workers
-	enrichWorker := enrich.NewWorker(commandBus)
-	eventBus.Subscribe(events.NoteEnrichmentRequested{}, enrichWorker)
+	//
+	// JOBS
+	//
+	jobQueue, err := jobs.Open(cfg.JobsFile)
+	if err != nil {
+		return nil, fmt.Errorf("jobs.Open: %w", err)
+	}
+
+	// workers enqueue jobs for live events, to be run in the background
+	workers := newRouter()
+
+	enrichWorker := enrich.NewWorker(commandBus, jobQueue)
+	workers.Subscribe(events.NoteEnrichmentRequested{}, enrichWorker)
+	jobQueue.Register(enrich.JobKind, enrichWorker.Work)
 
-	classifyWorker := classify.NewWorker(commandBus)
-	eventBus.Subscribe(events.NoteCreated{}, classifyWorker)
+	archiveWorker := archive.NewWorker(commandBus, jobQueue, blobStore)
+	workers.Subscribe(events.NoteArchiveRequested{}, archiveWorker)
+	jobQueue.Register(archive.JobKind, archiveWorker.Work)
+
+	classifyWorker := classify.NewWorker(commandBus, jobQueue, noteProjection, userProjection, cfg.Classifier)
+	workers.Subscribe(events.NoteCreated{}, classifyWorker)
+	jobQueue.Register(classify.JobKind, classifyWorker.Work)
+
+	// enqueue the jobs of events recorded since the last start, in case a
+	// process stopped between recording an event and enqueuing its jobs.
+	// Jobs are only enqueued once for each event.
+	head := notes.position
+	for _, p := range projectors {
+		head = max(head, p.position)
+	}
+	position, ok, err := jobQueue.Position()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		// queues from before checkpoints have had every job enqueued live
+		position = head
+	}
+	err = eventStore.ReplayFrom(position+1, func(rec evoke.RecordedEvent, replay bool) error {
+		head = max(head, rec.Sequence)
+		return workers.Publish(rec, replay)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("enqueue missed jobs: %w", err)
+	}
+	err = jobQueue.Checkpoint(head)
+	if err != nil {
+		return nil, err
+	}
+	eventStore.RegisterPublisher(workers)
 
 	err = migrateOwnerlessNotes(noteProjection, commandBus)
 	if err != nil {
@@ -118,11 +292,62 @@ func New(filename string) (*App, error) {
 	return &App{
 		Commander:     commandBus,
 		Notes:         noteProjection,
+		Users:         userProjection,
 		EventDebugger: eventStore,
-		classify:      classifyWorker,
+		Jobs:          jobQueue,
+		Blobs:         blobStore,
+		Classifier:    cfg.Classifier,
 	}, nil
 }
 
+// subscribeNotes subscribes the note projection to the events it handles
+func subscribeNotes(notes *projector) {
+	notes.Subscribe(events.NoteCreated{})
+	notes.Subscribe(events.NoteOwnerSet{})
+	notes.Subscribe(events.NoteDeleted{})
+	notes.Subscribe(events.NoteUndeleted{})
+	notes.Subscribe(events.NoteTextUpdated{})
+	notes.Subscribe(events.NoteCategoryChanged{})
+	notes.Subscribe(events.NoteSubcategoryChanged{})
+	notes.Subscribe(events.NoteClassificationSuggested{})
+	notes.Subscribe(events.NoteDueChanged{})
+	notes.Subscribe(events.NoteDueCleared{})
+	notes.Subscribe(events.NoteEnrichmentRequested{})
+	notes.Subscribe(events.NoteEnriched{})
+	notes.Subscribe(events.NoteEnrichmentFailed{})
+	notes.Subscribe(events.NoteArchiveRequested{})
+	notes.Subscribe(events.NoteArchived{})
+	notes.Subscribe(events.NoteArchiveFailed{})
+	notes.Subscribe(events.NoteStarred{})
+	notes.Subscribe(events.NoteUnstarred{})
+	notes.Subscribe(events.NoteTaskCompleted{})
+	notes.Subscribe(events.NoteTaskDeferred{})
+	notes.Subscribe(events.NoteTaskReopened{})
+	notes.Subscribe(events.NoteRecurrenceSet{})
+	notes.Subscribe(events.NoteRecurrenceCleared{})
+	notes.Subscribe(events.NoteTaskRecurred{})
+	notes.Subscribe(events.TagNoteAdded{})
+	notes.Subscribe(events.TagNoteRemoved{})
+	notes.Subscribe(events.PersonDisplayNameSet{})
+	notes.Subscribe(events.PersonNotesSet{})
+	notes.Subscribe(events.PersonMerged{})
+	notes.Subscribe(events.PersonUnmerged{})
+}
+
+// Start runs background jobs until Close, including jobs left over from
+// previous runs. Commands that only run briefly leave those to the server.
+func (a *App) Start() {
+	a.Jobs.Start()
+}
+
+// Close stops the background job runners and runs the jobs enqueued since
+// the app was opened that are due before returning. Jobs waiting to be
+// retried stay queued for the next run.
+func (a *App) Close(ctx context.Context) error {
+	a.Jobs.Stop()
+	return a.Jobs.Drain(ctx)
+}
+
 // One time migration to add an owner to notes without one
 func migrateOwnerlessNotes(p *note.Projection, cmd evoke.CommandSender) error {
 	// find notes with no owner
@@ -0,0 +1,43 @@
+package app
+
+import (
+	"github.com/google/uuid"
+	"github.com/rcy/evoke"
+	"github.com/rcy/whatever/catalog/notesmeta"
+	"github.com/rcy/whatever/commands"
+)
+
+// CaptureNote creates a note from text typed into a capture box, which
+// would be filed in category and subcategory unless the text says
+// otherwise with quick add tokens, see notesmeta.ParseQuickAdd.
+func (a *App) CaptureNote(owner string, text string, category string, subcategory string) (uuid.UUID, error) {
+	q, err := notesmeta.ParseQuickAdd(text, category, subcategory)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	noteID := uuid.New()
+	cmds := []evoke.Command{commands.CreateNote{
+		Owner:       owner,
+		NoteID:      noteID,
+		Text:        q.Text,
+		Category:    q.Category,
+		Subcategory: q.Subcategory,
+	}}
+	if q.Transition != "" {
+		cmds = append(cmds, commands.TransitionNoteSubcategory{NoteID: noteID, TransitionEvent: q.Transition, Actor: "user"})
+	}
+	if q.Starred {
+		cmds = append(cmds, commands.StarNote{NoteID: noteID})
+	}
+	for _, tag := range q.Tags {
+		cmds = append(cmds, commands.AddNoteTag{NoteID: noteID, Tag: tag})
+	}
+
+	for _, cmd := range cmds {
+		if err := a.Commander.Send(cmd); err != nil {
+			return uuid.Nil, err
+		}
+	}
+	return noteID, nil
+}
@@ -0,0 +1,47 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/rcy/whatever/projections/note"
+)
+
+// CheckNotes rebuilds the note projection in memory from the whole event
+// log and compares its derived indexes with the ones in a.Notes. It returns
+// the rows the live projection is missing and the ones it should not have.
+func (a *App) CheckNotes() (missing []note.IndexRow, extra []note.IndexRow, err error) {
+	fresh, err := note.New()
+	if err != nil {
+		return nil, nil, err
+	}
+	fresh.UseBlobs(a.Blobs)
+	// every event is published in order below, so there is never a gap to
+	// replay from the log
+	p, err := newProjector(fresh, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	subscribeNotes(p)
+
+	recs, err := a.EventDebugger.DebugEvents()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, rec := range recs {
+		err := p.Publish(rec, true)
+		if err != nil {
+			return nil, nil, fmt.Errorf("replay event %d: %w", rec.Sequence, err)
+		}
+	}
+
+	want, err := fresh.Indexes()
+	if err != nil {
+		return nil, nil, err
+	}
+	got, err := a.Notes.Indexes()
+	if err != nil {
+		return nil, nil, err
+	}
+	missing, extra = note.CompareIndexes(got, want)
+	return missing, extra, nil
+}
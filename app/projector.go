@@ -0,0 +1,116 @@
+package app
+
+import (
+	"sync"
+
+	"github.com/rcy/evoke"
+)
+
+// projection applies events and remembers the last one it applied
+type projection interface {
+	Position() (int64, error)
+	Checkpoint(sequence int64) error
+	// Apply handles an event and checkpoints its sequence atomically
+	Apply(evt evoke.Event, replay bool, sequence int64) error
+}
+
+// replayer replays the events in the log from a sequence number
+type replayer interface {
+	ReplayFrom(seq int64, handler evoke.RecordedEventHandlerFunc) error
+}
+
+// projector feeds recorded events to a projection. Events the projection
+// has already applied are skipped, so each projection can be replayed from
+// its own checkpoint. An event is never skipped: when one failed to apply,
+// or events are published out of order, the events in between are replayed
+// from the log first.
+type projector struct {
+	projection projection
+	log        replayer
+	mu         sync.Mutex
+	position   int64
+	events     map[string]bool
+}
+
+func newProjector(p projection, log replayer) (*projector, error) {
+	position, err := p.Position()
+	if err != nil {
+		return nil, err
+	}
+	return &projector{projection: p, log: log, position: position, events: map[string]bool{}}, nil
+}
+
+// Subscribe sends events of the same type as evt to the projection
+func (p *projector) Subscribe(evt evoke.Event) {
+	p.events[evoke.TypeName(evt)] = true
+}
+
+func (p *projector) Publish(rec evoke.RecordedEvent, replay bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if rec.Sequence <= p.position {
+		return nil
+	}
+	// a replay is already reading the log in order
+	if !replay && rec.Sequence > p.position+1 {
+		err := p.log.ReplayFrom(p.position+1, func(missed evoke.RecordedEvent, replay bool) error {
+			if missed.Sequence >= rec.Sequence {
+				return nil
+			}
+			return p.apply(missed, replay)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return p.apply(rec, replay)
+}
+
+// apply applies rec and moves the position past it, only once it succeeded
+func (p *projector) apply(rec evoke.RecordedEvent, replay bool) error {
+	if rec.Sequence <= p.position {
+		return nil
+	}
+	var err error
+	if p.events[rec.EventType] {
+		err = p.projection.Apply(rec.Event, replay, rec.Sequence)
+	} else {
+		err = p.projection.Checkpoint(rec.Sequence)
+	}
+	if err != nil {
+		return err
+	}
+	p.position = rec.Sequence
+	return nil
+}
+
+// enqueuer enqueues background jobs for recorded events
+type enqueuer interface {
+	Enqueue(rec evoke.RecordedEvent) error
+}
+
+// router publishes events to the workers subscribed to their type. Unlike
+// evoke's event bus it is quiet about events nobody subscribed to.
+type router struct {
+	handlers map[string][]enqueuer
+}
+
+func newRouter() *router {
+	return &router{handlers: map[string][]enqueuer{}}
+}
+
+func (r *router) Subscribe(evt evoke.Event, handler enqueuer) {
+	name := evoke.TypeName(evt)
+	r.handlers[name] = append(r.handlers[name], handler)
+}
+
+func (r *router) Publish(rec evoke.RecordedEvent, replay bool) error {
+	for _, h := range r.handlers[rec.EventType] {
+		err := h.Enqueue(rec)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package app
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/rcy/evoke"
+)
+
+type testEvent struct{}
+
+type otherEvent struct{}
+
+// testProjection records the events applied to it, failing the ones in fail
+type testProjection struct {
+	applied  []int64
+	position int64
+	fail     map[int64]bool
+}
+
+func (p *testProjection) Position() (int64, error) {
+	return p.position, nil
+}
+
+func (p *testProjection) Checkpoint(sequence int64) error {
+	p.position = max(p.position, sequence)
+	return nil
+}
+
+func (p *testProjection) Apply(evt evoke.Event, replay bool, sequence int64) error {
+	if p.fail[sequence] {
+		return errors.New("database is locked")
+	}
+	p.applied = append(p.applied, sequence)
+	return p.Checkpoint(sequence)
+}
+
+// testLog is an event log of recs
+type testLog []evoke.RecordedEvent
+
+func (l testLog) ReplayFrom(seq int64, handler evoke.RecordedEventHandlerFunc) error {
+	for _, rec := range l {
+		if rec.Sequence < seq {
+			continue
+		}
+		err := handler(rec, true)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func testRecords() testLog {
+	var l testLog
+	for i := range int64(5) {
+		var evt evoke.Event = testEvent{}
+		if i == 2 {
+			evt = otherEvent{}
+		}
+		l = append(l, evoke.RecordedEvent{Sequence: i + 1, EventType: evoke.TypeName(evt), Event: evt})
+	}
+	return l
+}
+
+func TestProjectorPublish(t *testing.T) {
+	log := testRecords()
+	projection := &testProjection{}
+	p, err := newProjector(projection, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Subscribe(testEvent{})
+
+	for _, rec := range log {
+		err := p.Publish(rec, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := []int64{1, 2, 4, 5}; !reflect.DeepEqual(projection.applied, want) {
+		t.Errorf("applied %v, want %v", projection.applied, want)
+	}
+	if projection.position != 5 {
+		t.Errorf("position %d, want 5", projection.position)
+	}
+}
+
+func TestProjectorRetriesFailedEvent(t *testing.T) {
+	log := testRecords()
+	projection := &testProjection{fail: map[int64]bool{2: true}}
+	p, err := newProjector(projection, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Subscribe(testEvent{})
+
+	if err := p.Publish(log[0], false); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Publish(log[1], false); err == nil {
+		t.Fatal("want error")
+	}
+
+	// still failing, the projection does not move past event 2
+	if err := p.Publish(log[2], false); err == nil {
+		t.Fatal("want error")
+	}
+	if projection.position != 1 {
+		t.Errorf("position %d, want 1", projection.position)
+	}
+
+	delete(projection.fail, 2)
+	if err := p.Publish(log[3], false); err != nil {
+		t.Fatal(err)
+	}
+	if want := []int64{1, 2, 4}; !reflect.DeepEqual(projection.applied, want) {
+		t.Errorf("applied %v, want %v", projection.applied, want)
+	}
+	if projection.position != 4 {
+		t.Errorf("position %d, want 4", projection.position)
+	}
+}
+
+func TestProjectorOutOfOrder(t *testing.T) {
+	log := testRecords()
+	projection := &testProjection{}
+	p, err := newProjector(projection, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Subscribe(testEvent{})
+
+	for _, i := range []int{0, 3, 1, 2, 4} {
+		err := p.Publish(log[i], false)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := []int64{1, 2, 4, 5}; !reflect.DeepEqual(projection.applied, want) {
+		t.Errorf("applied %v, want %v", projection.applied, want)
+	}
+}
+
+func TestProjectorResumesFromCheckpoint(t *testing.T) {
+	log := testRecords()
+	projection := &testProjection{position: 3}
+	p, err := newProjector(projection, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Subscribe(testEvent{})
+
+	err = log.ReplayFrom(1, p.Publish)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int64{4, 5}; !reflect.DeepEqual(projection.applied, want) {
+		t.Errorf("applied %v, want %v", projection.applied, want)
+	}
+}
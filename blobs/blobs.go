@@ -0,0 +1,83 @@
+package blobs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Store keeps blobs in a directory, named by the sha256 of their contents,
+// so the same contents are only stored once and never change.
+type Store struct {
+	dir string
+}
+
+// Open returns the store in dir, creating the directory if needed
+func Open(dir string) (*Store, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("create blob dir: %w", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+// Put stores data and returns its hash
+func (s *Store) Put(data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	path := s.path(hash)
+	if _, err := os.Stat(path); err == nil {
+		return hash, nil
+	}
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return "", fmt.Errorf("create blob dir: %w", err)
+	}
+
+	// write to a temporary file first so a blob is never seen half written
+	tmp, err := os.CreateTemp(filepath.Dir(path), hash+".*")
+	if err != nil {
+		return "", fmt.Errorf("create blob: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("write blob: %w", err)
+	}
+	err = tmp.Close()
+	if err != nil {
+		return "", fmt.Errorf("write blob: %w", err)
+	}
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return "", fmt.Errorf("store blob: %w", err)
+	}
+	return hash, nil
+}
+
+// Get returns the contents of the blob with hash. It returns an error
+// matching os.ErrNotExist when there is no such blob.
+func (s *Store) Get(hash string) ([]byte, error) {
+	if len(hash) != sha256.Size*2 {
+		return nil, fmt.Errorf("invalid blob hash %q: %w", hash, os.ErrNotExist)
+	}
+	if _, err := hex.DecodeString(hash); err != nil {
+		return nil, fmt.Errorf("invalid blob hash %q: %w", hash, os.ErrNotExist)
+	}
+	data, err := os.ReadFile(s.path(hash))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("blob %s: %w", hash, os.ErrNotExist)
+	}
+	return data, err
+}
+
+// path spreads blobs over subdirectories named by the first two characters
+// of their hash
+func (s *Store) path(hash string) string {
+	return filepath.Join(s.dir, hash[:2], hash[2:])
+}
@@ -0,0 +1,206 @@
+package notesmeta
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseDue parses a due date written as a date, optionally followed by a
+// time of day, relative to now in the calendar. Dates can be written as
+//
+//	today, tomorrow
+//	tue, next tuesday (the coming one, never today)
+//	in 3 days, in 2 weeks, in 1 month
+//	next week, next month (the day they start)
+//	dec 1, 1 december, dec 1 2027 (the coming one if no year is given)
+//	2026-12-01
+//
+// and times as "at 3pm", "9:30am" or "15:00". A time on its own is today, or
+// tomorrow once it has passed. A date without a time is due by the end of
+// the day, see Midnight, so a time of midnight is rejected as
+// ErrMidnightDue.
+func ParseDue(input string, cal Calendar, now time.Time) (time.Time, error) {
+	fields := strings.Fields(strings.ToLower(input))
+	if len(fields) == 0 {
+		return time.Time{}, fmt.Errorf("empty due date")
+	}
+
+	// split off a trailing time of day
+	var clock *time.Duration
+	if d, ok := parseClock(fields[len(fields)-1]); ok {
+		clock = &d
+		fields = fields[:len(fields)-1]
+		if len(fields) > 0 && fields[len(fields)-1] == "at" {
+			fields = fields[:len(fields)-1]
+		}
+	}
+
+	today := Midnight(now.In(cal.Location))
+	day := today
+	if len(fields) > 0 {
+		var err error
+		day, err = parseDay(fields, cal, today)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%w: %q", err, input)
+		}
+	} else if clock == nil {
+		return time.Time{}, fmt.Errorf("empty due date")
+	}
+
+	if clock != nil {
+		if *clock == 0 {
+			return time.Time{}, ErrMidnightDue
+		}
+		// set the wall clock rather than adding to midnight, which is off
+		// by an hour on days the clocks change
+		hour, minute := int(clock.Hours()), int(clock.Minutes())%60
+		due := time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, cal.Location)
+		if len(fields) == 0 && due.Before(now) {
+			due = time.Date(day.Year(), day.Month(), day.Day()+1, hour, minute, 0, 0, cal.Location)
+		}
+		return due, nil
+	}
+	return day.AddDate(0, 0, 1), nil
+}
+
+// ErrMidnightDue is returned for a due time of midnight, which cannot be
+// told apart from a due date without a time: due by the end of the day
+// before
+var ErrMidnightDue = errors.New("a due time of midnight is the end of the day before, leave out the time to be due by the end of a day")
+
+var clockRe = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?(am|pm)?$`)
+
+// parseClock parses a time of day as 3pm, 9:30am or 15:00 into the time
+// since midnight
+func parseClock(s string) (time.Duration, bool) {
+	m := clockRe.FindStringSubmatch(s)
+	// a bare number is a day of the month, not a time
+	if m == nil || m[2] == "" && m[3] == "" {
+		return 0, false
+	}
+	hour, _ := strconv.Atoi(m[1])
+	minute, _ := strconv.Atoi(m[2])
+	switch m[3] {
+	case "am":
+		if hour < 1 || hour > 12 {
+			return 0, false
+		}
+		hour %= 12
+	case "pm":
+		if hour < 1 || hour > 12 {
+			return 0, false
+		}
+		hour = hour%12 + 12
+	}
+	if hour > 23 || minute > 59 {
+		return 0, false
+	}
+	return time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute, true
+}
+
+// parseDay returns the midnight starting the day described by fields
+func parseDay(fields []string, cal Calendar, today time.Time) (time.Time, error) {
+	text := strings.Join(fields, " ")
+	switch text {
+	case "today":
+		return today, nil
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), nil
+	case "next week":
+		return cal.StartOfWeek(today).AddDate(0, 0, 7), nil
+	case "next month":
+		return time.Date(today.Year(), today.Month()+1, 1, 0, 0, 0, 0, today.Location()), nil
+	}
+
+	if t, err := time.ParseInLocation(time.DateOnly, text, today.Location()); err == nil {
+		return t, nil
+	}
+
+	// tue, next tue
+	name := strings.TrimPrefix(text, "next ")
+	if d, err := ParseWeekday(name); err == nil {
+		days := (int(d) - int(today.Weekday()) + 7) % 7
+		if days == 0 {
+			days = 7
+		}
+		return today.AddDate(0, 0, days), nil
+	}
+
+	// in 3 days
+	if len(fields) == 3 && fields[0] == "in" {
+		n, err := strconv.Atoi(fields[1])
+		if err != nil || n < 0 {
+			return time.Time{}, fmt.Errorf("invalid number")
+		}
+		switch strings.TrimSuffix(fields[2], "s") {
+		case "day":
+			return today.AddDate(0, 0, n), nil
+		case "week":
+			return today.AddDate(0, 0, 7*n), nil
+		case "month":
+			return today.AddDate(0, n, 0), nil
+		}
+	}
+
+	// dec 1, 1 dec, dec 1 2027
+	if len(fields) == 2 || len(fields) == 3 {
+		month, dayText := fields[0], fields[1]
+		if _, err := strconv.Atoi(month); err == nil {
+			month, dayText = dayText, month
+		}
+		m, mok := parseMonth(month)
+		d, err := strconv.Atoi(strings.TrimRight(dayText, "stndrh,"))
+		if mok && err == nil && d >= 1 && d <= 31 {
+			if len(fields) == 3 {
+				year, err := strconv.Atoi(fields[2])
+				if err != nil {
+					return time.Time{}, fmt.Errorf("invalid year")
+				}
+				return validDate(year, m, d, today.Location())
+			}
+			t, err := validDate(today.Year(), m, d, today.Location())
+			if err != nil {
+				return time.Time{}, err
+			}
+			if t.Before(today) {
+				return validDate(today.Year()+1, m, d, today.Location())
+			}
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("could not understand due date")
+}
+
+func parseMonth(s string) (time.Month, bool) {
+	for m := time.January; m <= time.December; m++ {
+		name := strings.ToLower(m.String())
+		if s == name || len(s) >= 3 && strings.HasPrefix(name, s) {
+			return m, true
+		}
+	}
+	return 0, false
+}
+
+// validDate rejects dates like feb 30 that time.Date would normalize
+func validDate(year int, month time.Month, day int, loc *time.Location) (time.Time, error) {
+	t := time.Date(year, month, day, 0, 0, 0, 0, loc)
+	if t.Month() != month {
+		return time.Time{}, fmt.Errorf("%s has no day %d", month, day)
+	}
+	return t, nil
+}
+
+// FormatDue formats a due time in loc, as just the date when it is due by
+// the end of the day
+func FormatDue(due time.Time, loc *time.Location) string {
+	due = due.In(loc)
+	if due.Equal(Midnight(due)) {
+		return due.Add(-time.Nanosecond).Format(time.DateOnly)
+	}
+	return due.Format("2006-01-02 15:04")
+}
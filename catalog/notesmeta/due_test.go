@@ -0,0 +1,148 @@
+package notesmeta
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDue(t *testing.T) {
+	vancouver, err := time.LoadLocation("America/Vancouver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cal := Calendar{Location: vancouver, WeekStart: time.Monday}
+	// a friday morning, two days before clocks go forward
+	now := time.Date(2026, time.March, 6, 10, 0, 0, 0, vancouver)
+	date := func(month time.Month, day int, hour int, minute int) time.Time {
+		return time.Date(2026, month, day, hour, minute, 0, 0, vancouver)
+	}
+
+	tests := []struct {
+		input string
+		want  time.Time
+	}{
+		// dates are due by the midnight that ends them
+		{"today", date(time.March, 7, 0, 0)},
+		{"Tomorrow", date(time.March, 8, 0, 0)},
+		{"sat", date(time.March, 8, 0, 0)},
+		{"next saturday", date(time.March, 8, 0, 0)},
+		{"fri", date(time.March, 14, 0, 0)}, // never today
+		{"in 3 days", date(time.March, 10, 0, 0)},
+		{"in 2 weeks", date(time.March, 21, 0, 0)},
+		{"in 1 month", date(time.April, 7, 0, 0)},
+		{"next week", date(time.March, 10, 0, 0)}, // the week starts on monday
+		{"next month", date(time.April, 2, 0, 0)},
+		{"2026-12-01", date(time.December, 2, 0, 0)},
+		{"dec 1", date(time.December, 2, 0, 0)},
+		{"1 december", date(time.December, 2, 0, 0)},
+		{"dec 1st", date(time.December, 2, 0, 0)},
+		{"jan 5", time.Date(2027, time.January, 6, 0, 0, 0, 0, vancouver)}, // the coming one
+		{"mar 6", date(time.March, 7, 0, 0)},
+		{"dec 1 2027", time.Date(2027, time.December, 2, 0, 0, 0, 0, vancouver)},
+		{"feb 29 2028", time.Date(2028, time.March, 1, 0, 0, 0, 0, vancouver)},
+
+		// times are due at that time
+		{"3pm", date(time.March, 6, 15, 0)},
+		{"at 9:30am", date(time.March, 7, 9, 30)}, // passed today
+		{"today at 9:30am", date(time.March, 6, 9, 30)},
+		{"tomorrow at 15:00", date(time.March, 7, 15, 0)},
+		{"next tue at 12:01am", date(time.March, 10, 0, 1)},
+		{"dec 1 at 12pm", date(time.December, 1, 12, 0)},
+
+		// on the day clocks go forward the wall clock time is kept
+		{"sun at 3pm", date(time.March, 8, 15, 0)},
+		{"sun at 1:30am", date(time.March, 8, 1, 30)},
+		{"sun", date(time.March, 9, 0, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseDue(tt.input, cal, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("got %s, want %s", got.In(vancouver), tt.want)
+			}
+
+			// what is shown for a due parses back to it
+			formatted := FormatDue(got, vancouver)
+			again, err := ParseDue(formatted, cal, now)
+			if err != nil || !again.Equal(got) {
+				t.Errorf("%q parses back to %s, %v", formatted, again.In(vancouver), err)
+			}
+		})
+	}
+}
+
+func TestParseDueTimeZone(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// late on monday evening in UTC is already tuesday in Tokyo
+	now := time.Date(2026, time.June, 1, 20, 0, 0, 0, time.UTC)
+
+	got, err := ParseDue("today", Calendar{Location: tokyo}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2026, time.June, 3, 0, 0, 0, 0, tokyo)
+	if !got.Equal(want) {
+		t.Errorf("got %s, want %s", got.In(tokyo), want)
+	}
+
+	got, err = ParseDue("today", Calendar{Location: time.UTC}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = time.Date(2026, time.June, 2, 0, 0, 0, 0, time.UTC)
+	if !got.Equal(want) {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestParseDueErrors(t *testing.T) {
+	now := time.Date(2026, time.March, 6, 10, 0, 0, 0, time.UTC)
+	for _, input := range []string{
+		"",
+		"   ",
+		"at",
+		"soon",
+		"feb 30",
+		"feb 29 2027",
+		"in three days",
+		"in 3 fortnights",
+		"13pm",
+		"25:00",
+		"dec 1 nextyear",
+		"next tue at 12am",
+		"00:00",
+	} {
+		t.Run(input, func(t *testing.T) {
+			got, err := ParseDue(input, Calendar{Location: time.UTC}, now)
+			if err == nil {
+				t.Errorf("got %s, want error", got)
+			}
+		})
+	}
+}
+
+func TestFormatDue(t *testing.T) {
+	vancouver, err := time.LoadLocation("America/Vancouver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		due  time.Time
+		want string
+	}{
+		{time.Date(2026, time.March, 9, 0, 0, 0, 0, vancouver), "2026-03-08"},
+		{time.Date(2026, time.March, 8, 15, 0, 0, 0, vancouver), "2026-03-08 15:00"},
+		{time.Date(2026, time.March, 9, 7, 0, 0, 0, time.UTC), "2026-03-08"},
+	}
+	for _, tt := range tests {
+		if got := FormatDue(tt.due, vancouver); got != tt.want {
+			t.Errorf("FormatDue(%s) = %q, want %q", tt.due, got, tt.want)
+		}
+	}
+}
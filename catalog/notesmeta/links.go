@@ -0,0 +1,26 @@
+package notesmeta
+
+import (
+	"slices"
+	"strings"
+
+	"mvdan.cc/xurls/v2"
+)
+
+var linkRe = xurls.Strict()
+
+// Links returns the http and https links in text in the order they first
+// appear, without duplicates. Links without a scheme are left out since
+// there is nothing to fetch.
+func Links(text string) []string {
+	var links []string
+	for _, link := range linkRe.FindAllString(text, -1) {
+		if !strings.HasPrefix(link, "http://") && !strings.HasPrefix(link, "https://") {
+			continue
+		}
+		if !slices.Contains(links, link) {
+			links = append(links, link)
+		}
+	}
+	return links
+}
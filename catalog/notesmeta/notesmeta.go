@@ -3,17 +3,59 @@ package notesmeta
 import (
 	"fmt"
 	"slices"
+	"strings"
 	"time"
 )
 
-var location = func() *time.Location {
-	loc, err := time.LoadLocation("America/Creston")
+// DefaultTimeZone is used for users who have not set a time zone
+const DefaultTimeZone = "America/Creston"
+
+var defaultLocation = func() *time.Location {
+	loc, err := time.LoadLocation(DefaultTimeZone)
 	if err != nil {
 		panic(err)
 	}
 	return loc
 }()
 
+// Calendar is how a user counts days and weeks
+type Calendar struct {
+	Location  *time.Location
+	WeekStart time.Weekday
+}
+
+// DefaultCalendar is used for users who have not set their preferences
+var DefaultCalendar = Calendar{Location: defaultLocation, WeekStart: time.Sunday}
+
+// Now returns the current time in the calendar's time zone
+func (c Calendar) Now() time.Time {
+	return time.Now().In(c.Location)
+}
+
+// StartOfWeek returns the midnight that starts the week containing t
+func (c Calendar) StartOfWeek(t time.Time) time.Time {
+	t = t.In(c.Location)
+	return Midnight(t).AddDate(0, 0, -c.daysIntoWeek(t))
+}
+
+// daysIntoWeek returns how many days t is past the start of its week
+func (c Calendar) daysIntoWeek(t time.Time) int {
+	return (int(t.Weekday()) - int(c.WeekStart) + 7) % 7
+}
+
+// LoadLocation returns the time zone with the given name, or the default
+// time zone if name is empty or unknown
+func LoadLocation(name string) *time.Location {
+	if name == "" {
+		return defaultLocation
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return defaultLocation
+	}
+	return loc
+}
+
 type Category struct {
 	Slug          string
 	DisplayName   string
@@ -35,7 +77,7 @@ func (c Category) Inbox() Subcategory {
 type Transition struct {
 	Event        string
 	TargetSlug   string
-	DaysUntilDue func(time.Time) int
+	DaysUntilDue func(Calendar, time.Time) int
 }
 
 type Subcategory struct {
@@ -95,23 +137,33 @@ const (
 	taskDone        = "done"
 )
 
+// Subcategories of tasks that other packages treat specially
+const (
+	TaskScheduled = taskScheduled
+	TaskDone      = taskDone
+	TaskSomeday   = taskSomeday
+)
+
 type Timeframe struct {
 	Slug        string
 	EventName   string
 	DisplayName string
-	Days        func(time.Time) int
+	// Days returns the number of days from the midnight starting t's day to
+	// the end of the timeframe
+	Days func(c Calendar, t time.Time) int
 }
 
 var TimeframeList = []Timeframe{
-	{Slug: "today", EventName: "today", DisplayName: "Today", Days: func(t time.Time) int { return 1 }},
-	{Slug: "tomorrow", EventName: "tommorow", DisplayName: "Tomorrow", Days: func(t time.Time) int { return 2 }},
-	{Slug: "thisweek", EventName: "thisweek", DisplayName: "ThisWeek", Days: func(t time.Time) int { return int(6 - t.Weekday()) }},
-	{Slug: "nextweek", EventName: "nextweek", DisplayName: "NextWeek", Days: func(t time.Time) int { return 7 + int(6-t.Weekday()) }},
-	{Slug: "thismonth", EventName: "thismonth", DisplayName: "ThisMonth", Days: func(t time.Time) int { return remainingDaysInMonth(t, 1) }},
-	{Slug: "nextmonth", EventName: "nextmonth", DisplayName: "NextMonth", Days: func(t time.Time) int { return remainingDaysInMonth(t, 2) }},
+	{Slug: "today", EventName: "today", DisplayName: "Today", Days: func(c Calendar, t time.Time) int { return 1 }},
+	{Slug: "tomorrow", EventName: "tommorow", DisplayName: "Tomorrow", Days: func(c Calendar, t time.Time) int { return 2 }},
+	{Slug: "thisweek", EventName: "thisweek", DisplayName: "ThisWeek", Days: func(c Calendar, t time.Time) int { return 6 - c.daysIntoWeek(t) }},
+	{Slug: "nextweek", EventName: "nextweek", DisplayName: "NextWeek", Days: func(c Calendar, t time.Time) int { return 7 + 6 - c.daysIntoWeek(t) }},
+	{Slug: "thismonth", EventName: "thismonth", DisplayName: "ThisMonth", Days: func(c Calendar, t time.Time) int { return remainingDaysInMonth(t, 1) }},
+	{Slug: "nextmonth", EventName: "nextmonth", DisplayName: "NextMonth", Days: func(c Calendar, t time.Time) int { return remainingDaysInMonth(t, 2) }},
 }
 
-func timeframeLookup(slug string) (bool, Timeframe) {
+// Return timeframe by slug
+func TimeframeLookup(slug string) (bool, Timeframe) {
 	for _, tf := range TimeframeList {
 		if tf.Slug == slug {
 			return true, tf
@@ -120,16 +172,16 @@ func timeframeLookup(slug string) (bool, Timeframe) {
 	return false, Timeframe{}
 }
 
-// Return start time and end time of range (relative to now) for timerange given by slug
-// Eg: TimeframeRange("tomorrow") returns midnight+1 day, midnight+2 days, nil
-func TimeframeRange(slug string) (time.Time, time.Time, error) {
+// Return start time and end time of range (relative to now in the calendar) for timerange given by slug
+// Eg: TimeframeRange("tomorrow", cal) returns midnight+1 day, midnight+2 days, nil
+func TimeframeRange(slug string, cal Calendar) (time.Time, time.Time, error) {
 	for i, tf := range TimeframeList {
 		if tf.Slug == slug {
-			now := time.Now().In(location)
+			now := cal.Now()
 			start := Midnight(now)
-			end := start.AddDate(0, 0, tf.Days(now))
+			end := start.AddDate(0, 0, tf.Days(cal, now))
 			if i > 0 {
-				start = start.AddDate(0, 0, TimeframeList[i-1].Days(now))
+				start = start.AddDate(0, 0, TimeframeList[i-1].Days(cal, now))
 			} else {
 				start = time.Unix(0, 0) // show overdue on today
 			}
@@ -214,6 +266,12 @@ const (
 	noteOther         = "other"
 )
 
+// Archivable reports whether notes in category and subcategory keep a
+// readable copy of the pages they link to, for reading later
+func Archivable(category string, subcategory string) bool {
+	return category == Note.Slug && slices.Contains([]string{noteRead, noteWatch, noteBookmark}, subcategory)
+}
+
 var Note = Category{
 	Slug:        "reference",
 	DisplayName: "Notes",
@@ -328,3 +386,14 @@ var People = Category{
 
 var Categories = CategoryList{Inbox, Task, Note, People}
 var RefileCategories = CategoryList{Inbox, Task, Note}
+
+// ParseWeekday returns the weekday with the given english name, such as
+// "monday" or "Mon"
+func ParseWeekday(name string) (time.Weekday, error) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(name, d.String()) || strings.EqualFold(name, d.String()[:3]) {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown weekday: %s", name)
+}
@@ -0,0 +1,130 @@
+package notesmeta
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// QuickAdd is a note captured with inline tokens, see ParseQuickAdd
+type QuickAdd struct {
+	Text        string
+	Category    string
+	Subcategory string
+	// Transition is the subcategory transition event to apply after the
+	// note is created, eg "today" or "someday"
+	Transition string
+	Starred    bool
+	Tags       []string
+}
+
+// ParseQuickAdd pulls capture tokens out of text, for a note that would
+// otherwise be filed in category and subcategory:
+//
+//	!today, !tomorrow, !thisweek... or !someday schedule the note as a task
+//	*                                 as the first or last word, stars the note
+//	#tag                              tags the note
+//	/idea, /quote...                  files the note as that kind of reference
+//
+// The tokens are removed from the text.
+func ParseQuickAdd(text string, category string, subcategory string) (QuickAdd, error) {
+	q := QuickAdd{Category: category, Subcategory: subcategory}
+
+	var scheduled, filed bool
+	var out strings.Builder
+	last := 0
+	words := wordRe.FindAllStringIndex(text, -1)
+	for i, loc := range words {
+		word := text[loc[0]:loc[1]]
+		switch {
+		case word == "*" && (i == 0 || i == len(words)-1):
+			q.Starred = true
+		case strings.HasPrefix(word, "!") && quickAddTransition(word[1:]) != "":
+			q.Category = Task.Slug
+			q.Subcategory = Task.Inbox().Slug
+			q.Transition = quickAddTransition(word[1:])
+			scheduled = true
+		case strings.HasPrefix(word, "/") && quickAddReference(word[1:]) != "":
+			q.Category = Note.Slug
+			q.Subcategory = quickAddReference(word[1:])
+			filed = true
+		case strings.HasPrefix(word, "#") && validTag(word):
+			tag, _ := NormalizeTag(word)
+			if !slices.Contains(q.Tags, tag) {
+				q.Tags = append(q.Tags, tag)
+			}
+		default:
+			continue
+		}
+
+		// drop the token along with the spaces before it
+		start := loc[0]
+		for start > last && (text[start-1] == ' ' || text[start-1] == '\t') {
+			start--
+		}
+		out.WriteString(text[last:start])
+		last = loc[1]
+	}
+	out.WriteString(text[last:])
+
+	if scheduled && filed {
+		return QuickAdd{}, fmt.Errorf("a note cannot be both scheduled and filed as a reference")
+	}
+	q.Text = strings.TrimSpace(out.String())
+	return q, nil
+}
+
+var wordRe = regexp.MustCompile(`\S+`)
+
+// quickAddTransition returns the task transition event for a schedule token
+func quickAddTransition(token string) string {
+	token = strings.ToLower(token)
+	if token == taskSomeday {
+		return taskSomeday
+	}
+	if ok, tf := TimeframeLookup(token); ok {
+		return tf.EventName
+	}
+	return ""
+}
+
+// quickAddReference returns the reference subcategory for a filing token
+func quickAddReference(token string) string {
+	token = strings.ToLower(token)
+	// the first subcategory is the unsorted inbox
+	for _, sub := range Note.Subcategories[1:] {
+		if sub.Slug == token {
+			return sub.Slug
+		}
+	}
+	return ""
+}
+
+var tagRe = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// NormalizeTag returns tag lowercased and without a leading #
+func NormalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+	if !tagRe.MatchString(tag) {
+		return "", fmt.Errorf("invalid tag %q, use letters, digits, - and _", tag)
+	}
+	return tag, nil
+}
+
+func validTag(tag string) bool {
+	_, err := NormalizeTag(tag)
+	return err == nil
+}
+
+var handleRe = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// NormalizeHandle returns the handle of a person lowercased and without a
+// leading @
+func NormalizeHandle(handle string) (string, error) {
+	handle = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(handle), "@"))
+	if !handleRe.MatchString(handle) {
+		return "", fmt.Errorf("invalid handle %q, use letters, digits and _", handle)
+	}
+	return handle, nil
+}
@@ -0,0 +1,114 @@
+package notesmeta
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseQuickAdd(t *testing.T) {
+	tests := []struct {
+		text string
+		want QuickAdd
+	}{
+		{"buy milk", QuickAdd{Text: "buy milk", Category: "inbox", Subcategory: "default"}},
+		{"buy milk !today", QuickAdd{Text: "buy milk", Category: "task", Subcategory: "notnow", Transition: "today"}},
+		{"call the bank !Tomorrow *", QuickAdd{Text: "call the bank", Category: "task", Subcategory: "notnow", Transition: "tommorow", Starred: true}},
+		{"!someday learn the piano", QuickAdd{Text: "learn the piano", Category: "task", Subcategory: "notnow", Transition: "someday"}},
+		{"a bike rack /idea #Garden #garden #diy", QuickAdd{Text: "a bike rack", Category: "reference", Subcategory: "idea", Tags: []string{"garden", "diy"}}},
+		{"* buy milk", QuickAdd{Text: "buy milk", Category: "inbox", Subcategory: "default", Starred: true}},
+		{"#work\tfinish the report", QuickAdd{Text: "finish the report", Category: "inbox", Subcategory: "default", Tags: []string{"work"}}},
+
+		// words that only look like tokens are kept
+		{"ship it !soon", QuickAdd{Text: "ship it !soon", Category: "inbox", Subcategory: "default"}},
+		{"sort it /process", QuickAdd{Text: "sort it /process", Category: "inbox", Subcategory: "default"}},
+		{"see docs/#install", QuickAdd{Text: "see docs/#install", Category: "inbox", Subcategory: "default"}},
+		{"wow #great!", QuickAdd{Text: "wow #great!", Category: "inbox", Subcategory: "default"}},
+		{"2 * 3 = 6", QuickAdd{Text: "2 * 3 = 6", Category: "inbox", Subcategory: "default"}},
+		{"a **bold** plan", QuickAdd{Text: "a **bold** plan", Category: "inbox", Subcategory: "default"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, err := ParseQuickAdd(tt.text, "inbox", "default")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseQuickAddScheduledAndFiled(t *testing.T) {
+	got, err := ParseQuickAdd("a thought !today /idea", "inbox", "default")
+	if err == nil {
+		t.Errorf("got %+v, want error", got)
+	}
+}
+
+func TestNormalizeTag(t *testing.T) {
+	tests := []struct {
+		tag     string
+		want    string
+		wantErr bool
+	}{
+		{tag: "work", want: "work"},
+		{tag: "#Work", want: "work"},
+		{tag: "  to-do_2 ", want: "to-do_2"},
+		{tag: "", wantErr: true},
+		{tag: "#", wantErr: true},
+		{tag: "two words", wantErr: true},
+		{tag: "##work", wantErr: true},
+		{tag: "café", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			got, err := NormalizeTag(tt.tag)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("got %q, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeHandle(t *testing.T) {
+	tests := []struct {
+		handle  string
+		want    string
+		wantErr bool
+	}{
+		{handle: "alice", want: "alice"},
+		{handle: "@Alice_2", want: "alice_2"},
+		{handle: " bob ", want: "bob"},
+		{handle: "", wantErr: true},
+		{handle: "@", wantErr: true},
+		{handle: "mary-jane", wantErr: true},
+		{handle: "@@alice", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.handle, func(t *testing.T) {
+			got, err := NormalizeHandle(tt.handle)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("got %q, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
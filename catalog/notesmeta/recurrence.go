@@ -0,0 +1,186 @@
+package notesmeta
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kinds of recurrence rules
+const (
+	RecurDaily    = "daily"
+	RecurWeekdays = "weekdays"
+	RecurEvery    = "every"   // every N days after the last due date
+	RecurWeekly   = "weekly"  // on the given days of the week
+	RecurMonthly  = "monthly" // on day N of the month
+	RecurAfter    = "after"   // N days after the task was completed
+)
+
+// Recurrence is a rule for scheduling the next occurrence of a task when
+// it is done
+type Recurrence struct {
+	Kind     string
+	Days     int            // for every and after
+	Weekdays []time.Weekday // for weekly
+	Day      int            // for monthly
+}
+
+// ParseRecurrence parses a rule written as one of
+//
+//	daily
+//	weekdays
+//	every 3 days
+//	weekly mon,thu
+//	monthly 15
+//	3 days after done
+func ParseRecurrence(rule string) (Recurrence, error) {
+	fields := strings.Fields(strings.ToLower(rule))
+	if len(fields) == 0 {
+		return Recurrence{}, fmt.Errorf("empty recurrence rule")
+	}
+
+	days := func(s string) (int, error) {
+		n, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil || n < 1 {
+			return 0, fmt.Errorf("invalid number of days: %s", s)
+		}
+		return n, nil
+	}
+
+	switch {
+	case len(fields) == 1 && fields[0] == RecurDaily:
+		return Recurrence{Kind: RecurDaily}, nil
+	case len(fields) == 1 && fields[0] == RecurWeekdays:
+		return Recurrence{Kind: RecurWeekdays}, nil
+	case fields[0] == RecurEvery && (len(fields) == 2 || len(fields) == 3 && isDays(fields[2])):
+		n, err := days(fields[1])
+		if err != nil {
+			return Recurrence{}, err
+		}
+		return Recurrence{Kind: RecurEvery, Days: n}, nil
+	case fields[0] == RecurWeekly && len(fields) >= 2:
+		var weekdays []time.Weekday
+		for _, name := range strings.FieldsFunc(strings.Join(fields[1:], ","), func(r rune) bool { return r == ',' }) {
+			d, err := ParseWeekday(name)
+			if err != nil {
+				return Recurrence{}, err
+			}
+			if !slices.Contains(weekdays, d) {
+				weekdays = append(weekdays, d)
+			}
+		}
+		if len(weekdays) == 0 {
+			return Recurrence{}, fmt.Errorf("weekly needs at least one day")
+		}
+		slices.Sort(weekdays)
+		return Recurrence{Kind: RecurWeekly, Weekdays: weekdays}, nil
+	case fields[0] == RecurMonthly && len(fields) == 2:
+		n, err := strconv.Atoi(fields[1])
+		if err != nil || n < 1 || n > 31 {
+			return Recurrence{}, fmt.Errorf("invalid day of month: %s", fields[1])
+		}
+		return Recurrence{Kind: RecurMonthly, Day: n}, nil
+	case fields[len(fields)-1] == "done" || fields[len(fields)-1] == RecurAfter:
+		// "3 days after done", "3d after done", "3d after"
+		rest := fields[:len(fields)-1]
+		if len(rest) > 0 && rest[len(rest)-1] == RecurAfter {
+			rest = rest[:len(rest)-1]
+		}
+		if len(rest) == 2 && isDays(rest[1]) {
+			rest = rest[:1]
+		}
+		if len(rest) != 1 {
+			break
+		}
+		n, err := days(rest[0])
+		if err != nil {
+			return Recurrence{}, err
+		}
+		return Recurrence{Kind: RecurAfter, Days: n}, nil
+	}
+
+	return Recurrence{}, fmt.Errorf("unknown recurrence rule: %s", rule)
+}
+
+func isDays(s string) bool {
+	return s == "day" || s == "days"
+}
+
+// String returns the rule in the form accepted by ParseRecurrence
+func (r Recurrence) String() string {
+	plural := func(n int) string {
+		if n == 1 {
+			return "1 day"
+		}
+		return fmt.Sprintf("%d days", n)
+	}
+	switch r.Kind {
+	case RecurEvery:
+		return "every " + plural(r.Days)
+	case RecurWeekly:
+		names := make([]string, len(r.Weekdays))
+		for i, d := range r.Weekdays {
+			names[i] = strings.ToLower(d.String()[:3])
+		}
+		return "weekly " + strings.Join(names, ",")
+	case RecurMonthly:
+		return fmt.Sprintf("monthly %d", r.Day)
+	case RecurAfter:
+		return plural(r.Days) + " after done"
+	}
+	return r.Kind
+}
+
+// Next returns the due date of the occurrence after a task that was due at
+// due (nil if it had no due date) is done at now. Like all due dates it is
+// the midnight that ends the due day. Occurrences are never scheduled
+// before tomorrow.
+func (r Recurrence) Next(cal Calendar, due *time.Time, now time.Time) time.Time {
+	today := Midnight(now.In(cal.Location))
+
+	// the day the task was last due, or today if it was not scheduled
+	last := today
+	if due != nil {
+		last = Midnight(due.In(cal.Location).Add(-time.Nanosecond))
+	}
+
+	var day time.Time
+	switch r.Kind {
+	case RecurAfter:
+		day = today.AddDate(0, 0, r.Days)
+	case RecurEvery:
+		day = last.AddDate(0, 0, r.Days)
+		for !day.After(today) {
+			day = day.AddDate(0, 0, r.Days)
+		}
+	default:
+		// the first matching day after both the last due day and today
+		day = last
+		if today.After(day) {
+			day = today
+		}
+		day = day.AddDate(0, 0, 1)
+		for !r.matches(day) {
+			day = day.AddDate(0, 0, 1)
+		}
+	}
+
+	return day.AddDate(0, 0, 1)
+}
+
+// matches reports whether a task recurs on day
+func (r Recurrence) matches(day time.Time) bool {
+	switch r.Kind {
+	case RecurWeekdays:
+		return day.Weekday() != time.Saturday && day.Weekday() != time.Sunday
+	case RecurWeekly:
+		return slices.Contains(r.Weekdays, day.Weekday())
+	case RecurMonthly:
+		// months without day N recur on their last day
+		lastDay := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, day.Location()).Day()
+		return day.Day() == min(r.Day, lastDay)
+	}
+	return true
+}
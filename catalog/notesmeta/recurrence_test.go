@@ -0,0 +1,126 @@
+package notesmeta
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRecurrence(t *testing.T) {
+	tests := []struct {
+		rule   string
+		want   Recurrence
+		String string
+	}{
+		{"daily", Recurrence{Kind: RecurDaily}, "daily"},
+		{"Daily", Recurrence{Kind: RecurDaily}, "daily"},
+		{"weekdays", Recurrence{Kind: RecurWeekdays}, "weekdays"},
+		{"every 3 days", Recurrence{Kind: RecurEvery, Days: 3}, "every 3 days"},
+		{"every 1 day", Recurrence{Kind: RecurEvery, Days: 1}, "every 1 day"},
+		{"every 10d", Recurrence{Kind: RecurEvery, Days: 10}, "every 10 days"},
+		{"weekly mon,thu", Recurrence{Kind: RecurWeekly, Weekdays: []time.Weekday{time.Monday, time.Thursday}}, "weekly mon,thu"},
+		{"weekly thursday, monday", Recurrence{Kind: RecurWeekly, Weekdays: []time.Weekday{time.Monday, time.Thursday}}, "weekly mon,thu"},
+		{"weekly sun sat sun", Recurrence{Kind: RecurWeekly, Weekdays: []time.Weekday{time.Sunday, time.Saturday}}, "weekly sun,sat"},
+		{"monthly 15", Recurrence{Kind: RecurMonthly, Day: 15}, "monthly 15"},
+		{"monthly 31", Recurrence{Kind: RecurMonthly, Day: 31}, "monthly 31"},
+		{"3 days after done", Recurrence{Kind: RecurAfter, Days: 3}, "3 days after done"},
+		{"2 days after", Recurrence{Kind: RecurAfter, Days: 2}, "2 days after done"},
+		{"3d after done", Recurrence{Kind: RecurAfter, Days: 3}, "3 days after done"},
+		{"1 day after done", Recurrence{Kind: RecurAfter, Days: 1}, "1 day after done"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			got, err := ParseRecurrence(tt.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			if got.String() != tt.String {
+				t.Errorf("String() = %q, want %q", got.String(), tt.String)
+			}
+			again, err := ParseRecurrence(got.String())
+			if err != nil || !reflect.DeepEqual(again, got) {
+				t.Errorf("String() does not parse back: %+v, %v", again, err)
+			}
+		})
+	}
+}
+
+func TestParseRecurrenceErrors(t *testing.T) {
+	for _, rule := range []string{
+		"",
+		"hourly",
+		"daily please",
+		"every",
+		"every 0 days",
+		"every -2 days",
+		"every three days",
+		"every 3 weeks",
+		"weekly",
+		"weekly someday",
+		"monthly",
+		"monthly 0",
+		"monthly 32",
+		"after done",
+		"0 days after done",
+		"3 weeks after done",
+	} {
+		t.Run(rule, func(t *testing.T) {
+			got, err := ParseRecurrence(rule)
+			if err == nil {
+				t.Errorf("got %+v, want error", got)
+			}
+		})
+	}
+}
+
+func TestRecurrenceNext(t *testing.T) {
+	vancouver, err := time.LoadLocation("America/Vancouver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cal := Calendar{Location: vancouver}
+	day := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, vancouver)
+	}
+	due := func(year int, month time.Month, d int) *time.Time {
+		t := day(year, month, d)
+		return &t
+	}
+	// a friday afternoon, two days before clocks go forward
+	now := time.Date(2026, time.March, 6, 15, 0, 0, 0, vancouver)
+
+	tests := []struct {
+		name string
+		rule string
+		due  *time.Time
+		want time.Time
+	}{
+		{"daily is tomorrow", "daily", due(2026, time.March, 7), day(2026, time.March, 8)},
+		{"daily without a due date", "daily", nil, day(2026, time.March, 8)},
+		{"daily done late", "daily", due(2026, time.March, 2), day(2026, time.March, 8)},
+		{"daily done early", "daily", due(2026, time.March, 11), day(2026, time.March, 12)},
+		{"weekdays skip the weekend", "weekdays", due(2026, time.March, 7), day(2026, time.March, 10)},
+		{"weekly", "weekly mon,thu", due(2026, time.March, 6), day(2026, time.March, 10)},
+		{"every keeps its rhythm", "every 3 days", due(2026, time.March, 3), day(2026, time.March, 9)},
+		{"every skips missed occurrences", "every 2 days", due(2026, time.February, 27), day(2026, time.March, 9)},
+		{"after counts from today", "3 days after done", due(2026, time.February, 1), day(2026, time.March, 10)},
+		{"monthly", "monthly 15", due(2026, time.March, 7), day(2026, time.March, 16)},
+		{"monthly on a short month", "monthly 31", due(2026, time.April, 1), day(2026, time.May, 1)},
+		{"due at a time of day", "daily", &now, day(2026, time.March, 8)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := ParseRecurrence(tt.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := r.Next(cal, tt.due, now)
+			if !got.Equal(tt.want) {
+				t.Errorf("got %s, want %s", got.In(vancouver), tt.want)
+			}
+		})
+	}
+}
@@ -1,13 +1,10 @@
 package cli
 
-import (
-	"github.com/pkg/browser"
-	"github.com/rcy/whatever/app"
-)
+import "github.com/pkg/browser"
 
 type BugCmd struct {
 }
 
-func (c *BugCmd) Run(app *app.App) error {
+func (c *BugCmd) Run() error {
 	return browser.OpenURL("https://github.com/rcy/whatever/issues/new")
 }
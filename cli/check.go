@@ -0,0 +1,27 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/rcy/whatever/app"
+)
+
+type CheckCmd struct{}
+
+func (c *CheckCmd) Run(app *app.App) error {
+	missing, extra, err := app.CheckNotes()
+	if err != nil {
+		return err
+	}
+	for _, row := range missing {
+		fmt.Println("missing", row)
+	}
+	for _, row := range extra {
+		fmt.Println("extra", row)
+	}
+	if len(missing)+len(extra) > 0 {
+		return fmt.Errorf("note projection is out of sync, delete NOTES_FILE to rebuild it")
+	}
+	fmt.Println("ok")
+	return nil
+}
@@ -2,44 +2,39 @@ package cli
 
 import (
 	"fmt"
-	"os"
 
-	"github.com/rcy/whatever/app"
-	"github.com/rcy/whatever/commands"
+	"github.com/rcy/whatever/api"
 	"github.com/rcy/whatever/workers/classify"
 )
 
 type ClassifyCmd struct{}
 
-func (c *ClassifyCmd) Run(a *app.App) error {
-	ownerID := os.Getenv("OWNER_ID")
-
-	notes, err := a.Notes.FindAllByCategory(ownerID, "inbox")
+func (c *ClassifyCmd) Run(client *api.Client) error {
+	classifications, err := client.Classify()
 	if err != nil {
 		return err
 	}
 
-	if len(notes) == 0 {
-		fmt.Println("inbox is empty")
+	if len(classifications) == 0 {
+		fmt.Println("nothing to classify")
 		return nil
 	}
 
-	for _, note := range notes {
-		category, err := classify.Categorize(note.Text)
-		if err != nil {
-			return err
+	for _, cl := range classifications {
+		result := classify.Result{
+			Category:    cl.Category,
+			Subcategory: cl.Subcategory,
+			Timeframe:   cl.Timeframe,
+			Confidence:  cl.Confidence,
 		}
-
-		err = a.Commander.Send(commands.SetNoteCategory{
-			NoteID:   note.ID,
-			Category: category,
-			Actor:    "ai",
-		})
-		if err != nil {
-			return err
+		switch {
+		case cl.Filed:
+			fmt.Printf("%q → %s/%s\n", cl.Note.Text, cl.Note.Category, cl.Note.Subcategory)
+		case result.Confident():
+			fmt.Printf("%q unchanged, classified as %s\n", cl.Note.Text, result)
+		default:
+			fmt.Printf("%q ? %s\n", cl.Note.Text, result)
 		}
-
-		fmt.Printf("%q → %s\n", note.Text, category)
 	}
 	return nil
 }
@@ -1,10 +1,19 @@
 package cli
 
 var CLI struct {
-	Version VersionCmd `cmd:"" help:"show the build version"`
-	Notes   NotesCmd   `cmd:""`
-	//Events  events.Cmd `cmd:"" help:"events commands"`
-	Ddate DDateCmd `cmd:"" help:"show current discordian date"`
-	Serve ServeCmd `cmd:"" help:"start a webserver"`
-	Bug   BugCmd   `cmd:"" help:"report a bug"`
+	Remote string `placeholder:"URL" env:"WHATEVER_REMOTE" help:"talk to the whatever server at URL instead of the local event file"`
+
+	Version  VersionCmd  `cmd:"" help:"show the build version"`
+	Login    LoginCmd    `cmd:"" help:"store an api token for a remote server"`
+	Notes    NotesCmd    `cmd:""`
+	People   PeopleCmd   `cmd:"" help:"show and edit people mentioned in notes"`
+	Events   EventsCmd   `cmd:"" help:"show events in the log"`
+	Settings SettingsCmd `cmd:"" help:"show or change your preferences"`
+	Timezone TimezoneCmd `cmd:"" help:"show or set the time zone used for due dates"`
+	Ddate    DDateCmd    `cmd:"" help:"show current discordian date"`
+	Serve    ServeCmd    `cmd:"" help:"start a webserver"`
+	Jobs     JobsCmd     `cmd:"" help:"show background jobs that have not completed"`
+	Enrich   EnrichCmd   `cmd:"" help:"look up the links in a note again"`
+	Check    CheckCmd    `cmd:"" help:"check the note projection against a fresh replay of the event log"`
+	Bug      BugCmd      `cmd:"" help:"report a bug"`
 }
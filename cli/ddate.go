@@ -5,13 +5,12 @@ import (
 	"time"
 
 	"github.com/rcy/disco"
-	"github.com/rcy/whatever/app"
 )
 
 type DDateCmd struct {
 }
 
-func (c *DDateCmd) Run(app *app.App) error {
+func (c *DDateCmd) Run() error {
 	fmt.Println(disco.NowIn(time.Local).Format(true))
 	return nil
 }
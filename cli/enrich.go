@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/rcy/whatever/api"
+)
+
+type EnrichCmd struct {
+	ID     string `arg:"" optional:"" help:"note to look up the links of"`
+	URL    string `arg:"" optional:"" help:"only look up this link"`
+	Failed bool   `help:"look up every link that failed, in all notes"`
+}
+
+func (c *EnrichCmd) Run(client *api.Client) error {
+	if c.Failed {
+		if c.ID != "" {
+			return fmt.Errorf("--failed does not take a note")
+		}
+		return enrichFailed(client)
+	}
+	if c.ID == "" {
+		return fmt.Errorf("give a note or --failed")
+	}
+	note, err := client.EnrichNote(c.ID, c.URL)
+	if err != nil {
+		return err
+	}
+	fmt.Println(note.ID.String()[0:7], "enriching", note.DisplayText)
+	return nil
+}
+
+// enrichFailed requests enrichment of the links that failed in all notes,
+// leaving the links that worked alone
+func enrichFailed(client *api.Client) error {
+	noteList, err := client.ListNotes(url.Values{"enrichment": {"failed"}})
+	if err != nil {
+		return err
+	}
+	for _, n := range noteList {
+		// the list leaves out links, get them from the note
+		note, err := client.GetNote(n.ID.String())
+		if err != nil {
+			return err
+		}
+		for _, link := range note.Links {
+			if link.Status != "failure" {
+				continue
+			}
+			_, err := client.EnrichNote(note.ID.String(), link.URL)
+			if err != nil {
+				return fmt.Errorf("%s: %w", link.URL, err)
+			}
+			fmt.Println(note.ID.String()[0:7], "enriching", link.URL)
+		}
+	}
+	if len(noteList) == 0 {
+		fmt.Println("no failed links")
+	}
+	return nil
+}
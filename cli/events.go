@@ -0,0 +1,139 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rcy/evoke"
+	"github.com/rcy/whatever/app"
+	"github.com/rcy/whatever/eventlog"
+)
+
+const followInterval = time.Second
+
+type EventsCmd struct {
+	Aggregate string `short:"a" help:"only events of aggregates whose id starts with this prefix"`
+	Type      string `short:"t" help:"only events of this type"`
+	Since     string `help:"only events recorded at or after this date, time or duration ago"`
+	Until     string `help:"only events recorded before this date, time or duration ago"`
+	Tail      int    `short:"n" help:"only show the last n matching events"`
+	Format    string `enum:"table,jsonl" default:"table" help:"table or jsonl"`
+	Follow    bool   `short:"f" help:"keep printing events as they are recorded"`
+}
+
+type eventLine struct {
+	Sequence    int64       `json:"sequence"`
+	RecordedAt  time.Time   `json:"recordedAt"`
+	AggregateID uuid.UUID   `json:"aggregateId"`
+	Type        string      `json:"type"`
+	Event       evoke.Event `json:"event"`
+}
+
+func (c *EventsCmd) Run(app *app.App) error {
+	now := time.Now()
+	filter := eventlog.Filter{
+		AggregatePrefix: c.Aggregate,
+		Type:            c.Type,
+	}
+	var err error
+	if c.Since != "" {
+		filter.Since, err = eventlog.ParseTime(c.Since, now)
+		if err != nil {
+			return err
+		}
+	}
+	if c.Until != "" {
+		filter.Until, err = eventlog.ParseTime(c.Until, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	recs, err := app.EventDebugger.DebugEvents()
+	if err != nil {
+		return err
+	}
+	lastSequence := int64(0)
+	if len(recs) > 0 {
+		lastSequence = recs[len(recs)-1].Sequence
+	}
+	recs = filter.Apply(recs)
+	if c.Tail > 0 && len(recs) > c.Tail {
+		recs = recs[len(recs)-c.Tail:]
+	}
+	err = c.print(recs)
+	if err != nil {
+		return err
+	}
+
+	if !c.Follow {
+		return nil
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(followInterval):
+		}
+
+		// other processes append to the event file, so poll it
+		recs, err := app.EventDebugger.DebugEvents()
+		if err != nil {
+			return err
+		}
+		if len(recs) == 0 || recs[len(recs)-1].Sequence == lastSequence {
+			continue
+		}
+		filter.AfterSequence = lastSequence
+		lastSequence = recs[len(recs)-1].Sequence
+
+		err = c.print(filter.Apply(recs))
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (c *EventsCmd) print(recs []evoke.RecordedEvent) error {
+	if c.Format == "jsonl" {
+		enc := json.NewEncoder(os.Stdout)
+		for _, rec := range recs {
+			err := enc.Encode(eventLine{
+				Sequence:    rec.Sequence,
+				RecordedAt:  time.Unix(rec.RecordedAt, 0).UTC(),
+				AggregateID: rec.AggregateID,
+				Type:        rec.EventType,
+				Event:       rec.Event,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	for _, rec := range recs {
+		data, err := json.Marshal(rec.Event)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n",
+			rec.Sequence,
+			rec.AggregateID.String()[0:5],
+			rec.EventType,
+			time.Unix(rec.RecordedAt, 0).UTC().Format(time.RFC3339),
+			data)
+	}
+	return tw.Flush()
+}
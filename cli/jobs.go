@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rcy/whatever/app"
+	"github.com/rcy/whatever/jobs"
+)
+
+type JobsCmd struct {
+	Failed bool `help:"Only show jobs that ran out of retries"`
+}
+
+func (c *JobsCmd) Run(app *app.App) error {
+	var jobList []jobs.Job
+	var err error
+	if c.Failed {
+		jobList, err = app.Jobs.FindAllFailed()
+	} else {
+		jobList, err = app.Jobs.FindAll()
+	}
+	if err != nil {
+		return err
+	}
+	for _, job := range jobList {
+		fmt.Printf("%d %s %s %d/%d %s %s\n", job.ID, job.Kind, job.State, job.Attempts, job.MaxAttempts, time.Unix(job.RunAt, 0).Local().Format(time.DateTime), job.LastError)
+	}
+	return nil
+}
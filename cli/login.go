@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/browser"
+	"github.com/rcy/whatever/api"
+	"github.com/rcy/whatever/app"
+	"github.com/rcy/whatever/web"
+)
+
+type LoginCmd struct {
+	URL   string `arg:"" optional:"" help:"server to log in to, defaults to --remote"`
+	Token string `help:"api token created on the server's settings page, prompted for if not given"`
+}
+
+func (c *LoginCmd) Run() error {
+	url := strings.TrimRight(c.URL, "/")
+	if url == "" {
+		url = strings.TrimRight(CLI.Remote, "/")
+	}
+	if url == "" {
+		return errors.New("no server url given")
+	}
+
+	token := c.Token
+	if token == "" {
+		settingsURL := url + "/settings"
+		fmt.Printf("Create an api token at %s and paste it here: ", settingsURL)
+		_ = browser.OpenURL(settingsURL)
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("read token: %w", err)
+		}
+		token = strings.TrimSpace(line)
+	}
+
+	me, err := api.NewClient(url, token).Me()
+	if err != nil {
+		return fmt.Errorf("check token: %w", err)
+	}
+
+	err = saveToken(url, token)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("logged in to %s as %s\n", url, me.ID)
+	return nil
+}
+
+// NewClient returns an api client for the server at remote, or for the local
+// app as OWNER_ID when remote is empty
+func NewClient(remote string, loadApp func() (*app.App, error)) (*api.Client, error) {
+	if remote == "" {
+		a, err := loadApp()
+		if err != nil {
+			return nil, err
+		}
+		return api.NewLocalClient(web.API(a, os.Getenv("OWNER_ID"))), nil
+	}
+
+	remote = strings.TrimRight(remote, "/")
+	token, ok := os.LookupEnv("WHATEVER_TOKEN")
+	if !ok {
+		tokens, err := loadTokens()
+		if err != nil {
+			return nil, err
+		}
+		token, ok = tokens[remote]
+		if !ok {
+			return nil, fmt.Errorf("no token for %s, run whatever login %s", remote, remote)
+		}
+	}
+	return api.NewClient(remote, token), nil
+}
+
+// tokensFile holds api tokens by server url
+func tokensFile() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "whatever", "tokens.json"), nil
+}
+
+func loadTokens() (map[string]string, error) {
+	filename, err := tokensFile()
+	if err != nil {
+		return nil, err
+	}
+	tokens := map[string]string{}
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return tokens, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, &tokens)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return tokens, nil
+}
+
+func saveToken(url string, token string) error {
+	tokens, err := loadTokens()
+	if err != nil {
+		return err
+	}
+	tokens[url] = token
+
+	filename, err := tokensFile()
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(filename), 0o700)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0o600)
+}
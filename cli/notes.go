@@ -1,15 +1,15 @@
 package cli
 
 import (
+	"cmp"
 	"fmt"
-	"os"
+	"html"
+	"net/url"
+	"slices"
 	"strings"
 	"time"
 
-	"github.com/google/uuid"
-	"github.com/rcy/whatever/app"
-	"github.com/rcy/whatever/commands"
-	"github.com/rcy/whatever/projections/note"
+	"github.com/rcy/whatever/api"
 )
 
 type NotesCmd struct {
@@ -20,26 +20,44 @@ type NotesCmd struct {
 	Undelete UndeleteCmd `cmd:""`
 	Edit     EditCmd     `cmd:""`
 	Classify ClassifyCmd `cmd:"" help:"classify inbox items"`
+	Do       DoCmd       `cmd:"" help:"schedule, complete or otherwise transition a note"`
+	Due      DueCmd      `cmd:"" help:"set the date a task is due"`
+	Star     StarCmd     `cmd:""`
+	Unstar   UnstarCmd   `cmd:""`
+	Refile   RefileCmd   `cmd:"" help:"move a note to another category"`
+	Repeat   RepeatCmd   `cmd:"" help:"make a task recur when it is done"`
+	Finished FinishedCmd `cmd:"" help:"list tasks finished this week"`
+	History  HistoryCmd  `cmd:"" help:"show when a task was completed, deferred and reopened"`
+	Tag      TagCmd      `cmd:"" help:"add tags to a note"`
+	Untag    UntagCmd    `cmd:"" help:"remove tags from a note"`
+	Tags     TagsCmd     `cmd:"" help:"list tags and how many notes have them"`
+	Search   SearchCmd   `cmd:"" help:"search notes, including deleted ones"`
 }
 
 type ListCmd struct {
-	Deleted bool `help:"Show deleted notes"`
+	Deleted bool   `help:"Show deleted notes"`
+	Tag     string `help:"Show notes with a tag"`
 }
 
-func (c *ListCmd) Run(app *app.App) error {
-	ownerID := os.Getenv("OWNER_ID")
-	var noteList []note.Note
+func (c *ListCmd) Run(client *api.Client) error {
+	var noteList []api.Note
 	var err error
 	if c.Deleted {
-		noteList, err = app.Notes.FindAllDeleted(ownerID)
+		noteList, err = client.ListDeletedNotes()
 	} else {
-		noteList, err = app.Notes.FindAll(ownerID)
+		var filter url.Values
+		if c.Tag != "" {
+			filter = url.Values{"tag": {strings.TrimPrefix(c.Tag, "#")}}
+		}
+		noteList, err = client.ListNotes(filter)
 	}
 	if err != nil {
 		return err
 	}
+	// the api lists newest first
+	slices.Reverse(noteList)
 	for _, note := range noteList {
-		fmt.Printf("%s %s %s\n", note.ID, note.Category, note.Text)
+		fmt.Printf("%s %s %s\n", note.ID, note.Category, note.DisplayText)
 	}
 	return nil
 }
@@ -48,12 +66,36 @@ type ShowCmd struct {
 	ID string `arg:""`
 }
 
-func (c *ShowCmd) Run(app *app.App) error {
-	note, err := app.Notes.FindOne(c.ID)
+func (c *ShowCmd) Run(client *api.Client) error {
+	note, err := client.GetNote(c.ID)
 	if err != nil {
 		return err
 	}
-	fmt.Printf("%s %s %s\n", note.ID[0:7], time.Unix(note.Ts, 0).Local().Format(time.DateTime), note.Text)
+	fmt.Printf("%s %s %s\n", note.ID.String()[0:7], note.CreatedAt.Local().Format(time.DateTime), note.Text)
+	if len(note.Tags) > 0 {
+		fmt.Println("#" + strings.Join(note.Tags, " #"))
+	}
+	for _, link := range note.Links {
+		fmt.Println(link.URL)
+		switch link.Status {
+		case "enriching":
+			fmt.Println("  enriching...")
+		case "failure":
+			fmt.Println("  enrichment failed:", cmp.Or(link.Error, "unknown error"))
+		default:
+			line := link.Title
+			if link.SiteName != "" {
+				line += " · " + link.SiteName
+			}
+			fmt.Println("  " + line)
+			if link.Description != "" {
+				fmt.Println("  " + link.Description)
+			}
+			if link.ArchivedAt != nil {
+				fmt.Println("  archived", link.ArchivedAt.Local().Format(time.DateTime))
+			}
+		}
+	}
 	return nil
 }
 
@@ -61,51 +103,114 @@ type AddCmd struct {
 	Text []string `arg:""`
 }
 
-func (c *AddCmd) Run(app *app.App) error {
-	ownerID := os.Getenv("OWNER_ID")
-	noteID := uuid.New()
-	err := app.Commander.Send(commands.CreateNote{
-		NoteID:      noteID,
-		Owner:       ownerID,
-		Text:        strings.Join(c.Text, " "),
-		Category:    "inbox",
-		Subcategory: "default",
-	})
+func (c *AddCmd) Run(client *api.Client) error {
+	note, err := client.CreateNote(strings.Join(c.Text, " "), "", "")
 	if err != nil {
 		return err
 	}
-	fmt.Println(noteID)
+	fmt.Println(note.ID)
 	return nil
 }
 
 type EditCmd struct {
-	NoteID uuid.UUID `arg:""`
-	Text   []string  `arg:""`
+	ID   string   `arg:""`
+	Text []string `arg:""`
 }
 
-func (c *EditCmd) Run(app *app.App) error {
-	return app.Commander.Send(commands.UpdateNoteText{
-		NoteID: c.NoteID,
-		Text:   strings.Join(c.Text, " "),
-	})
+func (c *EditCmd) Run(client *api.Client) error {
+	_, err := client.EditNote(c.ID, strings.Join(c.Text, " "))
+	return err
 }
 
 type DeleteCmd struct {
-	ID uuid.UUID `arg:""`
+	ID string `arg:""`
 }
 
-func (c *DeleteCmd) Run(app *app.App) error {
-	return app.Commander.Send(commands.DeleteNote{
-		NoteID: c.ID,
-	})
+func (c *DeleteCmd) Run(client *api.Client) error {
+	_, err := client.DeleteNote(c.ID)
+	return err
 }
 
 type UndeleteCmd struct {
-	ID uuid.UUID `arg:""`
+	ID string `arg:""`
 }
 
-func (c *UndeleteCmd) Run(app *app.App) error {
-	return app.Commander.Send(commands.UndeleteNote{
-		NoteID: c.ID,
-	})
+func (c *UndeleteCmd) Run(client *api.Client) error {
+	_, err := client.UndeleteNote(c.ID)
+	return err
+}
+
+type TagCmd struct {
+	ID   string   `arg:""`
+	Tags []string `arg:""`
+}
+
+func (c *TagCmd) Run(client *api.Client) error {
+	var note api.Note
+	var err error
+	for _, tag := range c.Tags {
+		note, err = client.TagNote(c.ID, strings.TrimPrefix(tag, "#"))
+		if err != nil {
+			return err
+		}
+	}
+	printNote(client, note)
+	return nil
+}
+
+type UntagCmd struct {
+	ID   string   `arg:""`
+	Tags []string `arg:""`
+}
+
+func (c *UntagCmd) Run(client *api.Client) error {
+	var note api.Note
+	var err error
+	for _, tag := range c.Tags {
+		note, err = client.UntagNote(c.ID, strings.TrimPrefix(tag, "#"))
+		if err != nil {
+			return err
+		}
+	}
+	printNote(client, note)
+	return nil
 }
+
+type TagsCmd struct{}
+
+func (c *TagsCmd) Run(client *api.Client) error {
+	tags, err := client.Tags()
+	if err != nil {
+		return err
+	}
+	for _, t := range tags {
+		fmt.Printf("%4d #%s\n", t.Count, t.Tag)
+	}
+	return nil
+}
+
+type SearchCmd struct {
+	Query []string `arg:"" help:"words, \"phrases\", pre*, -word, cat:task, sub:today, tag:work, due:<7d, is:starred, is:deleted"`
+}
+
+func (c *SearchCmd) Run(client *api.Client) error {
+	results, err := client.Search(strings.Join(c.Query, " "))
+	if err != nil {
+		return err
+	}
+	for _, res := range results {
+		text := res.Note.DisplayText
+		if res.Snippet != "" {
+			text = html.UnescapeString(searchHighlights.Replace(res.Snippet))
+		}
+		deleted := ""
+		if res.Note.Deleted {
+			deleted = " (deleted)"
+		}
+		fmt.Printf("%s %s/%s%s %s\n", res.Note.ID.String()[0:7], res.Note.Category, res.Note.Subcategory, deleted, strings.ReplaceAll(text, "\n", " "))
+	}
+	return nil
+}
+
+// searchHighlights puts the matching words of a search snippet in brackets
+var searchHighlights = strings.NewReplacer("<mark>", "[", "</mark>", "]")
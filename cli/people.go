@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/rcy/whatever/api"
+)
+
+type PeopleCmd struct {
+	List    PeopleListCmd    `cmd:"" default:"withargs" aliases:"ls" help:"list people mentioned in notes"`
+	Show    PeopleShowCmd    `cmd:"" help:"show a person and the notes mentioning them"`
+	Rename  PeopleRenameCmd  `cmd:"" help:"set the name shown for a person"`
+	Note    PeopleNoteCmd    `cmd:"" help:"set contact notes about a person"`
+	Merge   PeopleMergeCmd   `cmd:"" help:"make a handle an alias of another person"`
+	Unmerge PeopleUnmergeCmd `cmd:"" help:"split an alias back into its own person"`
+}
+
+type PeopleListCmd struct{}
+
+func (c *PeopleListCmd) Run(client *api.Client) error {
+	people, err := client.People()
+	if err != nil {
+		return err
+	}
+	loc, err := userLocation(client)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, p := range people {
+		last := "never"
+		if p.LastMentioned != nil {
+			last = p.LastMentioned.In(loc).Format("2006-01-02")
+		}
+		fmt.Fprintf(tw, "@%s\t%s\t%d notes\t%d open\t%s\n", p.Handle, p.DisplayName, p.Mentions, p.OpenTasks, last)
+	}
+	return tw.Flush()
+}
+
+type PeopleShowCmd struct {
+	Handle string `arg:""`
+}
+
+func (c *PeopleShowCmd) Run(client *api.Client) error {
+	p, err := client.GetPerson(strings.TrimPrefix(c.Handle, "@"))
+	if err != nil {
+		return err
+	}
+	printPerson(p)
+
+	noteList, err := client.ListNotes(url.Values{"person": {p.Handle}})
+	if err != nil {
+		return err
+	}
+	// the api lists newest first
+	slices.Reverse(noteList)
+	for _, note := range noteList {
+		printNote(client, note)
+	}
+	return nil
+}
+
+type PeopleRenameCmd struct {
+	Handle string   `arg:""`
+	Name   []string `arg:"" optional:"" help:"leave out to show the handle"`
+}
+
+func (c *PeopleRenameCmd) Run(client *api.Client) error {
+	name := strings.Join(c.Name, " ")
+	p, err := client.UpdatePerson(strings.TrimPrefix(c.Handle, "@"), &name, nil)
+	if err != nil {
+		return err
+	}
+	printPerson(p)
+	return nil
+}
+
+type PeopleNoteCmd struct {
+	Handle string   `arg:""`
+	Notes  []string `arg:"" optional:"" help:"leave out to clear the notes"`
+}
+
+func (c *PeopleNoteCmd) Run(client *api.Client) error {
+	notes := strings.Join(c.Notes, " ")
+	p, err := client.UpdatePerson(strings.TrimPrefix(c.Handle, "@"), nil, &notes)
+	if err != nil {
+		return err
+	}
+	printPerson(p)
+	return nil
+}
+
+type PeopleMergeCmd struct {
+	Handle string `arg:"" help:"handle to become an alias"`
+	Into   string `arg:"" help:"handle of the person to merge into"`
+}
+
+func (c *PeopleMergeCmd) Run(client *api.Client) error {
+	p, err := client.MergePerson(strings.TrimPrefix(c.Handle, "@"), strings.TrimPrefix(c.Into, "@"))
+	if err != nil {
+		return err
+	}
+	printPerson(p)
+	return nil
+}
+
+type PeopleUnmergeCmd struct {
+	Handle string `arg:""`
+}
+
+func (c *PeopleUnmergeCmd) Run(client *api.Client) error {
+	p, err := client.UnmergePerson(strings.TrimPrefix(c.Handle, "@"))
+	if err != nil {
+		return err
+	}
+	printPerson(p)
+	return nil
+}
+
+func printPerson(p api.Person) {
+	line := "@" + p.Handle
+	if p.DisplayName != "" {
+		line = p.DisplayName + " " + line
+	}
+	for _, alias := range p.Aliases {
+		line += " @" + alias
+	}
+	fmt.Println(line)
+	if p.Notes != "" {
+		fmt.Println(p.Notes)
+	}
+	fmt.Printf("%d notes, %d open tasks\n", p.Mentions, p.OpenTasks)
+}
@@ -37,6 +37,9 @@ func (c *ServeCmd) Run(app *app.App) error {
 		return err
 	}
 
+	// run jobs in the background while serving
+	app.Start()
+
 	srv := http.Server{
 		Addr:    ":" + c.Port,
 		Handler: mux,
@@ -57,7 +60,8 @@ func (c *ServeCmd) Run(app *app.App) error {
 
 	fmt.Println("Shutting down server...")
 
-	shutdownCtx, _ := context.WithTimeout(context.Background(), 5*time.Second)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 	err = srv.Shutdown(shutdownCtx)
 	if err != nil {
 		return err
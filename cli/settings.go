@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/rcy/whatever/api"
+)
+
+type SettingsCmd struct {
+	DisplayName *string `help:"name shown in the web app"`
+	TimeZone    *string `help:"IANA time zone name, eg America/Vancouver"`
+	WeekStart   *string `help:"day the week starts on, eg monday"`
+	Capture     *string `help:"category new notes are captured into" enum:"inbox,task,reference"`
+	Classifier  *bool   `help:"classify new notes automatically" negatable:""`
+}
+
+func (c *SettingsCmd) Run(client *api.Client) error {
+	prefs := api.Preferences{
+		DisplayName:       c.DisplayName,
+		TimeZone:          c.TimeZone,
+		WeekStart:         c.WeekStart,
+		CaptureCategory:   c.Capture,
+		ClassifierEnabled: c.Classifier,
+	}
+
+	var me api.Me
+	var err error
+	if prefs == (api.Preferences{}) {
+		me, err = client.Me()
+	} else {
+		me, err = client.UpdatePreferences(prefs)
+	}
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "display name\t%s\n", me.DisplayName)
+	fmt.Fprintf(tw, "email\t%s\n", me.Email)
+	fmt.Fprintf(tw, "time zone\t%s\n", me.TimeZone)
+	fmt.Fprintf(tw, "week start\t%s\n", me.WeekStart)
+	fmt.Fprintf(tw, "capture\t%s\n", me.CaptureCategory)
+	fmt.Fprintf(tw, "classifier\t%v\n", me.ClassifierEnabled)
+	return tw.Flush()
+}
@@ -0,0 +1,300 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rcy/whatever/api"
+	"github.com/rcy/whatever/catalog/notesmeta"
+	"github.com/rcy/whatever/eventlog"
+)
+
+type DoCmd struct {
+	ID     string `arg:""`
+	Action string `arg:"" help:"today, tomorrow, thisweek, nextweek, thismonth, nextmonth, someday, done, or any other transition of the note"`
+}
+
+func (c *DoCmd) Run(client *api.Client) error {
+	note, err := client.GetNote(c.ID)
+	if err != nil {
+		return err
+	}
+
+	// timeframes are named by slug on the command line
+	event := c.Action
+	if ok, tf := notesmeta.TimeframeLookup(c.Action); ok {
+		event = tf.EventName
+	}
+
+	path, err := transitionPath(note, event)
+	if err != nil {
+		return fmt.Errorf("%s: %w", c.Action, err)
+	}
+	for _, event := range path {
+		note, err = client.TransitionNote(note.ID.String(), event)
+		if err != nil {
+			return err
+		}
+	}
+	printNote(client, note)
+	return nil
+}
+
+type DueCmd struct {
+	ID    string   `arg:""`
+	When  []string `arg:"" optional:"" help:"due date such as 2026-12-01, tomorrow, next tue, in 3 days or dec 1, optionally followed by a time such as 3pm"`
+	Clear bool     `help:"remove the due date"`
+}
+
+func (c *DueCmd) Run(client *api.Client) error {
+	if c.Clear == (len(c.When) > 0) {
+		return errors.New("give either a due date or --clear")
+	}
+
+	note, err := client.GetNote(c.ID)
+	if err != nil {
+		return err
+	}
+	if note.Category != notesmeta.Task.Slug {
+		return fmt.Errorf("only tasks have due dates, refile the note as a task first")
+	}
+
+	if c.Clear {
+		note, err = client.ClearNoteDue(note.ID.String())
+		if err != nil {
+			return err
+		}
+		printNote(client, note)
+		return nil
+	}
+
+	me, err := client.Me()
+	if err != nil {
+		return err
+	}
+	cal, err := me.Calendar()
+	if err != nil {
+		return err
+	}
+	due, err := notesmeta.ParseDue(strings.Join(c.When, " "), cal, cal.Now())
+	if err != nil {
+		return err
+	}
+
+	note, err = client.SetNoteDue(note.ID.String(), due)
+	if err != nil {
+		return err
+	}
+	printNote(client, note)
+	return nil
+}
+
+type StarCmd struct {
+	ID string `arg:""`
+}
+
+func (c *StarCmd) Run(client *api.Client) error {
+	note, err := client.StarNote(c.ID)
+	if err != nil {
+		return err
+	}
+	printNote(client, note)
+	return nil
+}
+
+type UnstarCmd struct {
+	ID string `arg:""`
+}
+
+func (c *UnstarCmd) Run(client *api.Client) error {
+	note, err := client.UnstarNote(c.ID)
+	if err != nil {
+		return err
+	}
+	printNote(client, note)
+	return nil
+}
+
+type RefileCmd struct {
+	ID       string `arg:""`
+	Category string `arg:"" enum:"inbox,task,reference" help:"inbox, task or reference"`
+}
+
+func (c *RefileCmd) Run(client *api.Client) error {
+	note, err := client.SetNoteCategory(c.ID, c.Category)
+	if err != nil {
+		return err
+	}
+	printNote(client, note)
+	return nil
+}
+
+// transitionPath returns the transition events that take note to event.
+// Scheduled, someday and done tasks are rescheduled first when event is only
+// available to unscheduled tasks.
+func transitionPath(note api.Note, event string) ([]string, error) {
+	if note.Category == notesmeta.Inbox.Slug {
+		return nil, fmt.Errorf("note is in the inbox, refile it first")
+	}
+	category := notesmeta.Categories.Get(note.Category)
+	subcategory := category.Subcategories.Get(note.Subcategory)
+
+	if ok, _ := subcategory.Transitions.Get(event); ok {
+		return []string{event}, nil
+	}
+	if ok, reschedule := subcategory.Transitions.Get("reschedule"); ok {
+		target := category.Subcategories.Get(reschedule.TargetSlug)
+		if ok, _ := target.Transitions.Get(event); ok {
+			return []string{"reschedule", event}, nil
+		}
+	}
+
+	var available []string
+	for _, t := range subcategory.Transitions {
+		name := t.Event
+		for _, tf := range notesmeta.TimeframeList {
+			if tf.EventName == t.Event {
+				name = tf.Slug
+			}
+		}
+		available = append(available, name)
+	}
+	slices.Sort(available)
+	return nil, fmt.Errorf("not available for a note in %s/%s, expected one of: %s", note.Category, note.Subcategory, strings.Join(available, ", "))
+}
+
+// userLocation returns the time zone of the user the client acts for
+func userLocation(client *api.Client) (*time.Location, error) {
+	me, err := client.Me()
+	if err != nil {
+		return nil, err
+	}
+	return me.Location()
+}
+
+// printNote prints the state of a note on one line, with due dates shown
+// in the user's time zone
+func printNote(client *api.Client, note api.Note) {
+
+	parts := []string{note.ID.String()[0:7], note.Category + "/" + note.Subcategory}
+	if note.Due != nil {
+		loc, err := userLocation(client)
+		if err != nil {
+			loc = time.Local
+		}
+		parts = append(parts, "due "+notesmeta.FormatDue(*note.Due, loc))
+	}
+	if note.Recurrence != "" {
+		parts = append(parts, "↻ "+note.Recurrence)
+	}
+	if note.Starred {
+		parts = append(parts, "*")
+	}
+	if note.Deleted {
+		parts = append(parts, "(deleted)")
+	}
+	parts = append(parts, note.DisplayText)
+	fmt.Println(strings.Join(parts, " "))
+}
+
+type RepeatCmd struct {
+	ID    string   `arg:""`
+	Rule  []string `arg:"" optional:"" help:"daily, weekdays, every N days, weekly mon,thu, monthly N, or N days after done"`
+	Clear bool     `help:"stop the task from recurring"`
+}
+
+func (c *RepeatCmd) Run(client *api.Client) error {
+	if c.Clear == (len(c.Rule) > 0) {
+		return errors.New("give either a rule or --clear")
+	}
+
+	var note api.Note
+	var err error
+	if c.Clear {
+		note, err = client.ClearNoteRecurrence(c.ID)
+	} else {
+		note, err = client.SetNoteRecurrence(c.ID, strings.Join(c.Rule, " "))
+	}
+	if err != nil {
+		return err
+	}
+	printNote(client, note)
+	return nil
+}
+
+type FinishedCmd struct {
+	Since string `help:"show tasks finished since a date (YYYY-MM-DD), or a duration ago (7d, 12h); defaults to the start of this week"`
+}
+
+func (c *FinishedCmd) Run(client *api.Client) error {
+	me, err := client.Me()
+	if err != nil {
+		return err
+	}
+	cal, err := me.Calendar()
+	if err != nil {
+		return err
+	}
+
+	now := cal.Now()
+	since := cal.StartOfWeek(now)
+	if c.Since != "" {
+		since, err = eventlog.ParseTime(c.Since, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	noteList, err := client.ListNotes(url.Values{"completedAfter": {since.Format(time.RFC3339)}})
+	if err != nil {
+		return err
+	}
+	slices.SortFunc(noteList, func(a, b api.Note) int { return a.CompletedAt.Compare(*b.CompletedAt) })
+	for _, note := range noteList {
+		fmt.Printf("%s %s %s\n", note.CompletedAt.In(cal.Location).Format("Mon Jan 2"), note.ID.String()[0:7], note.DisplayText)
+	}
+	return nil
+}
+
+type HistoryCmd struct {
+	ID string `arg:""`
+}
+
+func (c *HistoryCmd) Run(client *api.Client) error {
+	note, err := client.GetNote(c.ID)
+	if err != nil {
+		return err
+	}
+	history, err := client.NoteHistory(note.ID.String())
+	if err != nil {
+		return err
+	}
+	loc, err := userLocation(client)
+	if err != nil {
+		return err
+	}
+
+	printNote(client, note)
+	day := func(t *time.Time) string {
+		if t == nil {
+			return "someday"
+		}
+		return notesmeta.FormatDue(*t, loc)
+	}
+	for _, e := range history {
+		line := e.At.In(loc).Format(time.DateTime) + " " + e.Kind
+		switch e.Kind {
+		case "deferred":
+			line += " " + day(e.From) + " → " + day(e.To)
+		case "recurred":
+			line += " → " + day(e.To)
+		}
+		fmt.Println(line)
+	}
+	fmt.Printf("deferred %d times, reopened %d times\n", note.DeferredCount, note.ReopenedCount)
+	return nil
+}
@@ -0,0 +1,26 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/rcy/whatever/api"
+)
+
+type TimezoneCmd struct {
+	Zone string `arg:"" optional:"" help:"IANA time zone name, eg America/Vancouver"`
+}
+
+func (c *TimezoneCmd) Run(client *api.Client) error {
+	var me api.Me
+	var err error
+	if c.Zone == "" {
+		me, err = client.Me()
+	} else {
+		me, err = client.SetTimeZone(c.Zone)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println(me.TimeZone)
+	return nil
+}
@@ -2,14 +2,14 @@ package cli
 
 import (
 	"fmt"
+	"os"
 
-	"github.com/rcy/whatever/app"
 	"github.com/rcy/whatever/version"
 )
 
 type VersionCmd struct{}
 
-func (c *VersionCmd) Run(app *app.App) error {
-	fmt.Printf("version=%s isRelease=%v dbFile=%s\n", version.Version(), version.IsRelease())
+func (c *VersionCmd) Run() error {
+	fmt.Printf("version=%s isRelease=%v dbFile=%s\n", version.Version(), version.IsRelease(), os.Getenv("EVOKE_FILE"))
 	return nil
 }
@@ -59,10 +59,21 @@ func (c ClassifyNote) AggregateID() uuid.UUID { return c.NoteID }
 type TransitionNoteSubcategory struct {
 	NoteID          uuid.UUID
 	TransitionEvent string
+	Actor           string // "user" or "ai"
 }
 
 func (c TransitionNoteSubcategory) AggregateID() uuid.UUID { return c.NoteID }
 
+type SuggestNoteClassification struct {
+	NoteID      uuid.UUID
+	Category    string
+	Subcategory string
+	Timeframe   string
+	Confidence  float64
+}
+
+func (c SuggestNoteClassification) AggregateID() uuid.UUID { return c.NoteID }
+
 type SetNoteDue struct {
 	NoteID uuid.UUID
 	Due    time.Time
@@ -77,22 +88,85 @@ type ClearNoteDue struct {
 
 func (c ClearNoteDue) AggregateID() uuid.UUID { return c.NoteID }
 
+type AddNoteTag struct {
+	NoteID uuid.UUID
+	Tag    string
+}
+
+func (c AddNoteTag) AggregateID() uuid.UUID { return c.NoteID }
+
+type RemoveNoteTag struct {
+	NoteID uuid.UUID
+	Tag    string
+}
+
+func (c RemoveNoteTag) AggregateID() uuid.UUID { return c.NoteID }
+
+// SetNoteRecurrence makes a task recur, see notesmeta.ParseRecurrence for
+// the rule syntax
+type SetNoteRecurrence struct {
+	NoteID uuid.UUID
+	Rule   string
+}
+
+func (c SetNoteRecurrence) AggregateID() uuid.UUID { return c.NoteID }
+
+type ClearNoteRecurrence struct {
+	NoteID uuid.UUID
+}
+
+func (c ClearNoteRecurrence) AggregateID() uuid.UUID { return c.NoteID }
+
 type CompleteNoteEnrichment struct {
-	NoteID      uuid.UUID
-	CompletedAt time.Time
-	Title       string
-	Thumb       string
+	NoteID       uuid.UUID
+	URL          string
+	CompletedAt  time.Time
+	Title        string
+	Description  string
+	SiteName     string
+	CanonicalURL string
+	Thumb        string
+	ContentType  string
 }
 
 func (c CompleteNoteEnrichment) AggregateID() uuid.UUID { return c.NoteID }
 
 type FailNoteEnrichment struct {
 	NoteID   uuid.UUID
+	URL      string
 	FailedAt time.Time
+	Reason   string
 }
 
 func (c FailNoteEnrichment) AggregateID() uuid.UUID { return c.NoteID }
 
+// RequestNoteEnrichment looks up the link URL in a note again, or all of
+// its links when URL is empty
+type RequestNoteEnrichment struct {
+	NoteID uuid.UUID
+	URL    string
+}
+
+func (c RequestNoteEnrichment) AggregateID() uuid.UUID { return c.NoteID }
+
+type CompleteNoteArchive struct {
+	NoteID     uuid.UUID
+	URL        string
+	Hash       string
+	ArchivedAt time.Time
+}
+
+func (c CompleteNoteArchive) AggregateID() uuid.UUID { return c.NoteID }
+
+type FailNoteArchive struct {
+	NoteID   uuid.UUID
+	URL      string
+	FailedAt time.Time
+	Reason   string
+}
+
+func (c FailNoteArchive) AggregateID() uuid.UUID { return c.NoteID }
+
 type StarNote struct {
 	NoteID uuid.UUID
 }
@@ -104,3 +178,121 @@ type UnstarNote struct {
 }
 
 func (c UnstarNote) AggregateID() uuid.UUID { return c.NoteID }
+
+type CreateAPIToken struct {
+	TokenID  uuid.UUID
+	Owner    string
+	Name     string
+	Hash     string
+	ReadOnly bool
+}
+
+func (c CreateAPIToken) AggregateID() uuid.UUID { return c.TokenID }
+
+type RevokeAPIToken struct {
+	TokenID uuid.UUID
+	Owner   string
+}
+
+func (c RevokeAPIToken) AggregateID() uuid.UUID { return c.TokenID }
+
+// userNamespace derives user aggregate ids from owner ids
+var userNamespace = uuid.MustParse("5b0f3c2e-8d6a-4e5f-9c1b-2a7d4e6f8091")
+
+// UserID returns the id of the user aggregate of owner
+func UserID(owner string) uuid.UUID {
+	return uuid.NewSHA1(userNamespace, []byte(owner))
+}
+
+type CreateUser struct {
+	UserID      uuid.UUID
+	Owner       string
+	DisplayName string
+	Email       string
+}
+
+func (c CreateUser) AggregateID() uuid.UUID { return c.UserID }
+
+type SetUserDisplayName struct {
+	UserID      uuid.UUID
+	Owner       string
+	DisplayName string
+}
+
+func (c SetUserDisplayName) AggregateID() uuid.UUID { return c.UserID }
+
+type SetUserTimeZone struct {
+	UserID   uuid.UUID
+	Owner    string
+	TimeZone string
+}
+
+func (c SetUserTimeZone) AggregateID() uuid.UUID { return c.UserID }
+
+type SetUserWeekStart struct {
+	UserID    uuid.UUID
+	Owner     string
+	WeekStart time.Weekday
+}
+
+func (c SetUserWeekStart) AggregateID() uuid.UUID { return c.UserID }
+
+type SetUserCaptureCategory struct {
+	UserID   uuid.UUID
+	Owner    string
+	Category string
+}
+
+func (c SetUserCaptureCategory) AggregateID() uuid.UUID { return c.UserID }
+
+type SetUserClassifierEnabled struct {
+	UserID  uuid.UUID
+	Owner   string
+	Enabled bool
+}
+
+func (c SetUserClassifierEnabled) AggregateID() uuid.UUID { return c.UserID }
+
+// personNamespace derives person aggregate ids from owner ids and handles
+var personNamespace = uuid.MustParse("a3c9e2d1-6f4b-4b8e-9d2a-7e1f0c5b3a64")
+
+// PersonID returns the id of the person aggregate for the @handle of owner
+func PersonID(owner string, handle string) uuid.UUID {
+	return uuid.NewSHA1(personNamespace, []byte(owner+"\x00"+handle))
+}
+
+type SetPersonDisplayName struct {
+	PersonID    uuid.UUID
+	Owner       string
+	Handle      string
+	DisplayName string
+}
+
+func (c SetPersonDisplayName) AggregateID() uuid.UUID { return c.PersonID }
+
+type SetPersonNotes struct {
+	PersonID uuid.UUID
+	Owner    string
+	Handle   string
+	Notes    string
+}
+
+func (c SetPersonNotes) AggregateID() uuid.UUID { return c.PersonID }
+
+// MergePerson makes Handle an alias of the person with handle Into
+type MergePerson struct {
+	PersonID uuid.UUID
+	Owner    string
+	Handle   string
+	Into     string
+}
+
+func (c MergePerson) AggregateID() uuid.UUID { return c.PersonID }
+
+type UnmergePerson struct {
+	PersonID uuid.UUID
+	Owner    string
+	Handle   string
+}
+
+func (c UnmergePerson) AggregateID() uuid.UUID { return c.PersonID }
@@ -0,0 +1,97 @@
+// Package eventlog filters the recorded events of the event store for
+// browsing and debugging.
+package eventlog
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rcy/evoke"
+)
+
+// Filter selects recorded events. Empty fields match everything.
+type Filter struct {
+	// AggregatePrefix matches the start of the aggregate id
+	AggregatePrefix string
+	// Type matches the event type, ignoring case
+	Type string
+	// Since and Until bound the time the event was recorded, Until is
+	// exclusive
+	Since time.Time
+	Until time.Time
+	// AfterSequence and BeforeSequence bound the sequence number, exclusive
+	AfterSequence  int64
+	BeforeSequence int64
+}
+
+func (f Filter) Match(rec evoke.RecordedEvent) bool {
+	if f.AggregatePrefix != "" && !strings.HasPrefix(rec.AggregateID.String(), strings.ToLower(f.AggregatePrefix)) {
+		return false
+	}
+	if f.Type != "" && !strings.EqualFold(rec.EventType, f.Type) {
+		return false
+	}
+	if !f.Since.IsZero() && rec.RecordedAt < f.Since.Unix() {
+		return false
+	}
+	if !f.Until.IsZero() && rec.RecordedAt >= f.Until.Unix() {
+		return false
+	}
+	if f.AfterSequence > 0 && rec.Sequence <= f.AfterSequence {
+		return false
+	}
+	if f.BeforeSequence > 0 && rec.Sequence >= f.BeforeSequence {
+		return false
+	}
+	return true
+}
+
+// Apply returns the events in recs that match the filter, in order
+func (f Filter) Apply(recs []evoke.RecordedEvent) []evoke.RecordedEvent {
+	var out []evoke.RecordedEvent
+	for _, rec := range recs {
+		if f.Match(rec) {
+			out = append(out, rec)
+		}
+	}
+	return out
+}
+
+// Types returns the distinct event types in recs in the order they first
+// appear
+func Types(recs []evoke.RecordedEvent) []string {
+	seen := map[string]bool{}
+	var types []string
+	for _, rec := range recs {
+		if !seen[rec.EventType] {
+			seen[rec.EventType] = true
+			types = append(types, rec.EventType)
+		}
+	}
+	return types
+}
+
+// ParseTime parses a time bound given as RFC3339, a date or date and time in
+// the local time zone, or a duration before now such as 90m or 2d
+func ParseTime(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{time.DateTime, "2006-01-02 15:04", time.DateOnly} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, expected a date, RFC3339 time or duration like 2h or 7d", s)
+}
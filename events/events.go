@@ -26,15 +26,23 @@ type NoteOwnerSet struct {
 	Owner  string
 }
 
+// NoteEnrichmentRequested asks for the link URL in a note to be looked
+// up. Events recorded before links were enriched one by one have no URL,
+// their Text is the whole note, starting with the link.
 type NoteEnrichmentRequested struct {
 	NoteID      uuid.UUID
 	RequestedAt time.Time
+	URL         string
 	Text        string
 }
 
+// NoteTextUpdated replaces the text of a note. Events recorded before
+// UpdatedAt was added may have the title of the link in the note saved in
+// front of the text, as older versions showed it there when editing.
 type NoteTextUpdated struct {
-	NoteID uuid.UUID
-	Text   string
+	NoteID    uuid.UUID
+	Text      string
+	UpdatedAt time.Time
 }
 
 type NoteDeleted struct {
@@ -55,6 +63,15 @@ type NoteCategoryChanged struct {
 type NoteSubcategoryChanged struct {
 	NoteID      uuid.UUID
 	Subcategory string
+	Actor       string // "user" or "ai"
+}
+
+type NoteClassificationSuggested struct {
+	NoteID      uuid.UUID
+	Category    string
+	Subcategory string
+	Timeframe   string
+	Confidence  float64
 }
 
 type NoteDueChanged struct {
@@ -67,24 +84,98 @@ type NoteDueCleared struct {
 }
 
 type NoteTaskCompleted struct {
-	NoteID uuid.UUID
+	NoteID      uuid.UUID
+	CompletedAt time.Time
 }
 
+// NoteTaskDeferred is emitted when a task's due date is pushed later or
+// cleared without the task being done
 type NoteTaskDeferred struct {
-	NoteID uuid.UUID
+	NoteID     uuid.UUID
+	DeferredAt time.Time
+	From       time.Time
+	To         *time.Time // nil when the task no longer has a due date
 }
 
 type NoteTaskReopened struct {
+	NoteID     uuid.UUID
+	ReopenedAt time.Time
+}
+
+// TagNoteAdded tags a note explicitly, as opposed to a #tag in its text
+type TagNoteAdded struct {
 	NoteID uuid.UUID
+	Tag    string
 }
 
-type NoteEnriched struct {
+type TagNoteRemoved struct {
+	NoteID uuid.UUID
+	Tag    string
+}
+
+type NoteRecurrenceSet struct {
 	NoteID uuid.UUID
-	Title  string
+	Rule   string
 }
 
+type NoteRecurrenceCleared struct {
+	NoteID uuid.UUID
+}
+
+// NoteTaskRecurred is emitted after a recurring task is completed and
+// scheduled again for its next occurrence
+type NoteTaskRecurred struct {
+	NoteID     uuid.UUID
+	RecurredAt time.Time
+	Due        time.Time
+}
+
+// NoteEnriched records what was found at the link URL in a note. Events
+// recorded before the other fields were added only have a Title, and are
+// about the first link in the note.
+type NoteEnriched struct {
+	NoteID       uuid.UUID
+	URL          string
+	Title        string
+	Description  string
+	SiteName     string
+	CanonicalURL string
+	Thumbnail    string // url of an image
+	ContentType  string // eg article or video
+}
+
+// NoteEnrichmentFailed records that the link URL in a note could not be
+// looked up, and why. Events without a URL are about the first link in the
+// note.
 type NoteEnrichmentFailed struct {
 	NoteID uuid.UUID
+	URL    string
+	Reason string
+}
+
+// NoteArchiveRequested asks for a readable copy of the page at the link
+// URL in a note to be saved
+type NoteArchiveRequested struct {
+	NoteID      uuid.UUID
+	URL         string
+	RequestedAt time.Time
+}
+
+// NoteArchived records that the readable text of the page at the link URL
+// in a note was saved in the blob store under Hash
+type NoteArchived struct {
+	NoteID     uuid.UUID
+	URL        string
+	Hash       string
+	ArchivedAt time.Time
+}
+
+// NoteArchiveFailed records that the page at the link URL in a note could
+// not be saved, and why
+type NoteArchiveFailed struct {
+	NoteID uuid.UUID
+	URL    string
+	Reason string
 }
 
 type NoteStarred struct {
@@ -94,3 +185,89 @@ type NoteStarred struct {
 type NoteUnstarred struct {
 	NoteID uuid.UUID
 }
+
+type APITokenCreated struct {
+	TokenID   uuid.UUID
+	Owner     string
+	Name      string
+	Hash      string // sha256 of the token, the token itself is never stored
+	ReadOnly  bool
+	CreatedAt time.Time
+}
+
+type APITokenRevoked struct {
+	TokenID   uuid.UUID
+	RevokedAt time.Time
+}
+
+type UserCreated struct {
+	UserID      uuid.UUID
+	Owner       string
+	DisplayName string
+	Email       string
+	CreatedAt   time.Time
+}
+
+type UserDisplayNameSet struct {
+	UserID      uuid.UUID
+	Owner       string
+	DisplayName string
+}
+
+type UserTimeZoneSet struct {
+	UserID   uuid.UUID
+	Owner    string
+	TimeZone string // IANA name, eg America/Vancouver
+}
+
+type UserWeekStartSet struct {
+	UserID    uuid.UUID
+	Owner     string
+	WeekStart time.Weekday
+}
+
+// UserCaptureCategorySet records the category new notes are filed in when
+// none is given
+type UserCaptureCategorySet struct {
+	UserID   uuid.UUID
+	Owner    string
+	Category string
+}
+
+type UserClassifierEnabledSet struct {
+	UserID  uuid.UUID
+	Owner   string
+	Enabled bool
+}
+
+// People are keyed by the handle they are @mentioned with, see
+// commands.PersonID. Their events never touch the notes mentioning them.
+
+type PersonDisplayNameSet struct {
+	PersonID    uuid.UUID
+	Owner       string
+	Handle      string
+	DisplayName string // empty to show the handle
+}
+
+// PersonNotesSet records free form contact notes about a person
+type PersonNotesSet struct {
+	PersonID uuid.UUID
+	Owner    string
+	Handle   string
+	Notes    string
+}
+
+// PersonMerged makes Handle an alias of the person with handle Into
+type PersonMerged struct {
+	PersonID uuid.UUID
+	Owner    string
+	Handle   string
+	Into     string
+}
+
+type PersonUnmerged struct {
+	PersonID uuid.UUID
+	Owner    string
+	Handle   string
+}
@@ -0,0 +1,467 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	StatePending   = "pending"
+	StateRunning   = "running"
+	StateCompleted = "completed"
+	StateFailed    = "failed"
+)
+
+const (
+	maxAttempts  = 5
+	baseBackoff  = 2 * time.Second
+	maxBackoff   = time.Hour
+	lease        = 5 * time.Minute
+	pollInterval = 5 * time.Second
+	runners      = 4
+)
+
+type Job struct {
+	ID          int64  `db:"id"`
+	Kind        string `db:"kind"`
+	Event       *int64 `db:"event"` // sequence of the event the job was enqueued for
+	Payload     string `db:"payload"`
+	State       string `db:"state"`
+	Attempts    int    `db:"attempts"`
+	MaxAttempts int    `db:"max_attempts"`
+	RunAt       int64  `db:"run_at"`
+	LockedUntil int64  `db:"locked_until"`
+	LastError   string `db:"last_error"`
+	CreatedAt   int64  `db:"created_at"`
+	UpdatedAt   int64  `db:"updated_at"`
+}
+
+// LastAttempt reports whether a failure of the current attempt is terminal
+func (j Job) LastAttempt() bool {
+	return j.Attempts >= j.MaxAttempts
+}
+
+// Unmarshal decodes the job payload into v
+func (j Job) Unmarshal(v any) error {
+	return json.Unmarshal([]byte(j.Payload), v)
+}
+
+type Handler func(ctx context.Context, job Job) error
+
+// Queue is a durable job queue stored in sqlite. Jobs that fail are retried
+// with exponential backoff until they run out of attempts, and jobs left
+// running by a process that died are picked up again once their lease
+// expires.
+type Queue struct {
+	db       *sqlx.DB
+	mu       sync.RWMutex
+	handlers map[string]Handler
+	wake     chan struct{}
+	cancel   context.CancelFunc
+	stopped  sync.WaitGroup
+
+	// running counts jobs in progress in this process
+	activeMu sync.Mutex
+	idle     *sync.Cond
+	running  int
+
+	// enqueued holds the ids of jobs enqueued by this process that have not
+	// finished, see Drain
+	enqueued map[int64]bool
+}
+
+func Open(filename string) (*Queue, error) {
+	db, err := sqlx.Open("sqlite", filename)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`PRAGMA journal_mode = WAL`)
+	if err != nil {
+		return nil, fmt.Errorf("enable WAL: %w", err)
+	}
+	_, err = db.Exec(`PRAGMA busy_timeout = 5000`)
+	if err != nil {
+		return nil, fmt.Errorf("set busy_timeout: %w", err)
+	}
+
+	_, err = db.Exec(`create table if not exists jobs(
+		id integer primary key autoincrement,
+		kind text not null,
+		event integer,
+		payload text not null,
+		state text not null,
+		attempts integer not null default 0,
+		max_attempts integer not null,
+		run_at integer not null,
+		locked_until integer not null default 0,
+		last_error text not null default '',
+		created_at integer not null,
+		updated_at integer not null
+	) strict`)
+	if err != nil {
+		return nil, fmt.Errorf("create table jobs: %w", err)
+	}
+	_, err = db.Exec(`create index if not exists jobs_state_run_at on jobs(state, run_at)`)
+	if err != nil {
+		return nil, fmt.Errorf("create index jobs_state_run_at: %w", err)
+	}
+	// a job is only enqueued once for each event
+	_, err = db.Exec(`create unique index if not exists jobs_kind_event on jobs(kind, event)`)
+	if err != nil {
+		return nil, fmt.Errorf("create index jobs_kind_event: %w", err)
+	}
+	// the last event jobs have been enqueued for, see Checkpoint
+	_, err = db.Exec(`create table if not exists checkpoint(position integer not null) strict`)
+	if err != nil {
+		return nil, fmt.Errorf("create table checkpoint: %w", err)
+	}
+
+	q := &Queue{
+		db:       db,
+		handlers: make(map[string]Handler),
+		wake:     make(chan struct{}, 1),
+		enqueued: make(map[int64]bool),
+	}
+	q.idle = sync.NewCond(&q.activeMu)
+
+	return q, nil
+}
+
+// Register sets the handler that runs jobs of kind
+func (q *Queue) Register(kind string, handler Handler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, exists := q.handlers[kind]; exists {
+		panic("handler already registered: " + kind)
+	}
+	q.handlers[kind] = handler
+}
+
+// Enqueue stores a job of kind for the event with sequence number event, to
+// be run as soon as possible. The payload is marshalled to json. Enqueuing
+// the same kind of job for an event again does nothing.
+func (q *Queue) Enqueue(kind string, event int64, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("Marshal: %w", err)
+	}
+	now := time.Now().Unix()
+	var id int64
+	err = q.db.Get(&id, `insert into jobs(kind, event, payload, state, max_attempts, run_at, created_at, updated_at) values(?,?,?,?,?,?,?,?) on conflict(kind, event) do nothing returning id`,
+		kind, event, string(data), StatePending, maxAttempts, now, now, now)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("insert into jobs: %w", err)
+	}
+
+	q.mu.Lock()
+	q.enqueued[id] = true
+	q.mu.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// Position returns the sequence number of the last event jobs have been
+// enqueued for, and false if the queue has never been checkpointed
+func (q *Queue) Position() (int64, bool, error) {
+	var position int64
+	err := q.db.Get(&position, `select position from checkpoint`)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("select checkpoint: %w", err)
+	}
+	return position, true, nil
+}
+
+// Checkpoint records that jobs have been enqueued for all events up to and
+// including sequence
+func (q *Queue) Checkpoint(sequence int64) error {
+	tx, err := q.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`delete from checkpoint`)
+	if err != nil {
+		return fmt.Errorf("delete checkpoint: %w", err)
+	}
+	_, err = tx.Exec(`insert into checkpoint(position) values(?)`, sequence)
+	if err != nil {
+		return fmt.Errorf("insert checkpoint: %w", err)
+	}
+	return tx.Commit()
+}
+
+// Start runs jobs in the background until Stop is called
+func (q *Queue) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	q.cancel = cancel
+	for range runners {
+		q.stopped.Add(1)
+		go func() {
+			defer q.stopped.Done()
+			q.loop(ctx)
+		}()
+	}
+}
+
+// Stop stops the background runners and waits for running jobs to finish
+func (q *Queue) Stop() {
+	if q.cancel != nil {
+		q.cancel()
+	}
+	q.stopped.Wait()
+}
+
+// Drain runs the jobs enqueued by this process that are due until none are
+// left, and waits for jobs already running in this process. Jobs waiting on
+// a retry, and jobs left over from earlier runs, are left in the queue for
+// whichever process runs next.
+func (q *Queue) Drain(ctx context.Context) error {
+	for {
+		for {
+			ran, err := q.runNext(ctx, true)
+			if err != nil {
+				return err
+			}
+			if !ran {
+				break
+			}
+		}
+		q.waitIdle()
+
+		ran, err := q.runNext(ctx, true)
+		if err != nil {
+			return err
+		}
+		if !ran {
+			return nil
+		}
+	}
+}
+
+// waitIdle blocks until no jobs are running in this process
+func (q *Queue) waitIdle() {
+	q.activeMu.Lock()
+	defer q.activeMu.Unlock()
+	for q.running > 0 {
+		q.idle.Wait()
+	}
+}
+
+func (q *Queue) loop(ctx context.Context) {
+	// let jobs that are already running finish when the queue is stopped
+	jobCtx := context.WithoutCancel(ctx)
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+		ran, err := q.runNext(jobCtx, false)
+		if err != nil {
+			log.Println("jobs:", err)
+		}
+		if ran {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.wake:
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// runNext claims and runs one job that is due, only one enqueued by this
+// process if own is set. It returns false if there was nothing to run.
+func (q *Queue) runNext(ctx context.Context, own bool) (bool, error) {
+	if ctx.Err() != nil {
+		return false, nil
+	}
+
+	q.activeMu.Lock()
+	q.running++
+	q.activeMu.Unlock()
+	defer func() {
+		q.activeMu.Lock()
+		q.running--
+		q.idle.Broadcast()
+		q.activeMu.Unlock()
+	}()
+
+	job, ok, err := q.claim(own)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	q.mu.RLock()
+	handler, ok := q.handlers[job.Kind]
+	q.mu.RUnlock()
+
+	if !ok {
+		err = fmt.Errorf("no handler registered for %q", job.Kind)
+	} else {
+		jobCtx, cancel := context.WithTimeout(ctx, lease)
+		err = runHandler(jobCtx, handler, job)
+		cancel()
+	}
+
+	if err != nil {
+		return true, q.fail(job, err)
+	}
+	return true, q.complete(job)
+}
+
+func runHandler(ctx context.Context, handler Handler, job Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// claim marks the next due job as running and returns it, only one
+// enqueued by this process if own is set
+func (q *Queue) claim(own bool) (Job, bool, error) {
+	now := time.Now().Unix()
+	query := `
+		update jobs set state = ?, attempts = attempts + 1, locked_until = ?, updated_at = ?
+		where id = (
+			select id from jobs
+			where ((state = ? and run_at <= ?) or (state = ? and locked_until <= ?))%s
+			order by run_at asc limit 1
+		)
+		returning *`
+	args := []any{StateRunning, now + int64(lease.Seconds()), now, StatePending, now, StateRunning, now}
+	if own {
+		q.mu.RLock()
+		ids := make([]int64, 0, len(q.enqueued))
+		for id := range q.enqueued {
+			ids = append(ids, id)
+		}
+		q.mu.RUnlock()
+		if len(ids) == 0 {
+			return Job{}, false, nil
+		}
+		// claimed in batches so the ids stay under the sqlite variable limit
+		for len(ids) > 0 {
+			batch := ids[:min(len(ids), maxClaimIDs)]
+			ids = ids[len(batch):]
+			batchQuery, batchArgs, err := sqlx.In(fmt.Sprintf(query, " and id in (?)"), append(args, batch)...)
+			if err != nil {
+				return Job{}, false, fmt.Errorf("claim job: %w", err)
+			}
+			job, ok, err := q.claimQuery(batchQuery, batchArgs)
+			if err != nil || ok {
+				return job, ok, err
+			}
+		}
+		return Job{}, false, nil
+	}
+	return q.claimQuery(fmt.Sprintf(query, ""), args)
+}
+
+// maxClaimIDs is how many job ids claim binds at once
+const maxClaimIDs = 500
+
+func (q *Queue) claimQuery(query string, args []any) (Job, bool, error) {
+	var jobList []Job
+	err := q.db.Select(&jobList, query, args...)
+	if err != nil {
+		return Job{}, false, fmt.Errorf("claim job: %w", err)
+	}
+	if len(jobList) == 0 {
+		return Job{}, false, nil
+	}
+	return jobList[0], true, nil
+}
+
+// finished forgets a job of this process that will not run again
+func (q *Queue) finished(job Job) {
+	q.mu.Lock()
+	delete(q.enqueued, job.ID)
+	q.mu.Unlock()
+}
+
+func (q *Queue) complete(job Job) error {
+	_, err := q.db.Exec(`update jobs set state = ?, locked_until = 0, last_error = '', updated_at = ? where id = ?`,
+		StateCompleted, time.Now().Unix(), job.ID)
+	if err != nil {
+		return fmt.Errorf("complete job %d: %w", job.ID, err)
+	}
+	q.finished(job)
+	return nil
+}
+
+func (q *Queue) fail(job Job, jobErr error) error {
+	now := time.Now()
+	state := StatePending
+	if job.LastAttempt() {
+		state = StateFailed
+	}
+	runAt := now.Add(backoff(job.Attempts)).Unix()
+
+	_, err := q.db.Exec(`update jobs set state = ?, run_at = ?, locked_until = 0, last_error = ?, updated_at = ? where id = ?`,
+		state, runAt, jobErr.Error(), now.Unix(), job.ID)
+	if err != nil {
+		return fmt.Errorf("fail job %d: %w", job.ID, err)
+	}
+	if state == StateFailed {
+		q.finished(job)
+	}
+
+	log.Printf("jobs: %s job %d attempt %d/%d failed: %s", job.Kind, job.ID, job.Attempts, job.MaxAttempts, jobErr)
+	return nil
+}
+
+// backoff returns how long to wait before retrying after attempt
+func backoff(attempt int) time.Duration {
+	d := baseBackoff
+	for i := 1; i < attempt && d < maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxBackoff)
+}
+
+// FindAll returns jobs that have not completed
+func (q *Queue) FindAll() ([]Job, error) {
+	var jobList []Job
+	err := q.db.Select(&jobList, `select * from jobs where state != ? order by id asc`, StateCompleted)
+	if err != nil {
+		return nil, fmt.Errorf("select jobs: %w", err)
+	}
+	return jobList, nil
+}
+
+// FindAllFailed returns jobs that ran out of attempts
+func (q *Queue) FindAllFailed() ([]Job, error) {
+	var jobList []Job
+	err := q.db.Select(&jobList, `select * from jobs where state = ? order by id asc`, StateFailed)
+	if err != nil {
+		return nil, fmt.Errorf("select failed jobs: %w", err)
+	}
+	return jobList, nil
+}
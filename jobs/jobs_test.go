@@ -0,0 +1,314 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestQueue(t *testing.T, filename string) *Queue {
+	t.Helper()
+	q, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { q.db.Close() })
+	return q
+}
+
+func testFile(t *testing.T) string {
+	return filepath.Join(t.TempDir(), "jobs.db")
+}
+
+// findJob returns the job with id
+func findJob(t *testing.T, q *Queue, id int64) Job {
+	t.Helper()
+	var job Job
+	err := q.db.Get(&job, `select * from jobs where id = ?`, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+// makeDue makes every job due now, as if its backoff or lease ran out
+func makeDue(t *testing.T, q *Queue) {
+	t.Helper()
+	_, err := q.db.Exec(`update jobs set run_at = 0, locked_until = 0`)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDrainRunsJobs(t *testing.T) {
+	q := openTestQueue(t, testFile(t))
+	var got []string
+	q.Register("test", func(ctx context.Context, job Job) error {
+		var payload string
+		err := job.Unmarshal(&payload)
+		got = append(got, payload)
+		return err
+	})
+
+	for i, payload := range []string{"one", "two"} {
+		err := q.Enqueue("test", int64(i+1), payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := q.Drain(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 2 || got[0] != "one" || got[1] != "two" {
+		t.Errorf("ran %v, want [one two]", got)
+	}
+	pending, err := q.FindAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("%d jobs left, want none", len(pending))
+	}
+	if len(q.enqueued) != 0 {
+		t.Errorf("%d finished jobs still tracked", len(q.enqueued))
+	}
+}
+
+func TestEnqueueOncePerEvent(t *testing.T) {
+	q := openTestQueue(t, testFile(t))
+	for range 2 {
+		err := q.Enqueue("test", 1, "payload")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := q.Enqueue("other", 1, "payload")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jobList, err := q.FindAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobList) != 2 {
+		t.Errorf("got %d jobs, want 2", len(jobList))
+	}
+}
+
+func TestDrainLeavesJobsOfOtherProcesses(t *testing.T) {
+	filename := testFile(t)
+	earlier := openTestQueue(t, filename)
+	err := earlier.Enqueue("test", 1, "leftover")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := openTestQueue(t, filename)
+	var got []string
+	q.Register("test", func(ctx context.Context, job Job) error {
+		var payload string
+		err := job.Unmarshal(&payload)
+		got = append(got, payload)
+		return err
+	})
+	err = q.Enqueue("test", 2, "own")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = q.Drain(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 1 || got[0] != "own" {
+		t.Errorf("ran %v, want [own]", got)
+	}
+	pending, err := q.FindAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].State != StatePending {
+		t.Errorf("got %+v, want the leftover job pending", pending)
+	}
+}
+
+func TestRetryWithBackoff(t *testing.T) {
+	q := openTestQueue(t, testFile(t))
+	runs := 0
+	q.Register("test", func(ctx context.Context, job Job) error {
+		runs++
+		if job.LastAttempt() {
+			return nil
+		}
+		return errors.New("try again")
+	})
+	err := q.Enqueue("test", 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a failed job waits for its backoff instead of blocking Drain
+	start := time.Now().Unix()
+	err = q.Drain(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := findJob(t, q, 1)
+	if job.State != StatePending || job.Attempts != 1 || job.LastError != "try again" {
+		t.Errorf("got %+v, want pending after 1 failed attempt", job)
+	}
+	if job.RunAt < start+int64(baseBackoff.Seconds()) {
+		t.Errorf("run_at %d, want after the backoff from %d", job.RunAt, start)
+	}
+
+	for range maxAttempts - 1 {
+		makeDue(t, q)
+		err = q.Drain(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	job = findJob(t, q, 1)
+	if job.State != StateCompleted || runs != maxAttempts {
+		t.Errorf("got %+v after %d runs, want completed on the last attempt", job, runs)
+	}
+}
+
+func TestFailAfterLastAttempt(t *testing.T) {
+	q := openTestQueue(t, testFile(t))
+	q.Register("test", func(ctx context.Context, job Job) error {
+		panic("boom")
+	})
+	err := q.Enqueue("test", 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range maxAttempts + 1 {
+		makeDue(t, q)
+		err = q.Drain(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	failed, err := q.FindAllFailed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failed) != 1 || failed[0].Attempts != maxAttempts || failed[0].LastError != "panic: boom" {
+		t.Errorf("got %+v, want one job failed after %d attempts", failed, maxAttempts)
+	}
+	if len(q.enqueued) != 0 {
+		t.Errorf("%d failed jobs still tracked", len(q.enqueued))
+	}
+}
+
+func TestExpiredLeaseIsClaimedAgain(t *testing.T) {
+	q := openTestQueue(t, testFile(t))
+	err := q.Enqueue("test", 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job, ok, err := q.claim(true)
+	if err != nil || !ok {
+		t.Fatalf("claim: %v %v", ok, err)
+	}
+	// the job is running, nobody else can claim it
+	_, ok, err = q.claim(false)
+	if err != nil || ok {
+		t.Fatalf("claimed a running job: %v %v", ok, err)
+	}
+
+	// the process running it died and its lease ran out
+	makeDue(t, q)
+	again, ok, err := q.claim(false)
+	if err != nil || !ok {
+		t.Fatalf("claim: %v %v", ok, err)
+	}
+	if again.ID != job.ID || again.Attempts != 2 {
+		t.Errorf("got %+v, want job %d on its second attempt", again, job.ID)
+	}
+}
+
+func TestUnknownKindFails(t *testing.T) {
+	q := openTestQueue(t, testFile(t))
+	err := q.Enqueue("unknown", 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = q.Drain(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := findJob(t, q, 1)
+	if job.State != StatePending || job.LastError == "" {
+		t.Errorf("got %+v, want a failed attempt", job)
+	}
+}
+
+func TestStartRunsJobs(t *testing.T) {
+	q := openTestQueue(t, testFile(t))
+	done := make(chan int64, 1)
+	q.Register("test", func(ctx context.Context, job Job) error {
+		done <- job.ID
+		return nil
+	})
+	q.Start()
+	defer q.Stop()
+
+	err := q.Enqueue("test", 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("job did not run")
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	filename := testFile(t)
+	q := openTestQueue(t, filename)
+	_, ok, err := q.Position()
+	if err != nil || ok {
+		t.Fatalf("new queue has a position: %v %v", ok, err)
+	}
+	for _, seq := range []int64{10, 7} {
+		err = q.Checkpoint(seq)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reopened := openTestQueue(t, filename)
+	position, ok, err := reopened.Position()
+	if err != nil || !ok || position != 7 {
+		t.Errorf("got %d %v %v, want 7", position, ok, err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 2 * time.Second},
+		{2, 4 * time.Second},
+		{5, 32 * time.Second},
+		{12, time.Hour},
+		{100, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := backoff(tt.attempt); got != tt.want {
+			t.Errorf("backoff(%d) = %s, want %s", tt.attempt, got, tt.want)
+		}
+	}
+}
@@ -1,28 +1,101 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 	_ "time/tzdata"
 
 	"github.com/alecthomas/kong"
 	"github.com/joho/godotenv"
+	"github.com/rcy/whatever/api"
 	"github.com/rcy/whatever/app"
 	"github.com/rcy/whatever/cli"
+	"github.com/rcy/whatever/workers/classify"
 )
 
 func main() {
 	_ = godotenv.Load()
+
+	// the app is only opened for commands that need it, so commands talking
+	// to a remote server never touch the local event file
+	var a *app.App
+	loadApp := func() (*app.App, error) {
+		if cli.CLI.Remote != "" {
+			return nil, errors.New("command not available with --remote")
+		}
+		if a != nil {
+			return a, nil
+		}
+		var err error
+		a, err = newApp()
+		return a, err
+	}
+
+	kctx := kong.Parse(&cli.CLI,
+		kong.BindSingletonProvider(loadApp),
+		kong.BindSingletonProvider(func() (*api.Client, error) {
+			return cli.NewClient(cli.CLI.Remote, loadApp)
+		}),
+	)
+	err := kctx.Run()
+	kctx.FatalIfErrorf(err)
+
+	if a == nil {
+		return
+	}
+
+	// finish background work started by the command
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	err = a.Close(ctx)
+	kctx.FatalIfErrorf(err)
+}
+
+func newApp() (*app.App, error) {
 	filename, ok := os.LookupEnv("EVOKE_FILE")
 	if !ok {
-		log.Fatal("EVOKE_FILE not set")
+		return nil, errors.New("EVOKE_FILE not set")
+	}
+	// everything derived from the event log lives next to it unless set,
+	// set NOTES_FILE or USERS_FILE empty to keep a projection in memory
+	base := strings.TrimSuffix(filename, filepath.Ext(filename))
+	notesFile, ok := os.LookupEnv("NOTES_FILE")
+	if !ok {
+		notesFile = base + "_notes.db"
+	}
+	usersFile, ok := os.LookupEnv("USERS_FILE")
+	if !ok {
+		usersFile = base + "_users.db"
 	}
-	a, err := app.New(filename)
+	jobsFile, ok := os.LookupEnv("JOBS_FILE")
+	if !ok {
+		jobsFile = base + "_jobs.db"
+	}
+	blobsDir, ok := os.LookupEnv("BLOBS_DIR")
+	if !ok {
+		blobsDir = base + "_blobs"
+	}
+	classifierKind, ok := os.LookupEnv("CLASSIFIER")
+	if !ok {
+		classifierKind = "rules"
+		if os.Getenv("OPENAI_API_KEY") != "" {
+			classifierKind = "openai"
+		}
+	}
+	classifier, err := classify.New(classifierKind, os.Getenv("CLASSIFIER_BASE_URL"), os.Getenv("CLASSIFIER_MODEL"))
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-
-	kctx := kong.Parse(&cli.CLI)
-	err = kctx.Run(a)
-	kctx.FatalIfErrorf(err)
+	return app.New(app.Config{
+		EventFile:  filename,
+		NotesFile:  notesFile,
+		UsersFile:  usersFile,
+		JobsFile:   jobsFile,
+		BlobsDir:   blobsDir,
+		Classifier: classifier,
+	})
 }
@@ -0,0 +1,57 @@
+package note
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+// IndexRow is an entry in one of the indexes derived from note text: the
+// people mentioned and the tags of each note
+type IndexRow struct {
+	Index  string `db:"idx"` // "people", "tags" or "explicit tags"
+	NoteID string `db:"note_id"`
+	Value  string `db:"value"`
+}
+
+func (r IndexRow) String() string {
+	return fmt.Sprintf("%s %s %s", r.Index, r.NoteID, r.Value)
+}
+
+// Indexes returns every row of the derived indexes, in a stable order
+func (p *Projection) Indexes() ([]IndexRow, error) {
+	var rows []IndexRow
+	err := p.db.Select(&rows, `
+select distinct 'people' idx, note_id, handle value from note_people
+union
+select distinct case explicit when 1 then 'explicit tags' else 'tags' end idx, note_id, tag value from note_tags
+order by idx, note_id, value`)
+	if err != nil {
+		return nil, fmt.Errorf("select indexes: %w", err)
+	}
+	return rows, nil
+}
+
+// CompareIndexes returns the rows of want missing from got, and the rows of
+// got that are not in want. Both must be ordered as returned by Indexes.
+func CompareIndexes(got []IndexRow, want []IndexRow) (missing []IndexRow, extra []IndexRow) {
+	for _, row := range want {
+		if _, found := slices.BinarySearchFunc(got, row, compareIndexRows); !found {
+			missing = append(missing, row)
+		}
+	}
+	for _, row := range got {
+		if _, found := slices.BinarySearchFunc(want, row, compareIndexRows); !found {
+			extra = append(extra, row)
+		}
+	}
+	return missing, extra
+}
+
+func compareIndexRows(a IndexRow, b IndexRow) int {
+	return cmp.Or(
+		cmp.Compare(a.Index, b.Index),
+		cmp.Compare(a.NoteID, b.NoteID),
+		cmp.Compare(a.Value, b.Value),
+	)
+}
@@ -0,0 +1,227 @@
+package note
+
+import (
+	"cmp"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/rcy/evoke"
+	"github.com/rcy/whatever/catalog/notesmeta"
+	"github.com/rcy/whatever/events"
+)
+
+// Link is a link in the text of a note and what enrichment found there
+type Link struct {
+	NoteID   uuid.UUID `db:"note_id"`
+	URL      string    `db:"url"`
+	Position int       `db:"position"` // order of the link in the text
+	Status   string    `db:"status"`   // "enriching", "failure" or "" once enriched
+	Error    string    `db:"error"`    // why it failed, empty for failures recorded without a reason
+
+	Title        string `db:"title"`
+	Description  string `db:"description"`
+	SiteName     string `db:"site_name"`
+	CanonicalURL string `db:"canonical_url"`
+	Thumbnail    string `db:"thumbnail"`
+	ContentType  string `db:"content_type"`
+
+	// The readable copy of the page saved by the archive worker
+	ArchiveStatus string `db:"archive_status"` // "archiving", "failure", "archived" or "" if never asked
+	ArchiveError  string `db:"archive_error"`
+	Archive       string `db:"archive"` // hash of the text in the blob store
+	ArchivedAt    *int64 `db:"archived_at"`
+}
+
+func (p *Projection) handleLink(evt evoke.Event) error {
+	switch e := evt.(type) {
+	case events.NoteEnrichmentRequested:
+		link, err := p.eventLink(e.NoteID, cmp.Or(e.URL, firstLink(e.Text)))
+		if err != nil || link == "" {
+			return err
+		}
+		_, err = p.db.Exec(`insert into note_links(note_id, url, position, status) values(?,?,?,'enriching') on conflict(note_id, url) do update set status = 'enriching', error = ''`, e.NoteID, link, 0)
+		if err != nil {
+			return fmt.Errorf("insert note_links: %w", err)
+		}
+		return p.syncLinks(e.NoteID)
+	case events.NoteEnriched:
+		link, err := p.eventLink(e.NoteID, e.URL)
+		if err != nil || link == "" {
+			return err
+		}
+		q := `update note_links set status = '', title = ?, description = ?, site_name = ?, canonical_url = ?, thumbnail = ?, content_type = ? where note_id = ? and url = ?`
+		_, err = p.db.Exec(q, e.Title, e.Description, e.SiteName, e.CanonicalURL, e.Thumbnail, e.ContentType, e.NoteID, link)
+		if err != nil {
+			return fmt.Errorf("update note_links: %w", err)
+		}
+		return p.syncLinks(e.NoteID)
+	case events.NoteEnrichmentFailed:
+		link, err := p.eventLink(e.NoteID, e.URL)
+		if err != nil || link == "" {
+			return err
+		}
+		_, err = p.db.Exec(`update note_links set status = 'failure', error = ? where note_id = ? and url = ?`, e.Reason, e.NoteID, link)
+		if err != nil {
+			return fmt.Errorf("update note_links: %w", err)
+		}
+		return p.syncLinks(e.NoteID)
+	case events.NoteArchiveRequested:
+		_, err := p.db.Exec(`update note_links set archive_status = 'archiving', archive_error = '' where note_id = ? and url = ?`, e.NoteID, e.URL)
+		if err != nil {
+			return fmt.Errorf("update note_links: %w", err)
+		}
+		return nil
+	case events.NoteArchived:
+		q := `update note_links set archive_status = 'archived', archive = ?, archived_at = ? where note_id = ? and url = ?`
+		_, err := p.db.Exec(q, e.Hash, e.ArchivedAt.UTC().Unix(), e.NoteID, e.URL)
+		if err != nil {
+			return fmt.Errorf("update note_links: %w", err)
+		}
+		return p.syncLinks(e.NoteID)
+	case events.NoteArchiveFailed:
+		_, err := p.db.Exec(`update note_links set archive_status = 'failure', archive_error = ? where note_id = ? and url = ?`, e.Reason, e.NoteID, e.URL)
+		if err != nil {
+			return fmt.Errorf("update note_links: %w", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("note projection event not handled: %T", evt)
+}
+
+// eventLink returns link, or for events recorded before links were enriched
+// one by one, the first link in the text of the note
+func (p *Projection) eventLink(noteID uuid.UUID, link string) (string, error) {
+	if link != "" {
+		return link, nil
+	}
+	var text string
+	err := p.db.Get(&text, `select text from notes where id = ?`, noteID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("select text: %w", err)
+	}
+	return firstLink(text), nil
+}
+
+func firstLink(text string) string {
+	links := notesmeta.Links(text)
+	if len(links) == 0 {
+		return ""
+	}
+	return links[0]
+}
+
+// syncLinks forgets the links no longer in the text of a note, and sums up
+// the rest in the note: its status is "enriching" while any link is, then
+// "failure" if any link failed, the link card shows the first link that
+// was found, and the titles and archived pages of all of them are
+// searchable.
+func (p *Projection) syncLinks(noteID uuid.UUID) error {
+	var text string
+	err := p.db.Get(&text, `select text from notes where id = ?`, noteID)
+	if errors.Is(err, sql.ErrNoRows) {
+		// deleted notes keep their links as they were
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("select text: %w", err)
+	}
+
+	linkList, err := p.FindLinks(noteID.String())
+	if err != nil {
+		return err
+	}
+	inText := notesmeta.Links(text)
+	var kept []Link
+	for _, link := range linkList {
+		position := slices.Index(inText, link.URL)
+		if position < 0 {
+			_, err := p.db.Exec(`delete from note_links where note_id = ? and url = ?`, noteID, link.URL)
+			if err != nil {
+				return fmt.Errorf("delete note_links: %w", err)
+			}
+			continue
+		}
+		_, err := p.db.Exec(`update note_links set position = ? where note_id = ? and url = ?`, position, noteID, link.URL)
+		if err != nil {
+			return fmt.Errorf("update note_links: %w", err)
+		}
+		link.Position = position
+		kept = append(kept, link)
+	}
+	slices.SortFunc(kept, func(a, b Link) int { return cmp.Compare(a.Position, b.Position) })
+
+	status := ""
+	var card Link
+	var titles []string
+	var archives []string
+	for _, link := range kept {
+		switch {
+		case link.Status == "enriching":
+			status = "enriching"
+		case link.Status == "failure" && status == "":
+			status = "failure"
+		case link.Status == "" && card.URL == "" && (link.Title != "" || link.Thumbnail != ""):
+			card = link
+		}
+		if link.Title != "" {
+			titles = append(titles, link.Title)
+		}
+		if text := p.archiveText(link); text != "" {
+			archives = append(archives, text)
+		}
+	}
+	q := `update notes set status = ?, link_title = ?, link_description = ?, link_site_name = ?, link_url = ?, link_thumbnail = ?, link_content_type = ? where id = ?`
+	_, err = p.db.Exec(q, status, card.Title, card.Description, card.SiteName, cmp.Or(card.CanonicalURL, card.URL), card.Thumbnail, card.ContentType, noteID)
+	if err != nil {
+		return fmt.Errorf("update notes: %w", err)
+	}
+	_, err = p.db.Exec(`update note_search set title = ?, archive = ? where note_id = ?`, strings.Join(titles, "\n"), strings.Join(archives, "\n"), noteID)
+	if err != nil {
+		return fmt.Errorf("update note_search: %w", err)
+	}
+	return nil
+}
+
+// archiveText returns the archived copy of the page at link, or nothing if
+// there is none. Blobs missing from this machine are left out of search
+// rather than stopping the projection.
+func (p *Projection) archiveText(link Link) string {
+	text, err := p.ArchiveText(link)
+	if err != nil {
+		return ""
+	}
+	return text
+}
+
+// ArchiveText returns the readable copy of the page at link saved by the
+// archive worker
+func (p *Projection) ArchiveText(link Link) (string, error) {
+	if link.Archive == "" {
+		return "", fmt.Errorf("%s is not archived", link.URL)
+	}
+	if p.blobs == nil {
+		return "", fmt.Errorf("no blob store")
+	}
+	data, err := p.blobs.Get(link.Archive)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// FindLinks returns the links in a note in the order they appear in its text
+func (p *Projection) FindLinks(noteID string) ([]Link, error) {
+	var linkList []Link
+	err := p.db.Select(&linkList, `select note_id, url, position, status, error, title, description, site_name, canonical_url, thumbnail, content_type, archive_status, archive_error, archive, archived_at from note_links where note_id = ? order by position`, noteID)
+	if err != nil {
+		return nil, fmt.Errorf("select note_links: %w", err)
+	}
+	return linkList, nil
+}
@@ -3,12 +3,33 @@ package note
 import (
 	"regexp"
 	"strings"
+
+	"mvdan.cc/xurls/v2"
 )
 
 var mentionRe = regexp.MustCompile(`(?i)(?:^|[^a-z0-9_])@([a-z0-9_]+)`)
 
+// tags are not matched after & so html entities like &#39; are skipped, or
+// after / so paths like docs/#install are
+var tagRe = regexp.MustCompile(`(?i)(?:^|[^a-z0-9_&/])#([a-z0-9_-]+)`)
+
+// links are blanked out before looking for mentions and tags, so anchors
+// like https://example.com/#/route and handles in links are not picked up
+var linkRe = xurls.Strict()
+
 func extractMentions(text string) []string {
-	matches := mentionRe.FindAllStringSubmatch(text, -1)
+	return extractHandles(mentionRe, text)
+}
+
+func extractTags(text string) []string {
+	return extractHandles(tagRe, text)
+}
+
+// extractHandles returns the distinct lowercased first submatches of re
+// outside of the links in text
+func extractHandles(re *regexp.Regexp, text string) []string {
+	text = linkRe.ReplaceAllString(text, " ")
+	matches := re.FindAllStringSubmatch(text, -1)
 
 	seen := make(map[string]struct{})
 	var result []string
@@ -0,0 +1,56 @@
+package note
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractTags(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"nothing here", nil},
+		{"#work", []string{"work"}},
+		{"plan the #Garden and the #garden-shed", []string{"garden", "garden-shed"}},
+		{"(#one,#two) #One", []string{"one", "two"}},
+		{"done.#tag", []string{"tag"}},
+		{"issue#12", nil},
+		{"it&#39;s", nil},
+		{"see docs/#install", nil},
+		{"https://example.com/#/route #real", []string{"real"}},
+		{"www.example.com/page#section", nil},
+		{"#", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got := extractTags(tt.text)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractMentions(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"nothing here", nil},
+		{"@alice", []string{"alice"}},
+		{"lunch with @Alice and @bob, then @alice again", []string{"alice", "bob"}},
+		{"mail alice@example.com", nil},
+		{"https://mastodon.social/@alice", nil},
+		{"https://example.com/@bob says @carol", []string{"carol"}},
+		{"@", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got := extractMentions(tt.text)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,13 +1,17 @@
 package note
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 	"github.com/rcy/evoke"
 	"github.com/rcy/whatever/events"
-	_ "modernc.org/sqlite"
+	"github.com/rcy/whatever/projections/projectiondb"
 )
 
 type Note struct {
@@ -21,36 +25,121 @@ type Note struct {
 	State       string    `db:"state"`
 	Status      string    `db:"status"`
 	Starred     bool      `db:"starred"`
+
+	// Classification suggested by the classifier when it was not
+	// confident enough to file the note itself
+	SuggestedCategory    string `db:"suggested_category"`
+	SuggestedSubcategory string `db:"suggested_subcategory"`
+	SuggestedTimeframe   string `db:"suggested_timeframe"`
+
+	// Task lifecycle
+	CompletedAt   *int64 `db:"completed_at"`
+	DeferredCount int    `db:"deferred_count"`
+	ReopenedCount int    `db:"reopened_count"`
+	Recurrence    string `db:"recurrence"` // empty for tasks that do not recur
+
+	// What was found at the link in the note, see events.NoteEnriched
+	LinkTitle       string `db:"link_title"`
+	LinkDescription string `db:"link_description"`
+	LinkSiteName    string `db:"link_site_name"`
+	LinkURL         string `db:"link_url"`
+	LinkThumbnail   string `db:"link_thumbnail"`
+	LinkContentType string `db:"link_content_type"`
+}
+
+// DisplayText returns the text of the note with the title of its link in
+// front, for places that show a note on one line without its link card
+func (n Note) DisplayText() string {
+	if n.LinkTitle == "" || strings.Contains(n.Text, n.LinkTitle) {
+		return n.Text
+	}
+	return n.LinkTitle + " " + n.Text
 }
 
-type Person struct {
-	Handle string `db:"handle"`
+// TaskEvent is an entry in the completion history of a task
+type TaskEvent struct {
+	NoteID uuid.UUID `db:"note_id"`
+	Kind   string    `db:"kind"` // "completed", "deferred", "reopened" or "recurred"
+	Ts     int64     `db:"ts"`
+	From   *int64    `db:"due_from"`
+	To     *int64    `db:"due_to"`
 }
 
 type Projection struct {
-	db *sqlx.DB
+	conn  *sqlx.DB
+	db    projectiondb.Queryer // conn, or the transaction of the event being applied
+	blobs BlobReader
+}
+
+// BlobReader returns the contents of a blob saved by the archive worker
+type BlobReader interface {
+	Get(hash string) ([]byte, error)
+}
+
+// UseBlobs makes the archived copies of linked pages in blobs searchable.
+// Call it before any events are applied.
+func (p *Projection) UseBlobs(blobs BlobReader) {
+	p.blobs = blobs
 }
 
+// Bump schemaVersion whenever the tables below change, or what is derived
+// into them from events does. A persisted projection with a different
+// version is dropped and rebuilt from the event log.
+const schemaVersion = 14
+
+var schema = []string{
+	`create table notes(id text primary key, owner text not null, ts integer not null, text text not null, category text not null, subcategory text not null, due integer, state text not null, status text not null, starred integer not null default 0, suggested_category text not null default '', suggested_subcategory text not null default '', suggested_timeframe text not null default '', completed_at integer, deferred_count integer not null default 0, reopened_count integer not null default 0, recurrence text not null default '', link_title text not null default '', link_description text not null default '', link_site_name text not null default '', link_url text not null default '', link_thumbnail text not null default '', link_content_type text not null default '') strict`,
+	`create table deleted_notes(id text primary key, owner text not null, ts integer not null, text text not null, category text not null, subcategory text not null, due integer, state text not null, status text not null, starred integer not null default 0, suggested_category text not null default '', suggested_subcategory text not null default '', suggested_timeframe text not null default '', completed_at integer, deferred_count integer not null default 0, reopened_count integer not null default 0, recurrence text not null default '', link_title text not null default '', link_description text not null default '', link_site_name text not null default '', link_url text not null default '', link_thumbnail text not null default '', link_content_type text not null default '') strict`,
+	`create table note_people(handle text, note_id text) strict`,
+	// details of people kept apart from the notes mentioning them, see Person
+	`create table people(owner text not null, handle text not null, display_name text not null default '', notes text not null default '', merged_into text not null default '', primary key(owner, handle)) strict`,
+	// links in the text of notes and what enrichment found there, see Link
+	`create table note_links(note_id text not null, url text not null, position integer not null, status text not null, error text not null default '', title text not null default '', description text not null default '', site_name text not null default '', canonical_url text not null default '', thumbnail text not null default '', content_type text not null default '', archive_status text not null default '', archive_error text not null default '', archive text not null default '', archived_at integer, primary key(note_id, url)) strict`,
+	// explicit tags are added with TagNoteAdded, the others are #tags in the text
+	`create table note_tags(tag text not null, note_id text not null, explicit integer not null) strict`,
+	`create table task_history(note_id text not null, kind text not null, ts integer not null, due_from integer, due_to integer) strict`,
+	`create index task_history_note_id on task_history(note_id)`,
+	// full text search over live and deleted notes, see Search
+	`create virtual table note_search using fts5(note_id unindexed, title, text, archive, tokenize = 'porter unicode61 remove_diacritics 2')`,
+}
+
+// noteColumns are copied between notes and deleted_notes
+const noteColumns = `id, owner, ts, text, category, subcategory, due, state, status, starred, suggested_category, suggested_subcategory, suggested_timeframe, completed_at, deferred_count, reopened_count, recurrence, link_title, link_description, link_site_name, link_url, link_thumbnail, link_content_type`
+
+// New returns an in-memory projection that must be rebuilt from the start
+// of the event log.
 func New() (*Projection, error) {
-	db, err := sqlx.Open("sqlite", ":memory:")
+	return Open(":memory:")
+}
+
+// Open returns a projection stored in the sqlite file at filename. The
+// projection remembers the position of the last event applied, see
+// Position.
+func Open(filename string) (*Projection, error) {
+	db, err := projectiondb.Open(filename, schemaVersion, schema)
 	if err != nil {
 		return nil, err
 	}
-	_, err = db.Exec(`create table notes(id text primary key, owner text not null, ts integer not null, text text not null, category text not null, subcategory text not null, due integer, state text not null, status text not null, starred integer not null default 0) strict`)
-	if err != nil {
-		return nil, fmt.Errorf("create table notes: %w", err)
-	}
-	_, err = db.Exec(`create table deleted_notes(id text primary key, owner text not null, ts integer not null, text text not null, category text not null, subcategory text not null, due integer, state text not null, status text not null, starred integer not null default 0) strict`)
-	if err != nil {
-		return nil, fmt.Errorf("create table deleted_notes: %w", err)
-	}
+	return &Projection{conn: db, db: db}, nil
+}
 
-	_, err = db.Exec(`create table note_people(handle text, note_id text) strict`)
-	if err != nil {
-		return nil, fmt.Errorf("create table note_people: %w", err)
-	}
+// Position returns the sequence number of the last event applied to the projection
+func (p *Projection) Position() (int64, error) {
+	return projectiondb.Position(p.db)
+}
 
-	return &Projection{db: db}, nil
+// Checkpoint records that all events up to and including sequence have been applied
+func (p *Projection) Checkpoint(sequence int64) error {
+	return projectiondb.Checkpoint(p.db, sequence)
+}
+
+// Apply handles evt and checkpoints sequence in one transaction
+func (p *Projection) Apply(evt evoke.Event, replaying bool, sequence int64) error {
+	return projectiondb.Apply(p.conn, sequence, func(tx *sqlx.Tx) error {
+		in := *p
+		in.db = tx
+		return in.Handle(evt, replaying)
+	})
 }
 
 func (p *Projection) Handle(evt evoke.Event, replaying bool) error {
@@ -61,20 +150,18 @@ func (p *Projection) Handle(evt evoke.Event, replaying bool) error {
 		if err != nil {
 			return fmt.Errorf("Exec: %w", err)
 		}
-
-		for _, mention := range extractMentions(e.Text) {
-			_, err = p.db.Exec(`insert into note_people(note_id, handle) values(?,?)`, e.NoteID, mention)
-			if err != nil {
-				return err
-			}
+		_, err = p.db.Exec(`insert into note_search(note_id, title, text, archive) values(?,'',?,'')`, e.NoteID, e.Text)
+		if err != nil {
+			return fmt.Errorf("insert note_search: %w", err)
 		}
+		return p.reindex(e.NoteID)
 	case events.NoteOwnerSet:
 		_, err := p.db.Exec(`update notes set owner = ? where id = ?`, e.Owner, e.NoteID)
 		if err != nil {
 			return err
 		}
 	case events.NoteDeleted:
-		q := `insert into deleted_notes(id, owner, ts, text, category, subcategory, due, state, status) select id, owner, ts, text, category, subcategory, due, state, status from notes where id = ?`
+		q := `insert into deleted_notes(` + noteColumns + `) select ` + noteColumns + ` from notes where id = ?`
 		_, err := p.db.Exec(q, e.NoteID)
 		if err != nil {
 			return err
@@ -84,25 +171,50 @@ func (p *Projection) Handle(evt evoke.Event, replaying bool) error {
 		if err != nil {
 			return err
 		}
-
-		return err
+		return p.reindex(e.NoteID)
 	case events.NoteUndeleted:
-		q := `insert into notes(id, owner, ts, text, category, subcategory, due, state, status) select id, owner, ts, text, category, subcategory, due, state, status from deleted_notes where id = ?`
+		q := `insert into notes(` + noteColumns + `) select ` + noteColumns + ` from deleted_notes where id = ?`
 		_, err := p.db.Exec(q, e.NoteID)
 		if err != nil {
 			return err
 		}
 
 		_, err = p.db.Exec(`delete from deleted_notes where id = ?`, e.NoteID)
-		return err
+		if err != nil {
+			return err
+		}
+		return p.reindex(e.NoteID)
 	case events.NoteTextUpdated:
-		_, err := p.db.Exec(`update notes set text = ? where id = ?`, e.Text, e.NoteID)
-		return err
+		text := e.Text
+		if e.UpdatedAt.IsZero() {
+			var title string
+			err := p.db.Get(&title, `select link_title from notes where id = ?`, e.NoteID)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+			text = stripLinkTitle(e.Text, title)
+		}
+		_, err := p.db.Exec(`update notes set text = ? where id = ?`, text, e.NoteID)
+		if err != nil {
+			return err
+		}
+		_, err = p.db.Exec(`update note_search set text = ? where note_id = ?`, text, e.NoteID)
+		if err != nil {
+			return fmt.Errorf("update note_search: %w", err)
+		}
+		err = p.reindex(e.NoteID)
+		if err != nil {
+			return err
+		}
+		return p.syncLinks(e.NoteID)
 	case events.NoteCategoryChanged:
-		_, err := p.db.Exec(`update notes set category = ?, subcategory = ? where id = ?`, e.Category, e.Subcategory, e.NoteID)
+		_, err := p.db.Exec(`update notes set category = ?, subcategory = ?, suggested_category = '', suggested_subcategory = '', suggested_timeframe = '' where id = ?`, e.Category, e.Subcategory, e.NoteID)
 		return err
 	case events.NoteSubcategoryChanged:
-		_, err := p.db.Exec(`update notes set subcategory = ? where id = ?`, e.Subcategory, e.NoteID)
+		_, err := p.db.Exec(`update notes set subcategory = ?, suggested_category = '', suggested_subcategory = '', suggested_timeframe = '' where id = ?`, e.Subcategory, e.NoteID)
+		return err
+	case events.NoteClassificationSuggested:
+		_, err := p.db.Exec(`update notes set suggested_category = ?, suggested_subcategory = ?, suggested_timeframe = ? where id = ?`, e.Category, e.Subcategory, e.Timeframe, e.NoteID)
 		return err
 	case events.NoteDueChanged:
 		_, err := p.db.Exec(`update notes set due = ? where id = ?`, e.Due.UTC().Unix(), e.NoteID)
@@ -110,27 +222,135 @@ func (p *Projection) Handle(evt evoke.Event, replaying bool) error {
 	case events.NoteDueCleared:
 		_, err := p.db.Exec(`update notes set due = null where id = ?`, e.NoteID)
 		return err
-	case events.NoteEnrichmentRequested:
-		_, err := p.db.Exec(`update notes set status = 'enriching' where id = ?`, e.NoteID)
-		return err
-	case events.NoteEnriched:
-		_, err := p.db.Exec(`update notes set status = '', text = ? || ' ' || text where id = ?`, e.Title, e.NoteID)
-		return err
-	case events.NoteEnrichmentFailed:
-		_, err := p.db.Exec(`update notes set status = 'failure' where id = ?`, e.NoteID)
-		return err
+	case events.NoteEnrichmentRequested, events.NoteEnriched, events.NoteEnrichmentFailed,
+		events.NoteArchiveRequested, events.NoteArchived, events.NoteArchiveFailed:
+		return p.handleLink(e)
 	case events.NoteStarred:
 		_, err := p.db.Exec(`update notes set starred = 1 where id = ?`, e.NoteID)
 		return err
 	case events.NoteUnstarred:
 		_, err := p.db.Exec(`update notes set starred = 0 where id = ?`, e.NoteID)
 		return err
+	case events.NoteTaskCompleted:
+		completedAt := e.CompletedAt.UTC().Unix()
+		_, err := p.db.Exec(`update notes set state = 'completed', completed_at = ? where id = ?`, completedAt, e.NoteID)
+		if err != nil {
+			return err
+		}
+		return p.addTaskEvent(e.NoteID, "completed", completedAt, nil, nil)
+	case events.NoteTaskDeferred:
+		_, err := p.db.Exec(`update notes set deferred_count = deferred_count + 1 where id = ?`, e.NoteID)
+		if err != nil {
+			return err
+		}
+		from := e.From.UTC().Unix()
+		var to *int64
+		if e.To != nil {
+			t := e.To.UTC().Unix()
+			to = &t
+		}
+		return p.addTaskEvent(e.NoteID, "deferred", e.DeferredAt.UTC().Unix(), &from, to)
+	case events.NoteTaskReopened:
+		_, err := p.db.Exec(`update notes set state = 'open', completed_at = null, reopened_count = reopened_count + 1 where id = ?`, e.NoteID)
+		if err != nil {
+			return err
+		}
+		return p.addTaskEvent(e.NoteID, "reopened", e.ReopenedAt.UTC().Unix(), nil, nil)
+	case events.NoteTaskRecurred:
+		_, err := p.db.Exec(`update notes set state = 'open' where id = ?`, e.NoteID)
+		if err != nil {
+			return err
+		}
+		due := e.Due.UTC().Unix()
+		return p.addTaskEvent(e.NoteID, "recurred", e.RecurredAt.UTC().Unix(), nil, &due)
+	case events.TagNoteAdded:
+		_, err := p.db.Exec(`insert into note_tags(note_id, tag, explicit) values(?,?,1)`, e.NoteID, e.Tag)
+		return err
+	case events.TagNoteRemoved:
+		_, err := p.db.Exec(`delete from note_tags where note_id = ? and tag = ? and explicit = 1`, e.NoteID, e.Tag)
+		return err
+	case events.NoteRecurrenceSet:
+		_, err := p.db.Exec(`update notes set recurrence = ? where id = ?`, e.Rule, e.NoteID)
+		return err
+	case events.NoteRecurrenceCleared:
+		_, err := p.db.Exec(`update notes set recurrence = '' where id = ?`, e.NoteID)
+		return err
+	case events.PersonDisplayNameSet, events.PersonNotesSet, events.PersonMerged, events.PersonUnmerged:
+		return p.handlePerson(e)
 	default:
 		return fmt.Errorf("note projection event not handled: %T", evt)
 	}
 	return nil
 }
 
+// stripLinkTitle removes the link title that older versions of this
+// projection put in front of the text of enriched notes. Editing such a note
+// saved the title into the text, sometimes more than once. It is only used
+// on events recorded by those versions, text typed since is kept as is.
+func stripLinkTitle(text string, title string) string {
+	if title == "" {
+		return text
+	}
+	stripped := text
+	for {
+		rest, found := strings.CutPrefix(stripped, title+" ")
+		if !found {
+			break
+		}
+		stripped = rest
+	}
+	// titles were only ever put in front of text starting with a link
+	if !strings.HasPrefix(stripped, "http") {
+		return text
+	}
+	return stripped
+}
+
+// reindex recomputes the mentions and text tags of a note from its current
+// text. Deleted notes are not indexed; their explicit tags are kept for when
+// they are undeleted.
+func (p *Projection) reindex(noteID uuid.UUID) error {
+	_, err := p.db.Exec(`delete from note_people where note_id = ?`, noteID)
+	if err != nil {
+		return fmt.Errorf("delete note_people: %w", err)
+	}
+	_, err = p.db.Exec(`delete from note_tags where note_id = ? and explicit = 0`, noteID)
+	if err != nil {
+		return fmt.Errorf("delete note_tags: %w", err)
+	}
+
+	var text string
+	err = p.db.Get(&text, `select text from notes where id = ?`, noteID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("select text: %w", err)
+	}
+
+	for _, mention := range extractMentions(text) {
+		_, err = p.db.Exec(`insert into note_people(note_id, handle) values(?,?)`, noteID, mention)
+		if err != nil {
+			return fmt.Errorf("insert note_people: %w", err)
+		}
+	}
+	for _, tag := range extractTags(text) {
+		_, err = p.db.Exec(`insert into note_tags(note_id, tag, explicit) values(?,?,0)`, noteID, tag)
+		if err != nil {
+			return fmt.Errorf("insert note_tags: %w", err)
+		}
+	}
+	return nil
+}
+
+func (p *Projection) addTaskEvent(noteID uuid.UUID, kind string, ts int64, from *int64, to *int64) error {
+	_, err := p.db.Exec(`insert into task_history(note_id, kind, ts, due_from, due_to) values(?,?,?,?,?)`, noteID, kind, ts, from, to)
+	if err != nil {
+		return fmt.Errorf("insert task_history: %w", err)
+	}
+	return nil
+}
+
 func (p *Projection) FindOne(id string) (Note, error) {
 	var note Note
 	err := p.db.Get(&note, `select * from notes where id = ?`, id)
@@ -140,29 +360,148 @@ func (p *Projection) FindOne(id string) (Note, error) {
 	return note, nil
 }
 
-func (p *Projection) FindAllPeople(owner string) ([]string, error) {
-	var handles []string
-	err := p.db.Select(&handles, `select distinct handle from note_people join notes on note_people.note_id = notes.id where owner = ?`, owner)
+func (p *Projection) FindOneDeleted(id string) (Note, error) {
+	var note Note
+	err := p.db.Get(&note, `select * from deleted_notes where id = ?`, id)
 	if err != nil {
-		return nil, fmt.Errorf("Select notes 1: %w", err)
+		return Note{}, err
 	}
-	return handles, nil
+	return note, nil
 }
 
-func (p *Projection) FindAll(owner string) ([]Note, error) {
+// Filter narrows down FindAllByFilter. Empty fields match everything.
+type Filter struct {
+	Category    string
+	Subcategory string
+	Person      string
+	Tag         string
+	DueAfter    *time.Time
+	DueBefore   *time.Time
+
+	// Completed narrows to tasks that were done in the range
+	CompletedAfter  *time.Time
+	CompletedBefore *time.Time
+
+	// EnrichmentFailed narrows to notes with a link that could not be
+	// looked up
+	EnrichmentFailed bool
+}
+
+func (p *Projection) FindAllByFilter(owner string, f Filter) ([]Note, error) {
+	q := `select notes.* from notes where owner = ?`
+	args := []any{owner}
+	if f.Category != "" {
+		q += ` and category = ?`
+		args = append(args, f.Category)
+	}
+	if f.Subcategory != "" {
+		q += ` and subcategory = ?`
+		args = append(args, f.Subcategory)
+	}
+	if f.Person != "" {
+		// mentions of the person's aliases count too
+		handles, err := p.personHandles(owner, strings.ToLower(strings.TrimPrefix(f.Person, "@")))
+		if err != nil {
+			return nil, err
+		}
+		q += ` and exists (select 1 from note_people where note_people.note_id = notes.id and handle in (` + strings.Repeat(",?", len(handles))[1:] + `))`
+		for _, h := range handles {
+			args = append(args, h)
+		}
+	}
+	if f.Tag != "" {
+		q += ` and exists (select 1 from note_tags where note_tags.note_id = notes.id and tag = ?)`
+		args = append(args, strings.ToLower(f.Tag))
+	}
+	if f.EnrichmentFailed {
+		q += ` and exists (select 1 from note_links where note_links.note_id = notes.id and status = 'failure')`
+	}
+	if f.DueAfter != nil {
+		q += ` and due > ?`
+		args = append(args, f.DueAfter.Unix())
+	}
+	if f.DueBefore != nil {
+		q += ` and due <= ?`
+		args = append(args, f.DueBefore.Unix())
+	}
+	if f.CompletedAfter != nil {
+		q += ` and completed_at >= ?`
+		args = append(args, f.CompletedAfter.Unix())
+	}
+	if f.CompletedBefore != nil {
+		q += ` and completed_at < ?`
+		args = append(args, f.CompletedBefore.Unix())
+	}
+	q += ` order by ts asc`
+
 	var noteList []Note
-	err := p.db.Select(&noteList, `select * from notes where owner = ? order by ts asc`, owner)
+	err := p.db.Select(&noteList, q, args...)
 	if err != nil {
-		return nil, fmt.Errorf("Select notes 2: %w", err)
+		return nil, fmt.Errorf("Select notes by filter: %w", err)
 	}
 	return noteList, nil
 }
 
-func (p *Projection) FindAllByPerson(owner string, handle string) ([]Note, error) {
+// TaskHistory returns the completions, deferrals and reopenings of a task,
+// oldest first
+func (p *Projection) TaskHistory(noteID string) ([]TaskEvent, error) {
+	var history []TaskEvent
+	err := p.db.Select(&history, `select * from task_history where note_id = ? order by rowid asc`, noteID)
+	if err != nil {
+		return nil, fmt.Errorf("select task_history: %w", err)
+	}
+	return history, nil
+}
+
+type TagCount struct {
+	Tag   string `db:"tag"`
+	Count int    `db:"count"`
+}
+
+// TagCounts returns the tags of owner's notes and how many notes have
+// each, most used first
+func (p *Projection) TagCounts(owner string) ([]TagCount, error) {
+	var tags []TagCount
+	err := p.db.Select(&tags, `select tag, count(distinct note_id) count from note_tags join notes on note_tags.note_id = notes.id where owner = ? group by tag order by count desc, tag asc`, owner)
+	if err != nil {
+		return nil, fmt.Errorf("select tags: %w", err)
+	}
+	return tags, nil
+}
+
+func (p *Projection) FindAllByTag(owner string, tag string) ([]Note, error) {
 	var noteList []Note
-	err := p.db.Select(&noteList, `select notes.* from notes join note_people on note_people.note_id = notes.id where owner = ? and handle = ? order by ts asc`, owner, handle)
+	err := p.db.Select(&noteList, `select distinct notes.* from notes join note_tags on note_tags.note_id = notes.id where owner = ? and tag = ? order by ts asc`, owner, strings.ToLower(tag))
 	if err != nil {
-		return nil, fmt.Errorf("Select notes 3: %w", err)
+		return nil, fmt.Errorf("select notes by tag: %w", err)
+	}
+	return noteList, nil
+}
+
+func (p *Projection) FindAllWithTag(owner string) ([]Note, error) {
+	var noteList []Note
+	err := p.db.Select(&noteList, `select distinct notes.* from notes join note_tags on note_tags.note_id = notes.id where owner = ? order by ts asc`, owner)
+	if err != nil {
+		return nil, fmt.Errorf("select tagged notes: %w", err)
+	}
+	return noteList, nil
+}
+
+// NoteTags returns the tags of a note, whether explicit or in its text
+func (p *Projection) NoteTags(noteID string) ([]string, error) {
+	var tags []string
+	err := p.db.Select(&tags, `select distinct tag from note_tags where note_id = ? order by tag asc`, noteID)
+	if err != nil {
+		return nil, fmt.Errorf("select note_tags: %w", err)
+	}
+	return tags, nil
+}
+
+func (p *Projection) FindAll(owner string) ([]Note, error) {
+	var noteList []Note
+	err := p.db.Select(&noteList, `select * from notes where owner = ? order by ts asc`, owner)
+	if err != nil {
+		return nil, fmt.Errorf("Select notes 2: %w", err)
 	}
 	return noteList, nil
 }
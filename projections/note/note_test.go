@@ -0,0 +1,29 @@
+package note
+
+import "testing"
+
+func TestStripLinkTitle(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		title string
+		want  string
+	}{
+		{"no title", "https://example.com", "", "https://example.com"},
+		{"title saved once", "Example https://example.com", "Example", "https://example.com"},
+		{"title saved twice", "Example Example https://example.com", "Example", "https://example.com"},
+		{"title with spaces", "An Example Page https://example.com hello", "An Example Page", "https://example.com hello"},
+		{"not in front", "see Example https://example.com", "Example", "see Example https://example.com"},
+		{"not before a link", "Example is what I typed", "Example", "Example is what I typed"},
+		{"title without a space", "Examplehttps://example.com", "Example", "Examplehttps://example.com"},
+		{"text is the title", "Example", "Example", "Example"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := stripLinkTitle(tt.text, tt.title)
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package note
+
+import (
+	"cmp"
+	"database/sql"
+	"fmt"
+	"slices"
+
+	"github.com/rcy/evoke"
+	"github.com/rcy/whatever/events"
+)
+
+// Person is someone @mentioned in notes, with the mentions of their
+// aliases folded in
+type Person struct {
+	Handle      string
+	DisplayName string // empty if not set
+	Notes       string // contact notes
+	Aliases     []string
+
+	Mentions      int    // live notes mentioning them
+	LastMentioned *int64 // when the newest of those notes was created
+	OpenTasks     int    // tasks mentioning them that are not done
+}
+
+// Name returns the display name of the person, or their @handle
+func (p Person) Name() string {
+	if p.DisplayName != "" {
+		return p.DisplayName
+	}
+	return "@" + p.Handle
+}
+
+type personRow struct {
+	Handle      string `db:"handle"`
+	DisplayName string `db:"display_name"`
+	Notes       string `db:"notes"`
+	MergedInto  string `db:"merged_into"`
+}
+
+func (p *Projection) handlePerson(evt evoke.Event) error {
+	upsert := func(owner string, handle string, column string, value string) error {
+		_, err := p.db.Exec(`insert into people(owner, handle, `+column+`) values(?,?,?) on conflict(owner, handle) do update set `+column+` = excluded.`+column, owner, handle, value)
+		if err != nil {
+			return fmt.Errorf("upsert people: %w", err)
+		}
+		return nil
+	}
+	switch e := evt.(type) {
+	case events.PersonDisplayNameSet:
+		return upsert(e.Owner, e.Handle, "display_name", e.DisplayName)
+	case events.PersonNotesSet:
+		return upsert(e.Owner, e.Handle, "notes", e.Notes)
+	case events.PersonMerged:
+		return upsert(e.Owner, e.Handle, "merged_into", e.Into)
+	case events.PersonUnmerged:
+		return upsert(e.Owner, e.Handle, "merged_into", "")
+	}
+	return fmt.Errorf("note projection event not handled: %T", evt)
+}
+
+// peopleOf returns the people rows of owner by handle, and a function
+// resolving a handle to the person it was merged into
+func (p *Projection) peopleOf(owner string) (map[string]personRow, func(string) string, error) {
+	var rows []personRow
+	err := p.db.Select(&rows, `select handle, display_name, notes, merged_into from people where owner = ?`, owner)
+	if err != nil {
+		return nil, nil, fmt.Errorf("select people: %w", err)
+	}
+	byHandle := make(map[string]personRow, len(rows))
+	for _, row := range rows {
+		byHandle[row.Handle] = row
+	}
+
+	resolve := func(handle string) string {
+		seen := map[string]bool{}
+		for !seen[handle] {
+			seen[handle] = true
+			next := byHandle[handle].MergedInto
+			if next == "" {
+				return handle
+			}
+			handle = next
+		}
+		// merged in a circle, leave them apart
+		return handle
+	}
+	return byHandle, resolve, nil
+}
+
+// ResolveHandle returns the handle of the person that handle is an alias of,
+// or handle itself
+func (p *Projection) ResolveHandle(owner string, handle string) (string, error) {
+	_, resolve, err := p.peopleOf(owner)
+	if err != nil {
+		return "", err
+	}
+	return resolve(handle), nil
+}
+
+// personHandles returns the handle of the person that handle is, or is an
+// alias of, followed by all of their aliases
+func (p *Projection) personHandles(owner string, handle string) ([]string, error) {
+	byHandle, resolve, err := p.peopleOf(owner)
+	if err != nil {
+		return nil, err
+	}
+	handle = resolve(handle)
+	handles := []string{handle}
+	for h := range byHandle {
+		if h != handle && resolve(h) == handle {
+			handles = append(handles, h)
+		}
+	}
+	return handles, nil
+}
+
+// People returns everyone mentioned in owner's notes or with details of
+// their own, most recently mentioned first
+func (p *Projection) People(owner string) ([]Person, error) {
+	byHandle, resolve, err := p.peopleOf(owner)
+	if err != nil {
+		return nil, err
+	}
+
+	var mentions []struct {
+		Handle string `db:"handle"`
+		NoteID string `db:"id"`
+		Ts     int64  `db:"ts"`
+		Open   bool   `db:"open"`
+	}
+	err = p.db.Select(&mentions, `select note_people.handle, notes.id, notes.ts, notes.category = 'task' and notes.subcategory != 'done' open from note_people join notes on note_people.note_id = notes.id where owner = ?`, owner)
+	if err != nil {
+		return nil, fmt.Errorf("select mentions: %w", err)
+	}
+
+	people := map[string]*Person{}
+	person := func(handle string) *Person {
+		if people[handle] == nil {
+			row := byHandle[handle]
+			people[handle] = &Person{Handle: handle, DisplayName: row.DisplayName, Notes: row.Notes}
+		}
+		return people[handle]
+	}
+	for handle, row := range byHandle {
+		canonical := resolve(handle)
+		if canonical != handle {
+			pp := person(canonical)
+			pp.Aliases = append(pp.Aliases, handle)
+		} else if row.DisplayName != "" || row.Notes != "" {
+			person(handle)
+		}
+	}
+
+	counted := map[string]map[string]bool{}
+	for _, m := range mentions {
+		canonical := resolve(m.Handle)
+		pp := person(canonical)
+		if counted[canonical] == nil {
+			counted[canonical] = map[string]bool{}
+		}
+		if counted[canonical][m.NoteID] {
+			continue
+		}
+		counted[canonical][m.NoteID] = true
+		pp.Mentions++
+		if pp.LastMentioned == nil || m.Ts > *pp.LastMentioned {
+			ts := m.Ts
+			pp.LastMentioned = &ts
+		}
+		if m.Open {
+			pp.OpenTasks++
+		}
+	}
+
+	result := make([]Person, 0, len(people))
+	for _, pp := range people {
+		slices.Sort(pp.Aliases)
+		result = append(result, *pp)
+	}
+	slices.SortFunc(result, func(a, b Person) int {
+		var at, bt int64
+		if a.LastMentioned != nil {
+			at = *a.LastMentioned
+		}
+		if b.LastMentioned != nil {
+			bt = *b.LastMentioned
+		}
+		return cmp.Or(cmp.Compare(bt, at), cmp.Compare(a.Handle, b.Handle))
+	})
+	return result, nil
+}
+
+// FindPerson returns the person with handle, or the one handle is an alias
+// of. People that were never mentioned nor given details are not found.
+func (p *Projection) FindPerson(owner string, handle string) (Person, error) {
+	people, err := p.People(owner)
+	if err != nil {
+		return Person{}, err
+	}
+	handle, err = p.ResolveHandle(owner, handle)
+	if err != nil {
+		return Person{}, err
+	}
+	i := slices.IndexFunc(people, func(pp Person) bool { return pp.Handle == handle })
+	if i < 0 {
+		return Person{}, sql.ErrNoRows
+	}
+	return people[i], nil
+}
+
+// FindAllByPerson returns the notes mentioning the person with handle or
+// any of their aliases
+func (p *Projection) FindAllByPerson(owner string, handle string) ([]Note, error) {
+	return p.FindAllByFilter(owner, Filter{Person: handle})
+}
@@ -0,0 +1,84 @@
+package note
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// maxCandidates limits how many notes an AmbiguousIDError lists
+const maxCandidates = 10
+
+// ErrInvalidID is returned by ResolveID for a prefix that cannot be part of
+// a note id
+var ErrInvalidID = errors.New("invalid note id")
+
+var idPrefixPattern = regexp.MustCompile(`^[0-9a-f-]+$`)
+
+// AmbiguousIDError is returned by ResolveID when a prefix matches more than
+// one note
+type AmbiguousIDError struct {
+	Prefix     string
+	Candidates []Note
+}
+
+func (e *AmbiguousIDError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "ambiguous id %q matches:", e.Prefix)
+	for _, n := range e.Candidates {
+		fmt.Fprintf(&b, "\n  %s %s", n.ID.String()[0:7], n.Text)
+	}
+	if len(e.Candidates) == maxCandidates {
+		b.WriteString("\n  ...")
+	}
+	return b.String()
+}
+
+// noMatchError is returned by ResolveID when no note matches
+type noMatchError struct {
+	prefix string
+}
+
+func (e noMatchError) Error() string {
+	return fmt.Sprintf("no note matches id %q", e.prefix)
+}
+
+func (e noMatchError) Unwrap() error {
+	return sql.ErrNoRows
+}
+
+// ResolveID expands an id prefix to the id of the single note of owner it
+// matches, searching both active and deleted notes. It returns an error
+// wrapping sql.ErrNoRows if nothing matches, and an *AmbiguousIDError if
+// more than one note does.
+func (p *Projection) ResolveID(owner string, prefix string) (uuid.UUID, error) {
+	prefix = strings.ToLower(strings.TrimSpace(prefix))
+	if !idPrefixPattern.MatchString(prefix) {
+		return uuid.Nil, fmt.Errorf("%w %q", ErrInvalidID, prefix)
+	}
+
+	var candidates []Note
+	err := p.db.Select(&candidates, `
+		select id, text from notes where owner = ? and id like ? || '%'
+		union all
+		select id, text from deleted_notes where owner = ? and id like ? || '%'
+		order by id
+		limit ?`,
+		owner, prefix, owner, prefix, maxCandidates)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("Select notes by id prefix: %w", err)
+	}
+
+	switch len(candidates) {
+	case 0:
+		return uuid.Nil, noMatchError{prefix}
+	case 1:
+		return candidates[0].ID, nil
+	default:
+		return uuid.Nil, &AmbiguousIDError{Prefix: prefix, Candidates: candidates}
+	}
+}
@@ -0,0 +1,243 @@
+package note
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Snippets returned by Search mark the matching words with these
+const (
+	SnippetStart = "\x02"
+	SnippetEnd   = "\x03"
+)
+
+// SearchResult is a note matching a search, live or deleted
+type SearchResult struct {
+	Note
+	Deleted bool `db:"deleted"`
+	// Snippet is the part of the note that best matches the search, empty
+	// when the search only has filters
+	Snippet string `db:"snippet"`
+}
+
+// Search returns the notes of owner matching query, best matches first.
+// Words in the query must all appear in the text or enrichment title of a
+// note. The query may also contain
+//
+//	"a phrase"      words next to each other
+//	pre*            words starting with pre
+//	-word           notes without word
+//	cat:task        notes in a category
+//	sub:today       notes in a subcategory
+//	tag:work        notes with a tag
+//	due:<7d         tasks due within 7 days, or overdue; due:>2w for later
+//	is:starred      starred notes
+//	is:deleted      deleted notes, which are otherwise included
+//	is:live         notes that are not deleted
+func (p *Projection) Search(owner string, query string) ([]SearchResult, error) {
+	sq, err := parseSearch(query, time.Now())
+	if err != nil {
+		return nil, &QueryError{Err: err}
+	}
+
+	notes := `(select ` + noteColumns + `, 0 deleted from notes union all select ` + noteColumns + `, 1 deleted from deleted_notes)`
+	var q string
+	args := []any{}
+	if sq.match != "" {
+		q = `select n.*, snippet(note_search, -1, ?, ?, '…', 16) snippet from note_search join ` + notes + ` n on n.id = note_search.note_id where note_search match ? and n.owner = ?`
+		args = append(args, SnippetStart, SnippetEnd, sq.match, owner)
+	} else {
+		q = `select n.*, '' snippet from ` + notes + ` n where n.owner = ?`
+		args = append(args, owner)
+	}
+	for _, f := range sq.filters {
+		q += ` and ` + f.sql
+		args = append(args, f.args...)
+	}
+	if sq.match != "" {
+		q += ` order by rank`
+	} else {
+		q += ` order by n.ts desc`
+	}
+	q += ` limit 100`
+
+	var results []SearchResult
+	err = p.db.Select(&results, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("search: %w", err)
+	}
+	return results, nil
+}
+
+// QueryError is returned by Search for a query it cannot parse
+type QueryError struct {
+	Err error
+}
+
+func (e *QueryError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+type searchQuery struct {
+	match   string // fts5 match expression
+	filters []searchFilter
+}
+
+type searchFilter struct {
+	sql  string
+	args []any
+}
+
+// parseSearch splits a search into an fts5 match expression and filters.
+// Words are quoted so that punctuation in them is not taken as fts5 syntax.
+func parseSearch(query string, now time.Time) (searchQuery, error) {
+	var sq searchQuery
+	var include, exclude []string
+
+	for _, token := range searchTokens(query) {
+		if token.phrase {
+			include = append(include, ftsQuote(token.text))
+			continue
+		}
+		word := token.text
+
+		if key, value, ok := strings.Cut(word, ":"); ok && value != "" {
+			f, ok, err := parseSearchFilter(strings.ToLower(key), value, now)
+			if err != nil {
+				return searchQuery{}, err
+			}
+			if ok {
+				sq.filters = append(sq.filters, f)
+				continue
+			}
+		}
+
+		negate := strings.HasPrefix(word, "-") && len(word) > 1
+		word = strings.TrimPrefix(word, "-")
+		term := ftsQuote(strings.TrimRight(word, "*"))
+		if strings.HasSuffix(word, "*") {
+			term += "*"
+		}
+		if strings.IndexFunc(word, isWordRune) < 0 {
+			// nothing fts5 would index
+			continue
+		}
+		if negate {
+			exclude = append(exclude, term)
+		} else {
+			include = append(include, term)
+		}
+	}
+
+	if len(exclude) > 0 && len(include) == 0 {
+		return searchQuery{}, fmt.Errorf("search for some words to exclude -words from")
+	}
+	if len(include) > 0 {
+		sq.match = "(" + strings.Join(include, " ") + ")"
+	}
+	for _, term := range exclude {
+		sq.match += " NOT " + term
+	}
+	return sq, nil
+}
+
+// parseSearchFilter returns the filter for key:value, or false if key is
+// not a filter
+func parseSearchFilter(key string, value string, now time.Time) (searchFilter, bool, error) {
+	switch key {
+	case "cat", "category":
+		return searchFilter{`n.category = ?`, []any{strings.ToLower(value)}}, true, nil
+	case "sub", "subcategory":
+		return searchFilter{`n.subcategory = ?`, []any{strings.ToLower(value)}}, true, nil
+	case "tag":
+		return searchFilter{`exists (select 1 from note_tags where note_tags.note_id = n.id and tag = ?)`, []any{strings.ToLower(strings.TrimPrefix(value, "#"))}}, true, nil
+	case "due":
+		op := "<="
+		switch value[0] {
+		case '<':
+			value = value[1:]
+		case '>':
+			op = ">"
+			value = value[1:]
+		}
+		d, err := parseSearchDuration(value)
+		if err != nil {
+			return searchFilter{}, false, fmt.Errorf("invalid due filter %q, use due:<7d or due:>2w", key+":"+value)
+		}
+		return searchFilter{`n.due is not null and n.due ` + op + ` ?`, []any{now.Add(d).Unix()}}, true, nil
+	case "is":
+		switch strings.ToLower(value) {
+		case "starred":
+			return searchFilter{`n.starred = 1`, nil}, true, nil
+		case "deleted":
+			return searchFilter{`n.deleted = 1`, nil}, true, nil
+		case "live":
+			return searchFilter{`n.deleted = 0`, nil}, true, nil
+		}
+		return searchFilter{}, false, fmt.Errorf("unknown filter is:%s, use is:starred, is:deleted or is:live", value)
+	}
+	return searchFilter{}, false, nil
+}
+
+// parseSearchDuration parses a number of days or weeks, like 7d or 2w
+func parseSearchDuration(s string) (time.Duration, error) {
+	if len(s) < 2 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	switch s[len(s)-1] {
+	case 'd':
+		return time.Duration(n) * 24 * time.Hour, nil
+	case 'w':
+		return time.Duration(n) * 7 * 24 * time.Hour, nil
+	}
+	return 0, fmt.Errorf("invalid duration %q", s)
+}
+
+type searchToken struct {
+	text   string
+	phrase bool
+}
+
+// searchTokens splits a query on spaces, keeping "quoted phrases" together
+func searchTokens(query string) []searchToken {
+	var tokens []searchToken
+	for {
+		query = strings.TrimSpace(query)
+		if query == "" {
+			return tokens
+		}
+		if rest, ok := strings.CutPrefix(query, `"`); ok {
+			phrase, after, _ := strings.Cut(rest, `"`)
+			if strings.TrimSpace(phrase) != "" {
+				tokens = append(tokens, searchToken{text: phrase, phrase: true})
+			}
+			query = after
+			continue
+		}
+		end := strings.IndexFunc(query, unicode.IsSpace)
+		if end < 0 {
+			end = len(query)
+		}
+		tokens = append(tokens, searchToken{text: query[:end]})
+		query = query[end:]
+	}
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func ftsQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
@@ -0,0 +1,71 @@
+package note
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSearch(t *testing.T) {
+	now := time.Date(2026, time.March, 6, 10, 0, 0, 0, time.UTC)
+	day := int64(24 * 60 * 60)
+
+	tests := []struct {
+		query string
+		want  searchQuery
+	}{
+		{"", searchQuery{}},
+		{"milk", searchQuery{match: `("milk")`}},
+		{"buy  milk", searchQuery{match: `("buy" "milk")`}},
+		{`"oat milk" bread`, searchQuery{match: `("oat milk" "bread")`}},
+		{`say "hi`, searchQuery{match: `("say" "hi")`}},
+		{`""`, searchQuery{}},
+		{"sour*", searchQuery{match: `("sour"*)`}},
+		{"milk -oat", searchQuery{match: `("milk") NOT "oat"`}},
+		{"milk -oat -soy", searchQuery{match: `("milk") NOT "oat" NOT "soy"`}},
+		{`don't AND or`, searchQuery{match: `("don't" "AND" "or")`}},
+		{`say"hi"`, searchQuery{match: `("say""hi""")`}},
+		{"milk - ...", searchQuery{match: `("milk")`}},
+		{"http://example.com", searchQuery{match: `("http://example.com")`}},
+		{"note:1", searchQuery{match: `("note:1")`}},
+
+		{"cat:Task", searchQuery{filters: []searchFilter{{`n.category = ?`, []any{"task"}}}}},
+		{"sub:today milk", searchQuery{match: `("milk")`, filters: []searchFilter{{`n.subcategory = ?`, []any{"today"}}}}},
+		{"tag:#Work", searchQuery{filters: []searchFilter{{`exists (select 1 from note_tags where note_tags.note_id = n.id and tag = ?)`, []any{"work"}}}}},
+		{"due:<7d", searchQuery{filters: []searchFilter{{`n.due is not null and n.due <= ?`, []any{now.Unix() + 7*day}}}}},
+		{"due:7d", searchQuery{filters: []searchFilter{{`n.due is not null and n.due <= ?`, []any{now.Unix() + 7*day}}}}},
+		{"due:>2w", searchQuery{filters: []searchFilter{{`n.due is not null and n.due > ?`, []any{now.Unix() + 14*day}}}}},
+		{"is:starred is:live", searchQuery{filters: []searchFilter{{`n.starred = 1`, nil}, {`n.deleted = 0`, nil}}}},
+		{"IS:Deleted", searchQuery{filters: []searchFilter{{`n.deleted = 1`, nil}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got, err := parseSearch(tt.query, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSearchErrors(t *testing.T) {
+	now := time.Date(2026, time.March, 6, 10, 0, 0, 0, time.UTC)
+	for _, query := range []string{
+		"-oat",
+		"is:pinned",
+		"due:soon",
+		"due:<",
+		"due:7m",
+		"due:-1d",
+	} {
+		t.Run(query, func(t *testing.T) {
+			got, err := parseSearch(query, now)
+			if err == nil {
+				t.Errorf("got %+v, want error", got)
+			}
+		})
+	}
+}
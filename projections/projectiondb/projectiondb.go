@@ -0,0 +1,131 @@
+package projectiondb
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	_ "modernc.org/sqlite"
+)
+
+// Open opens the sqlite database for a projection at filename, which may be
+// ":memory:". When the database was created with a different version every
+// table is dropped and schema is run again, so the projection gets rebuilt
+// from the event log. A checkpoint table is added to every schema to track
+// the last event applied.
+func Open(filename string, version int, schema []string) (*sqlx.DB, error) {
+	db, err := sqlx.Open("sqlite", filename)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`PRAGMA journal_mode = WAL`)
+	if err != nil {
+		return nil, fmt.Errorf("enable WAL: %w", err)
+	}
+	// the cli and the server may share the file
+	_, err = db.Exec(`PRAGMA busy_timeout = 5000`)
+	if err != nil {
+		return nil, fmt.Errorf("set busy_timeout: %w", err)
+	}
+
+	var current int
+	err = db.Get(&current, `PRAGMA user_version`)
+	if err != nil {
+		return nil, fmt.Errorf("get user_version: %w", err)
+	}
+
+	if current != version {
+		err = migrate(db, version, schema)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return db, nil
+}
+
+// migrate drops every table and creates the current schema
+func migrate(db *sqlx.DB, version int, schema []string) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var tables []string
+	err = tx.Select(&tables, `select name from sqlite_master where type = 'table' and name not like 'sqlite_%'`)
+	if err != nil {
+		return fmt.Errorf("select tables: %w", err)
+	}
+	for _, table := range tables {
+		// shadow tables of virtual tables are gone once the virtual table is dropped
+		_, err = tx.Exec(fmt.Sprintf(`drop table if exists %q`, table))
+		if err != nil {
+			return fmt.Errorf("drop table %s: %w", table, err)
+		}
+	}
+
+	schema = append(schema,
+		`create table checkpoint(position integer not null) strict`,
+		`insert into checkpoint(position) values(0)`,
+	)
+	for _, stmt := range schema {
+		_, err = tx.Exec(stmt)
+		if err != nil {
+			return fmt.Errorf("%s: %w", stmt, err)
+		}
+	}
+
+	_, err = tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, version))
+	if err != nil {
+		return fmt.Errorf("set user_version: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// Queryer is a projection database or a transaction on it
+type Queryer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Get(dest any, query string, args ...any) error
+	Select(dest any, query string, args ...any) error
+}
+
+// Apply runs apply in a transaction that also records sequence as the last
+// event applied, so a crash never leaves an event half applied or applied
+// without its checkpoint.
+func Apply(db *sqlx.DB, sequence int64, apply func(tx *sqlx.Tx) error) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = apply(tx)
+	if err != nil {
+		return err
+	}
+	err = Checkpoint(tx, sequence)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Position returns the sequence number of the last event applied
+func Position(db Queryer) (int64, error) {
+	var position int64
+	err := db.Get(&position, `select position from checkpoint`)
+	if err != nil {
+		return 0, fmt.Errorf("select checkpoint: %w", err)
+	}
+	return position, nil
+}
+
+// Checkpoint records that all events up to and including sequence have been applied
+func Checkpoint(db Queryer, sequence int64) error {
+	_, err := db.Exec(`update checkpoint set position = max(position, ?)`, sequence)
+	return err
+}
@@ -0,0 +1,201 @@
+package user
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/rcy/evoke"
+	"github.com/rcy/whatever/catalog/notesmeta"
+	"github.com/rcy/whatever/events"
+	"github.com/rcy/whatever/projections/projectiondb"
+)
+
+type APIToken struct {
+	ID        uuid.UUID `db:"id"`
+	Owner     string    `db:"owner"`
+	Name      string    `db:"name"`
+	Hash      string    `db:"hash"`
+	ReadOnly  bool      `db:"read_only"`
+	CreatedAt int64     `db:"created_at"`
+}
+
+// User is a user's profile and preferences
+type User struct {
+	Owner             string `db:"owner"`
+	ID                string `db:"id"`
+	DisplayName       string `db:"display_name"`
+	Email             string `db:"email"`
+	TimeZone          string `db:"time_zone"`
+	WeekStart         int    `db:"week_start"`
+	CaptureCategory   string `db:"capture_category"`
+	ClassifierEnabled bool   `db:"classifier_enabled"`
+	CreatedAt         int64  `db:"created_at"`
+}
+
+// Calendar returns how the user counts days and weeks
+func (u User) Calendar() notesmeta.Calendar {
+	return notesmeta.Calendar{
+		Location:  notesmeta.LoadLocation(u.TimeZone),
+		WeekStart: time.Weekday(u.WeekStart),
+	}
+}
+
+type Projection struct {
+	conn *sqlx.DB
+	db   projectiondb.Queryer // conn, or the transaction of the event being applied
+}
+
+// Bump schemaVersion whenever the tables below change
+const schemaVersion = 3
+
+var schema = []string{
+	`create table api_tokens(id text primary key, owner text not null, name text not null, hash text not null unique, read_only integer not null, created_at integer not null) strict`,
+	`create table users(
+		owner text primary key,
+		id text not null,
+		display_name text not null default '',
+		email text not null default '',
+		time_zone text not null default '',
+		week_start integer not null default 0,
+		capture_category text not null default 'inbox',
+		classifier_enabled integer not null default 1,
+		created_at integer not null default 0
+	) strict`,
+}
+
+// New returns an in-memory projection that must be rebuilt from the start
+// of the event log.
+func New() (*Projection, error) {
+	return Open(":memory:")
+}
+
+// Open returns a projection stored in the sqlite file at filename
+func Open(filename string) (*Projection, error) {
+	db, err := projectiondb.Open(filename, schemaVersion, schema)
+	if err != nil {
+		return nil, err
+	}
+	return &Projection{conn: db, db: db}, nil
+}
+
+// Position returns the sequence number of the last event applied to the projection
+func (p *Projection) Position() (int64, error) {
+	return projectiondb.Position(p.db)
+}
+
+// Checkpoint records that all events up to and including sequence have been applied
+func (p *Projection) Checkpoint(sequence int64) error {
+	return projectiondb.Checkpoint(p.db, sequence)
+}
+
+// Apply handles evt and checkpoints sequence in one transaction
+func (p *Projection) Apply(evt evoke.Event, replaying bool, sequence int64) error {
+	return projectiondb.Apply(p.conn, sequence, func(tx *sqlx.Tx) error {
+		in := *p
+		in.db = tx
+		return in.Handle(evt, replaying)
+	})
+}
+
+func (p *Projection) Handle(evt evoke.Event, replaying bool) error {
+	switch e := evt.(type) {
+	case events.APITokenCreated:
+		_, err := p.db.Exec(`insert into api_tokens(id, owner, name, hash, read_only, created_at) values(?,?,?,?,?,?)`,
+			e.TokenID, e.Owner, e.Name, e.Hash, e.ReadOnly, e.CreatedAt.UTC().Unix())
+		return err
+	case events.APITokenRevoked:
+		// revoked tokens are forgotten, the event log keeps the history
+		_, err := p.db.Exec(`delete from api_tokens where id = ?`, e.TokenID)
+		return err
+	case events.UserCreated:
+		return p.updateUser(e.Owner, e.UserID, `display_name = ?, email = ?, created_at = ?`, e.DisplayName, e.Email, e.CreatedAt.UTC().Unix())
+	case events.UserDisplayNameSet:
+		return p.updateUser(e.Owner, e.UserID, `display_name = ?`, e.DisplayName)
+	case events.UserTimeZoneSet:
+		return p.updateUser(e.Owner, e.UserID, `time_zone = ?`, e.TimeZone)
+	case events.UserWeekStartSet:
+		return p.updateUser(e.Owner, e.UserID, `week_start = ?`, int(e.WeekStart))
+	case events.UserCaptureCategorySet:
+		return p.updateUser(e.Owner, e.UserID, `capture_category = ?`, e.Category)
+	case events.UserClassifierEnabledSet:
+		return p.updateUser(e.Owner, e.UserID, `classifier_enabled = ?`, e.Enabled)
+	default:
+		return fmt.Errorf("user projection event not handled: %T", evt)
+	}
+}
+
+// updateUser sets columns of the user row of owner, creating the row if
+// preferences are set before the user was created
+func (p *Projection) updateUser(owner string, id uuid.UUID, set string, args ...any) error {
+	_, err := p.db.Exec(`insert into users(owner, id) values(?,?) on conflict(owner) do nothing`, owner, id)
+	if err != nil {
+		return fmt.Errorf("insert users: %w", err)
+	}
+	_, err = p.db.Exec(`update users set `+set+` where owner = ?`, append(args, owner)...)
+	if err != nil {
+		return fmt.Errorf("update users: %w", err)
+	}
+	return nil
+}
+
+func (p *Projection) FindAllAPITokens(owner string) ([]APIToken, error) {
+	var tokens []APIToken
+	err := p.db.Select(&tokens, `select * from api_tokens where owner = ? order by created_at asc`, owner)
+	if err != nil {
+		return nil, fmt.Errorf("select api_tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+// FindAPITokenByHash returns the active token with the given hash
+func (p *Projection) FindAPITokenByHash(hash string) (APIToken, error) {
+	var token APIToken
+	err := p.db.Get(&token, `select * from api_tokens where hash = ?`, hash)
+	if err != nil {
+		return APIToken{}, err
+	}
+	return token, nil
+}
+
+// FindUser returns the user row of owner, sql.ErrNoRows if they have
+// neither logged in nor set any preferences
+func (p *Projection) FindUser(owner string) (User, error) {
+	var u User
+	err := p.db.Get(&u, `select * from users where owner = ?`, owner)
+	if err != nil {
+		return User{}, err
+	}
+	return u, nil
+}
+
+// Preferences returns the user of owner, with default preferences if they
+// have not set any or they cannot be read
+func (p *Projection) Preferences(owner string) User {
+	u, err := p.FindUser(owner)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("user: preferences of %s: %s", owner, err)
+		}
+		return User{
+			Owner:             owner,
+			CaptureCategory:   notesmeta.Inbox.Slug,
+			ClassifierEnabled: true,
+		}
+	}
+	return u
+}
+
+// Calendar returns how owner counts days and weeks
+func (p *Projection) Calendar(owner string) notesmeta.Calendar {
+	return p.Preferences(owner).Calendar()
+}
+
+// ClassifierEnabled reports whether new notes of owner should be classified
+func (p *Projection) ClassifierEnabled(owner string) bool {
+	return p.Preferences(owner).ClassifierEnabled
+}
@@ -0,0 +1,646 @@
+package web
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/rcy/evoke"
+	"github.com/rcy/whatever/api"
+	"github.com/rcy/whatever/app"
+	"github.com/rcy/whatever/catalog/notesmeta"
+	"github.com/rcy/whatever/commands"
+	"github.com/rcy/whatever/projections/note"
+	"github.com/rcy/whatever/workers/classify"
+	googleoauth "google.golang.org/api/oauth2/v2"
+)
+
+// apiRouter serves the json api mounted at /api/v1. Requests must already
+// be authenticated.
+func (s *webservice) apiRouter(r chi.Router) {
+	r.Get("/me", s.apiMe)
+	r.Patch("/me", s.apiUpdatePreferences)
+	r.Put("/me/timezone", s.apiSetTimeZone)
+	r.Get("/notes", s.apiListNotes)
+	r.Post("/notes", s.apiCreateNote)
+	r.Get("/notes/deleted", s.apiListDeletedNotes)
+	r.Get("/notes/{id}", s.apiGetNote)
+	r.Patch("/notes/{id}", s.apiEditNote)
+	r.Delete("/notes/{id}", s.apiDeleteNote)
+	r.Post("/notes/{id}/undelete", s.apiUndeleteNote)
+	r.Put("/notes/{id}/category", s.apiSetNoteCategory)
+	r.Post("/notes/{id}/transitions", s.apiTransitionNote)
+	r.Put("/notes/{id}/star", s.apiStarNote)
+	r.Delete("/notes/{id}/star", s.apiUnstarNote)
+	r.Put("/notes/{id}/due", s.apiSetNoteDue)
+	r.Delete("/notes/{id}/due", s.apiClearNoteDue)
+	r.Put("/notes/{id}/recurrence", s.apiSetNoteRecurrence)
+	r.Delete("/notes/{id}/recurrence", s.apiClearNoteRecurrence)
+	r.Get("/notes/{id}/history", s.apiNoteHistory)
+	r.Post("/notes/{id}/enrich", s.apiEnrichNote)
+	r.Put("/notes/{id}/tags/{tag}", s.apiAddNoteTag)
+	r.Delete("/notes/{id}/tags/{tag}", s.apiRemoveNoteTag)
+	r.Get("/tags", s.apiListTags)
+	r.Get("/search", s.apiSearch)
+	r.Get("/people", s.apiListPeople)
+	r.Get("/people/{handle}", s.apiGetPerson)
+	r.Patch("/people/{handle}", s.apiUpdatePerson)
+	r.Put("/people/{handle}/merge", s.apiMergePerson)
+	r.Delete("/people/{handle}/merge", s.apiUnmergePerson)
+	r.Post("/classify", s.apiClassify)
+}
+
+// API serves the json api as owner without authentication, for clients
+// running in the same process as the app
+func API(app *app.App, owner string) http.Handler {
+	svc := webservice{app: app}
+	user := googleoauth.Userinfo{Id: owner}
+
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(withUser(r.Context(), user)))
+		})
+	})
+	svc.apiRouter(r)
+	return r
+}
+
+func newAPINote(n note.Note, deleted bool) api.Note {
+	an := api.Note{
+		ID:          n.ID,
+		CreatedAt:   time.Unix(n.Ts, 0).UTC(),
+		Text:        n.Text,
+		DisplayText: n.DisplayText(),
+		Category:    n.Category,
+		Subcategory: n.Subcategory,
+		Starred:     n.Starred,
+		Status:      n.Status,
+		Deleted:     deleted,
+
+		DeferredCount: n.DeferredCount,
+		ReopenedCount: n.ReopenedCount,
+		Recurrence:    n.Recurrence,
+	}
+	if n.Due != nil {
+		due := time.Unix(*n.Due, 0).UTC()
+		an.Due = &due
+	}
+	if n.CompletedAt != nil {
+		completedAt := time.Unix(*n.CompletedAt, 0).UTC()
+		an.CompletedAt = &completedAt
+	}
+	if n.SuggestedCategory != "" {
+		an.Suggestion = &api.Suggestion{
+			Category:    n.SuggestedCategory,
+			Subcategory: n.SuggestedSubcategory,
+			Timeframe:   n.SuggestedTimeframe,
+		}
+	}
+	if n.LinkTitle != "" || n.LinkThumbnail != "" {
+		an.Link = &api.Link{
+			Title:       n.LinkTitle,
+			Description: n.LinkDescription,
+			SiteName:    n.LinkSiteName,
+			URL:         n.LinkURL,
+			Thumbnail:   n.LinkThumbnail,
+			ContentType: n.LinkContentType,
+		}
+	}
+	return an
+}
+
+func newAPINoteList(noteList []note.Note, deleted bool) []api.Note {
+	out := make([]api.Note, len(noteList))
+	for i, n := range noteList {
+		out[i] = newAPINote(n, deleted)
+	}
+	return out
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, code string, err error) {
+	writeJSON(w, status, api.ErrorResponse{Error: api.ErrorBody{Code: code, Message: err.Error()}})
+}
+
+func (s *webservice) apiAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, err := s.authenticate(r)
+		if errors.Is(err, errReadOnlyToken) {
+			writeAPIError(w, http.StatusForbidden, "forbidden", err)
+			return
+		}
+		if err != nil {
+			writeAPIError(w, http.StatusUnauthorized, "unauthorized", err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(withUser(r.Context(), user)))
+	})
+}
+
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(v)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "bad_request", fmt.Errorf("invalid json body: %w", err))
+		return false
+	}
+	return true
+}
+
+// apiFindNote returns the note in the id url param, which may be a unique
+// prefix of the id, if it belongs to the current user. Deleted notes are only returned if includeDeleted is set.
+// On failure an error response has been written and ok is false.
+func (s *webservice) apiFindNote(w http.ResponseWriter, r *http.Request, includeDeleted bool) (n note.Note, deleted bool, ok bool) {
+	owner := getUserInfo(r).Id
+	id, err := s.app.Notes.ResolveID(owner, chi.URLParam(r, "id"))
+	var ambiguous *note.AmbiguousIDError
+	switch {
+	case errors.As(err, &ambiguous):
+		writeAPIError(w, http.StatusConflict, "ambiguous", err)
+		return note.Note{}, false, false
+	case errors.Is(err, sql.ErrNoRows):
+		writeAPIError(w, http.StatusNotFound, "not_found", err)
+		return note.Note{}, false, false
+	case errors.Is(err, note.ErrInvalidID):
+		writeAPIError(w, http.StatusBadRequest, "bad_request", err)
+		return note.Note{}, false, false
+	case err != nil:
+		writeAPIError(w, http.StatusInternalServerError, "internal", err)
+		return note.Note{}, false, false
+	}
+
+	n, err = s.app.Notes.FindOne(id.String())
+	if errors.Is(err, sql.ErrNoRows) && includeDeleted {
+		deleted = true
+		n, err = s.app.Notes.FindOneDeleted(id.String())
+	}
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && n.Owner != owner) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Errorf("note %s not found", id))
+		return note.Note{}, false, false
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "internal", err)
+		return note.Note{}, false, false
+	}
+	return n, deleted, true
+}
+
+// apiSend sends cmd and responds with the note's new state. Commands
+// rejected by the aggregate are reported as 422.
+func (s *webservice) apiSend(w http.ResponseWriter, r *http.Request, cmd evoke.Command, status int) {
+	err := s.app.Commander.Send(cmd)
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "rejected", err)
+		return
+	}
+	s.apiWriteNote(w, cmd.AggregateID(), status)
+}
+
+// apiWriteNote responds with the note noteID, which may be deleted
+func (s *webservice) apiWriteNote(w http.ResponseWriter, noteID uuid.UUID, status int) {
+	id := noteID.String()
+	deleted := false
+	n, err := s.app.Notes.FindOne(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		deleted = true
+		n, err = s.app.Notes.FindOneDeleted(id)
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "internal", err)
+		return
+	}
+	s.writeAPINote(w, n, deleted, status)
+}
+
+// writeAPINote responds with n along with its tags
+func (s *webservice) writeAPINote(w http.ResponseWriter, n note.Note, deleted bool, status int) {
+	out := newAPINote(n, deleted)
+	tags, err := s.app.Notes.NoteTags(n.ID.String())
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "internal", err)
+		return
+	}
+	out.Tags = tags
+	links, err := s.app.Notes.FindLinks(n.ID.String())
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "internal", err)
+		return
+	}
+	for _, l := range links {
+		var archivedAt *time.Time
+		if l.ArchivedAt != nil {
+			t := time.Unix(*l.ArchivedAt, 0).UTC()
+			archivedAt = &t
+		}
+		out.Links = append(out.Links, api.Link{
+			Title:        l.Title,
+			Description:  l.Description,
+			SiteName:     l.SiteName,
+			URL:          l.URL,
+			CanonicalURL: l.CanonicalURL,
+			Thumbnail:    l.Thumbnail,
+			ContentType:  l.ContentType,
+			Status:       l.Status,
+			Error:        l.Error,
+			ArchivedAt:   archivedAt,
+		})
+	}
+	writeJSON(w, status, out)
+}
+
+func (s *webservice) apiListNotes(w http.ResponseWriter, r *http.Request) {
+	owner := getUserInfo(r).Id
+	query := r.URL.Query()
+
+	filter := note.Filter{
+		Category:    query.Get("category"),
+		Subcategory: query.Get("subcategory"),
+		Person:      query.Get("person"),
+		Tag:         query.Get("tag"),
+
+		EnrichmentFailed: query.Get("enrichment") == "failed",
+	}
+
+	if timeframe := query.Get("due"); timeframe != "" {
+		start, end, err := notesmeta.TimeframeRange(timeframe, s.calendar(r))
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, "bad_request", fmt.Errorf("invalid due timeframe %q", timeframe))
+			return
+		}
+		filter.DueAfter = &start
+		filter.DueBefore = &end
+	}
+	for param, dst := range map[string]**time.Time{
+		"dueAfter":        &filter.DueAfter,
+		"dueBefore":       &filter.DueBefore,
+		"completedAfter":  &filter.CompletedAfter,
+		"completedBefore": &filter.CompletedBefore,
+	} {
+		value := query.Get(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, "bad_request", fmt.Errorf("invalid %s: %w", param, err))
+			return
+		}
+		*dst = &t
+	}
+
+	noteList, err := s.app.Notes.FindAllByFilter(owner, filter)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "internal", err)
+		return
+	}
+	slices.Reverse(noteList)
+
+	writeJSON(w, http.StatusOK, map[string]any{"notes": newAPINoteList(noteList, false)})
+}
+
+func (s *webservice) apiListDeletedNotes(w http.ResponseWriter, r *http.Request) {
+	noteList, err := s.app.Notes.FindAllDeleted(getUserInfo(r).Id)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "internal", err)
+		return
+	}
+	slices.Reverse(noteList)
+
+	writeJSON(w, http.StatusOK, map[string]any{"notes": newAPINoteList(noteList, true)})
+}
+
+func (s *webservice) apiGetNote(w http.ResponseWriter, r *http.Request) {
+	n, deleted, ok := s.apiFindNote(w, r, true)
+	if !ok {
+		return
+	}
+	s.writeAPINote(w, n, deleted, http.StatusOK)
+}
+
+func (s *webservice) apiSetNoteRecurrence(w http.ResponseWriter, r *http.Request) {
+	n, _, ok := s.apiFindNote(w, r, false)
+	if !ok {
+		return
+	}
+	var body struct {
+		Rule string `json:"rule"`
+	}
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+	s.apiSend(w, r, commands.SetNoteRecurrence{NoteID: n.ID, Rule: body.Rule}, http.StatusOK)
+}
+
+func (s *webservice) apiClearNoteRecurrence(w http.ResponseWriter, r *http.Request) {
+	n, _, ok := s.apiFindNote(w, r, false)
+	if !ok {
+		return
+	}
+	s.apiSend(w, r, commands.ClearNoteRecurrence{NoteID: n.ID}, http.StatusOK)
+}
+
+func (s *webservice) apiAddNoteTag(w http.ResponseWriter, r *http.Request) {
+	n, _, ok := s.apiFindNote(w, r, false)
+	if !ok {
+		return
+	}
+	s.apiSend(w, r, commands.AddNoteTag{NoteID: n.ID, Tag: chi.URLParam(r, "tag")}, http.StatusOK)
+}
+
+func (s *webservice) apiRemoveNoteTag(w http.ResponseWriter, r *http.Request) {
+	n, _, ok := s.apiFindNote(w, r, false)
+	if !ok {
+		return
+	}
+	s.apiSend(w, r, commands.RemoveNoteTag{NoteID: n.ID, Tag: chi.URLParam(r, "tag")}, http.StatusOK)
+}
+
+func (s *webservice) apiListTags(w http.ResponseWriter, r *http.Request) {
+	counts, err := s.app.Notes.TagCounts(getUserInfo(r).Id)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "internal", err)
+		return
+	}
+	out := make([]api.TagCount, len(counts))
+	for i, c := range counts {
+		out[i] = api.TagCount{Tag: c.Tag, Count: c.Count}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"tags": out})
+}
+
+func (s *webservice) apiNoteHistory(w http.ResponseWriter, r *http.Request) {
+	n, _, ok := s.apiFindNote(w, r, true)
+	if !ok {
+		return
+	}
+	history, err := s.app.Notes.TaskHistory(n.ID.String())
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "internal", err)
+		return
+	}
+	out := make([]api.TaskEvent, len(history))
+	for i, e := range history {
+		out[i] = newAPITaskEvent(e)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"history": out})
+}
+
+func newAPITaskEvent(e note.TaskEvent) api.TaskEvent {
+	unix := func(ts *int64) *time.Time {
+		if ts == nil {
+			return nil
+		}
+		t := time.Unix(*ts, 0).UTC()
+		return &t
+	}
+	return api.TaskEvent{
+		Kind: e.Kind,
+		At:   time.Unix(e.Ts, 0).UTC(),
+		From: unix(e.From),
+		To:   unix(e.To),
+	}
+}
+
+func (s *webservice) apiCreateNote(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Text        string `json:"text"`
+		Category    string `json:"category"`
+		Subcategory string `json:"subcategory"`
+	}
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+
+	if body.Category == "" {
+		body.Category = s.app.Users.Preferences(getUserInfo(r).Id).CaptureCategory
+	}
+	if !slices.ContainsFunc(notesmeta.Categories, func(c notesmeta.Category) bool { return c.Slug == body.Category }) {
+		writeAPIError(w, http.StatusBadRequest, "bad_request", fmt.Errorf("unknown category %q", body.Category))
+		return
+	}
+	category := notesmeta.Categories.Get(body.Category)
+	if body.Subcategory == "" {
+		body.Subcategory = category.Inbox().Slug
+	}
+	if !slices.ContainsFunc(category.Subcategories, func(c notesmeta.Subcategory) bool { return c.Slug == body.Subcategory }) {
+		writeAPIError(w, http.StatusBadRequest, "bad_request", fmt.Errorf("unknown subcategory %q", body.Subcategory))
+		return
+	}
+
+	noteID, err := s.app.CaptureNote(getUserInfo(r).Id, body.Text, body.Category, body.Subcategory)
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "rejected", err)
+		return
+	}
+	s.apiWriteNote(w, noteID, http.StatusCreated)
+}
+
+func (s *webservice) apiEditNote(w http.ResponseWriter, r *http.Request) {
+	n, _, ok := s.apiFindNote(w, r, false)
+	if !ok {
+		return
+	}
+	var body struct {
+		Text string `json:"text"`
+	}
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+	s.apiSend(w, r, commands.UpdateNoteText{NoteID: n.ID, Text: body.Text}, http.StatusOK)
+}
+
+func (s *webservice) apiDeleteNote(w http.ResponseWriter, r *http.Request) {
+	n, _, ok := s.apiFindNote(w, r, false)
+	if !ok {
+		return
+	}
+	s.apiSend(w, r, commands.DeleteNote{NoteID: n.ID}, http.StatusOK)
+}
+
+func (s *webservice) apiUndeleteNote(w http.ResponseWriter, r *http.Request) {
+	n, _, ok := s.apiFindNote(w, r, true)
+	if !ok {
+		return
+	}
+	s.apiSend(w, r, commands.UndeleteNote{NoteID: n.ID}, http.StatusOK)
+}
+
+func (s *webservice) apiEnrichNote(w http.ResponseWriter, r *http.Request) {
+	n, _, ok := s.apiFindNote(w, r, false)
+	if !ok {
+		return
+	}
+	var body struct {
+		URL string `json:"url"`
+	}
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+	s.apiSend(w, r, commands.RequestNoteEnrichment{NoteID: n.ID, URL: body.URL}, http.StatusOK)
+}
+
+func (s *webservice) apiSetNoteCategory(w http.ResponseWriter, r *http.Request) {
+	n, _, ok := s.apiFindNote(w, r, false)
+	if !ok {
+		return
+	}
+	var body struct {
+		Category string `json:"category"`
+	}
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+	if !slices.ContainsFunc(notesmeta.RefileCategories, func(c notesmeta.Category) bool { return c.Slug == body.Category }) {
+		writeAPIError(w, http.StatusBadRequest, "bad_request", fmt.Errorf("unknown category %q", body.Category))
+		return
+	}
+	s.apiSend(w, r, commands.SetNoteCategory{NoteID: n.ID, Category: body.Category, Actor: "user"}, http.StatusOK)
+}
+
+func (s *webservice) apiTransitionNote(w http.ResponseWriter, r *http.Request) {
+	n, _, ok := s.apiFindNote(w, r, false)
+	if !ok {
+		return
+	}
+	var body struct {
+		Event string `json:"event"`
+	}
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+	s.apiSend(w, r, commands.TransitionNoteSubcategory{NoteID: n.ID, TransitionEvent: body.Event, Actor: "user"}, http.StatusOK)
+}
+
+func (s *webservice) apiStarNote(w http.ResponseWriter, r *http.Request) {
+	n, _, ok := s.apiFindNote(w, r, false)
+	if !ok {
+		return
+	}
+	s.apiSend(w, r, commands.StarNote{NoteID: n.ID}, http.StatusOK)
+}
+
+func (s *webservice) apiUnstarNote(w http.ResponseWriter, r *http.Request) {
+	n, _, ok := s.apiFindNote(w, r, false)
+	if !ok {
+		return
+	}
+	s.apiSend(w, r, commands.UnstarNote{NoteID: n.ID}, http.StatusOK)
+}
+
+func (s *webservice) apiSetNoteDue(w http.ResponseWriter, r *http.Request) {
+	n, _, ok := s.apiFindNote(w, r, false)
+	if !ok {
+		return
+	}
+	var body struct {
+		Due time.Time `json:"due"`
+	}
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+	if body.Due.IsZero() {
+		writeAPIError(w, http.StatusBadRequest, "bad_request", errors.New("due is required"))
+		return
+	}
+	s.apiSend(w, r, commands.SetNoteDue{NoteID: n.ID, Due: body.Due}, http.StatusOK)
+}
+
+func (s *webservice) apiClearNoteDue(w http.ResponseWriter, r *http.Request) {
+	n, _, ok := s.apiFindNote(w, r, false)
+	if !ok {
+		return
+	}
+	s.apiSend(w, r, commands.ClearNoteDue{NoteID: n.ID}, http.StatusOK)
+}
+
+func (s *webservice) apiMe(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, me(s.app.Users.Preferences(getUserInfo(r).Id)))
+}
+
+func (s *webservice) apiUpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	var body api.Preferences
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+	err := s.updatePreferences(getUserInfo(r).Id, body)
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "rejected", err)
+		return
+	}
+	s.apiMe(w, r)
+}
+
+func (s *webservice) apiSetTimeZone(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		TimeZone string `json:"timeZone"`
+	}
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+	err := s.updatePreferences(getUserInfo(r).Id, api.Preferences{TimeZone: &body.TimeZone})
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "rejected", err)
+		return
+	}
+	s.apiMe(w, r)
+}
+
+// apiClassify runs the classifier on the inbox and on unsorted references,
+// filing the notes it is confident about
+func (s *webservice) apiClassify(w http.ResponseWriter, r *http.Request) {
+	owner := getUserInfo(r).Id
+
+	noteList, err := s.app.Notes.FindAllByCategory(owner, notesmeta.Inbox.Slug)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "internal", err)
+		return
+	}
+	unsorted, err := s.app.Notes.FindAllByCategoryAndSubcategory(owner, notesmeta.Note.Slug, notesmeta.Note.Inbox().Slug)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "internal", err)
+		return
+	}
+	noteList = append(noteList, unsorted...)
+
+	classifications := []api.Classification{}
+	for _, n := range noteList {
+		result, err := classify.Classify(r.Context(), s.app.Classifier, n.Text)
+		if err != nil {
+			writeAPIError(w, http.StatusBadGateway, "classifier", err)
+			return
+		}
+		err = classify.File(s.app.Commander, n, result)
+		if err != nil {
+			writeAPIError(w, http.StatusUnprocessableEntity, "rejected", err)
+			return
+		}
+		filed, err := s.app.Notes.FindOne(n.ID.String())
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, "internal", err)
+			return
+		}
+		classifications = append(classifications, api.Classification{
+			Note:        newAPINote(filed, false),
+			Category:    result.Category,
+			Subcategory: result.Subcategory,
+			Timeframe:   result.Timeframe,
+			Confidence:  result.Confidence,
+			Filed:       filed.Category != n.Category || filed.Subcategory != n.Subcategory,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"classifications": classifications})
+}
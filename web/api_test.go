@@ -0,0 +1,230 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rcy/whatever/app"
+	"github.com/rcy/whatever/workers/classify"
+	googleoauth "google.golang.org/api/oauth2/v2"
+)
+
+const testSessionSecret = "test secret"
+
+// newTestServer returns the web server of an app with its event log and job
+// queue in a temporary directory
+func newTestServer(t *testing.T) (*app.App, http.Handler) {
+	t.Helper()
+	dir := t.TempDir()
+	a, err := app.New(app.Config{
+		EventFile:  filepath.Join(dir, "evoke.db"),
+		JobsFile:   filepath.Join(dir, "jobs.db"),
+		BlobsDir:   filepath.Join(dir, "blobs"),
+		Classifier: classify.Rules{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler, err := Server(a, Config{
+		BaseURL:            "http://localhost",
+		GoogleClientID:     "client",
+		GoogleClientSecret: "secret",
+		SessionSecret:      testSessionSecret,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a, handler
+}
+
+// sessionCookie returns a browser session cookie for owner
+func sessionCookie(t *testing.T, owner string) *http.Cookie {
+	t.Helper()
+	sessions, err := newSessionManager(testSessionSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := sessions.sign(sessionPayload{
+		UserInfo:  googleoauth.Userinfo{Id: owner},
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &http.Cookie{Name: sessionCookieName, Value: token}
+}
+
+// testNote is the part of a note in api responses the tests look at
+type testNote struct {
+	ID          string     `json:"id"`
+	Text        string     `json:"text"`
+	Category    string     `json:"category"`
+	Subcategory string     `json:"subcategory"`
+	Starred     bool       `json:"starred"`
+	Deleted     bool       `json:"deleted"`
+	Due         *time.Time `json:"due"`
+}
+
+type testError struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// apiRequest sends a request with a json body to the api, authenticated
+// with auth, and decodes the response into out unless it is nil
+func apiRequest(t *testing.T, handler http.Handler, auth func(*http.Request), method string, path string, body any, out any) int {
+	t.Helper()
+	var reqBody bytes.Buffer
+	if body != nil {
+		if s, ok := body.(string); ok {
+			reqBody.WriteString(s)
+		} else if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, "/api/v1"+path, &reqBody)
+	if auth != nil {
+		auth(req)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if out != nil {
+		err := json.NewDecoder(w.Body).Decode(out)
+		if err != nil {
+			t.Fatalf("%s %s: decode %q: %s", method, path, w.Body.String(), err)
+		}
+	}
+	return w.Code
+}
+
+func asOwner(t *testing.T, owner string) func(*http.Request) {
+	cookie := sessionCookie(t, owner)
+	return func(r *http.Request) { r.AddCookie(cookie) }
+}
+
+func TestAPINoteLifecycle(t *testing.T) {
+	_, handler := newTestServer(t)
+	alice := asOwner(t, "alice")
+
+	var created testNote
+	status := apiRequest(t, handler, alice, "POST", "/notes", map[string]string{"text": "buy milk", "category": "task"}, &created)
+	if status != http.StatusCreated || created.Text != "buy milk" || created.Category != "task" || created.Subcategory != "notnow" {
+		t.Fatalf("create: %d %+v", status, created)
+	}
+	path := "/notes/" + created.ID
+
+	var got testNote
+	status = apiRequest(t, handler, alice, "GET", path, nil, &got)
+	if status != http.StatusOK || got != created {
+		t.Errorf("get: %d %+v, want %+v", status, got, created)
+	}
+
+	var list struct{ Notes []testNote }
+	status = apiRequest(t, handler, alice, "GET", "/notes?category=task", nil, &list)
+	if status != http.StatusOK || len(list.Notes) != 1 || list.Notes[0].ID != created.ID {
+		t.Errorf("list: %d %+v", status, list)
+	}
+	status = apiRequest(t, handler, alice, "GET", "/notes?category=reference", nil, &list)
+	if status != http.StatusOK || len(list.Notes) != 0 {
+		t.Errorf("list other category: %d %+v", status, list)
+	}
+
+	status = apiRequest(t, handler, alice, "PATCH", path, map[string]string{"text": "buy oat milk"}, &got)
+	if status != http.StatusOK || got.Text != "buy oat milk" {
+		t.Errorf("edit: %d %+v", status, got)
+	}
+
+	status = apiRequest(t, handler, alice, "PUT", path+"/star", nil, &got)
+	if status != http.StatusOK || !got.Starred {
+		t.Errorf("star: %d %+v", status, got)
+	}
+
+	due := time.Date(2030, time.January, 2, 15, 0, 0, 0, time.UTC)
+	status = apiRequest(t, handler, alice, "PUT", path+"/due", map[string]time.Time{"due": due}, &got)
+	if status != http.StatusOK || got.Due == nil || !got.Due.Equal(due) {
+		t.Errorf("due: %d %+v", status, got)
+	}
+	status = apiRequest(t, handler, alice, "DELETE", path+"/due", nil, &got)
+	if status != http.StatusOK || got.Due != nil {
+		t.Errorf("clear due: %d %+v", status, got)
+	}
+
+	status = apiRequest(t, handler, alice, "DELETE", path, nil, &got)
+	if status != http.StatusOK || !got.Deleted {
+		t.Errorf("delete: %d %+v", status, got)
+	}
+	status = apiRequest(t, handler, alice, "GET", path, nil, &got)
+	if status != http.StatusOK || !got.Deleted {
+		t.Errorf("get deleted: %d %+v", status, got)
+	}
+	status = apiRequest(t, handler, alice, "GET", "/notes?category=task", nil, &list)
+	if status != http.StatusOK || len(list.Notes) != 0 {
+		t.Errorf("list after delete: %d %+v", status, list)
+	}
+	status = apiRequest(t, handler, alice, "GET", "/notes/deleted", nil, &list)
+	if status != http.StatusOK || len(list.Notes) != 1 || !list.Notes[0].Deleted {
+		t.Errorf("list deleted: %d %+v", status, list)
+	}
+
+	status = apiRequest(t, handler, alice, "POST", path+"/undelete", nil, &got)
+	if status != http.StatusOK || got.Deleted {
+		t.Errorf("undelete: %d %+v", status, got)
+	}
+}
+
+func TestAPIErrors(t *testing.T) {
+	_, handler := newTestServer(t)
+	alice := asOwner(t, "alice")
+	bob := asOwner(t, "bob")
+
+	var created testNote
+	status := apiRequest(t, handler, alice, "POST", "/notes", map[string]string{"text": "a secret", "category": "task"}, &created)
+	if status != http.StatusCreated {
+		t.Fatalf("create: %d", status)
+	}
+
+	tests := []struct {
+		name   string
+		auth   func(*http.Request)
+		method string
+		path   string
+		body   any
+		status int
+		code   string
+	}{
+		{"no session", nil, "GET", "/notes", nil, http.StatusUnauthorized, "unauthorized"},
+		{"invalid json", alice, "POST", "/notes", "{", http.StatusBadRequest, "bad_request"},
+		{"unknown field", alice, "POST", "/notes", map[string]string{"txt": "typo"}, http.StatusBadRequest, "bad_request"},
+		{"unknown category", alice, "POST", "/notes", map[string]string{"text": "x", "category": "nope"}, http.StatusBadRequest, "bad_request"},
+		{"unknown subcategory", alice, "POST", "/notes", map[string]string{"text": "x", "category": "task", "subcategory": "nope"}, http.StatusBadRequest, "bad_request"},
+		{"missing note", alice, "GET", "/notes/4b1f3c2e-8d6a-4e5f-9c1b-2a7d4e6f8091", nil, http.StatusNotFound, "not_found"},
+		{"note of someone else", bob, "GET", "/notes/" + created.ID, nil, http.StatusNotFound, "not_found"},
+		{"edit note of someone else", bob, "PATCH", "/notes/" + created.ID, map[string]string{"text": "mine"}, http.StatusNotFound, "not_found"},
+		{"unknown transition", alice, "POST", "/notes/" + created.ID + "/transitions", map[string]string{"event": "nope"}, http.StatusUnprocessableEntity, "rejected"},
+		{"missing due", alice, "PUT", "/notes/" + created.ID + "/due", map[string]string{}, http.StatusBadRequest, "bad_request"},
+		{"invalid due filter", alice, "GET", "/notes?due=fortnight", nil, http.StatusBadRequest, "bad_request"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got testError
+			status := apiRequest(t, handler, tt.auth, tt.method, tt.path, tt.body, &got)
+			if status != tt.status || got.Error.Code != tt.code || got.Error.Message == "" {
+				t.Errorf("got %d %+v, want %d %s", status, got, tt.status, tt.code)
+			}
+		})
+	}
+
+	var got testNote
+	apiRequest(t, handler, alice, "GET", "/notes/"+created.ID, nil, &got)
+	if got.Text != "a secret" {
+		t.Errorf("note changed to %q", got.Text)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"crypto/subtle"
+	"database/sql"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -15,6 +16,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/rcy/whatever/catalog/notesmeta"
+	"github.com/rcy/whatever/commands"
 	"golang.org/x/oauth2"
 	googleoauth "google.golang.org/api/oauth2/v2"
 	"google.golang.org/api/option"
@@ -241,7 +244,15 @@ func (s *webservice) secureCookie(r *http.Request) bool {
 
 func (s *webservice) authMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		user, err := s.sessions.currentUser(r)
+		user, err := s.authenticate(r)
+		if errors.Is(err, errReadOnlyToken) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if _, ok := bearerToken(r); ok && err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
 		if err != nil {
 			redirect := "/auth"
 			if r.URL.Path != "/auth" {
@@ -250,8 +261,19 @@ func (s *webservice) authMiddleware(next http.Handler) http.Handler {
 			http.Redirect(w, r, redirect, http.StatusSeeOther)
 			return
 		}
-		ctx := context.WithValue(r.Context(), UserContextKey, user)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		next.ServeHTTP(w, r.WithContext(withUser(r.Context(), user)))
+	})
+}
+
+// sessionOnly refuses requests authenticated with an api token, so a leaked
+// token cannot be used to mint new tokens or revoke the others
+func sessionOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := bearerToken(r); ok {
+			http.Error(w, "api tokens can only be managed from a browser session", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
 	})
 }
 
@@ -327,6 +349,11 @@ func (s *webservice) authCallbackHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if err := s.ensureUser(*info); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	if err := s.sessions.issue(w, r, *info, secure); err != nil {
 		// http.Error(w, fmt.Sprintf("issue session: %s", err), http.StatusInternalServerError)
 		http.Redirect(w, r, "/auth", http.StatusSeeOther)
@@ -343,6 +370,32 @@ func (s *webservice) logoutHandler(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/auth", http.StatusSeeOther)
 }
 
+func withUser(ctx context.Context, user googleoauth.Userinfo) context.Context {
+	return context.WithValue(ctx, UserContextKey, user)
+}
+
 func getUserInfo(r *http.Request) googleoauth.Userinfo {
 	return r.Context().Value(UserContextKey).(googleoauth.Userinfo)
 }
+
+// calendar returns how the current user counts days and weeks
+func (s *webservice) calendar(r *http.Request) notesmeta.Calendar {
+	return s.app.Users.Calendar(getUserInfo(r).Id)
+}
+
+// ensureUser creates the user on their first login
+func (s *webservice) ensureUser(info googleoauth.Userinfo) error {
+	_, err := s.app.Users.FindUser(info.Id)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("FindUser: %w", err)
+	}
+	return s.app.Commander.Send(commands.CreateUser{
+		UserID:      commands.UserID(info.Id),
+		Owner:       info.Id,
+		DisplayName: info.Name,
+		Email:       info.Email,
+	})
+}
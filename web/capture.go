@@ -4,10 +4,12 @@ import (
 	"fmt"
 	"net/http"
 	"slices"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/rcy/evoke"
 	"github.com/rcy/whatever/catalog/notesmeta"
 	"github.com/rcy/whatever/commands"
 	"github.com/rcy/whatever/projections/note"
@@ -30,10 +32,10 @@ var captureStyles = g.Raw(`
 `)
 
 func captureNavWithRequest(r *http.Request, postAction string) g.Node {
-	return captureNav(postAction, getUserInfo(r).Picture, r.URL.Path)
+	return captureNav(postAction, getUserInfo(r).Picture, r.URL.Path, r.URL.Query().Get("q"))
 }
 
-func captureNav(postAction, pictureURL, activePath string) g.Node {
+func captureNav(postAction, pictureURL, activePath, query string) g.Node {
 	navItem := func(href, label string) g.Node {
 		if activePath == href {
 			return h.Span(h.Style("font-weight:bold"), g.Text(label))
@@ -60,6 +62,12 @@ func captureNav(postAction, pictureURL, activePath string) g.Node {
 				h.AutoComplete("off"),
 			),
 		),
+		h.Form(
+			h.Method("GET"),
+			h.Action("/capture/search"),
+			h.Style("margin:0"),
+			h.Input(h.Type("search"), h.Name("q"), h.Value(query), h.Placeholder("search..."), h.AutoComplete("off"), h.Style("width:10em")),
+		),
 		h.A(h.Href("/settings"), h.Style("display:flex; align-items:center"),
 			h.Img(h.Src(pictureURL), h.Style("width:1.5em; height:1.5em; border-radius:50%")),
 		),
@@ -81,21 +89,19 @@ func capturePage(body g.Node) g.Node {
 }
 
 func (s *webservice) captureIndex(w http.ResponseWriter, r *http.Request) {
+	if s.app.Users.Preferences(getUserInfo(r).Id).CaptureCategory == notesmeta.Note.Slug {
+		http.Redirect(w, r, "/capture/reference", http.StatusSeeOther)
+		return
+	}
 	http.Redirect(w, r, "/capture/tasks", http.StatusSeeOther)
 }
 
 func (s *webservice) postCaptureTask(w http.ResponseWriter, r *http.Request) {
 	userInfo := getUserInfo(r)
 	if body := r.FormValue("body"); body != "" {
-		err := s.app.Commander.Send(commands.CreateNote{
-			Owner:       userInfo.Id,
-			NoteID:      uuid.New(),
-			Text:        body,
-			Category:    notesmeta.Task.Slug,
-			Subcategory: notesmeta.Task.Inbox().Slug,
-		})
+		_, err := s.app.CaptureNote(userInfo.Id, body, notesmeta.Task.Slug, notesmeta.Task.Inbox().Slug)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 	}
@@ -105,15 +111,9 @@ func (s *webservice) postCaptureTask(w http.ResponseWriter, r *http.Request) {
 func (s *webservice) postCaptureReference(w http.ResponseWriter, r *http.Request) {
 	userInfo := getUserInfo(r)
 	if body := r.FormValue("body"); body != "" {
-		err := s.app.Commander.Send(commands.CreateNote{
-			Owner:       userInfo.Id,
-			NoteID:      uuid.New(),
-			Text:        body,
-			Category:    notesmeta.Note.Slug,
-			Subcategory: notesmeta.Note.Inbox().Slug,
-		})
+		_, err := s.app.CaptureNote(userInfo.Id, body, notesmeta.Note.Slug, notesmeta.Note.Inbox().Slug)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 	}
@@ -156,17 +156,18 @@ func (s *webservice) captureTasksIndex(w http.ResponseWriter, r *http.Request) {
 	}
 	slices.Reverse(done)
 
+	cal := s.calendar(r)
 	capturePage(g.Group{
 		captureNavWithRequest(r, "/capture/tasks"),
-		captureNotnowSection(notnow),
-		g.Group(g.Map(partitionScheduled(scheduled), func(b scheduledBucket) g.Node {
+		captureNotnowSection(notnow, cal.Location),
+		g.Group(g.Map(partitionScheduled(scheduled, cal), func(b scheduledBucket) g.Node {
 			if b.overdue {
-				return captureOverdueSection(b.notes)
+				return captureOverdueSection(b.notes, cal.Location)
 			}
-			return captureTaskSection(b.name, b.notes, b.today)
+			return captureTaskSection(b.name, b.notes, b.today, cal.Location)
 		})),
-		captureSomedaySection(someday),
-		captureDoneSection(done),
+		captureSomedaySection(someday, cal.Location),
+		captureDoneSection(done, cal.Location),
 	}).Render(w)
 }
 
@@ -177,9 +178,8 @@ type scheduledBucket struct {
 	today   bool
 }
 
-func partitionScheduled(notes []note.Note) []scheduledBucket {
-	loc, _ := time.LoadLocation("America/Vancouver")
-	now := time.Now().In(loc)
+func partitionScheduled(notes []note.Note, cal notesmeta.Calendar) []scheduledBucket {
+	now := cal.Now()
 	midnight := notesmeta.Midnight(now)
 
 	buckets := []scheduledBucket{{name: "Overdue", overdue: true}}
@@ -199,7 +199,7 @@ func partitionScheduled(notes []note.Note) []scheduledBucket {
 		}
 		placed := false
 		for i, tf := range notesmeta.TimeframeList {
-			if due <= midnight.AddDate(0, 0, tf.Days(now)).Unix() {
+			if due <= midnight.AddDate(0, 0, tf.Days(cal, now)).Unix() {
 				buckets[i+1].notes = append(buckets[i+1].notes, n)
 				placed = true
 				break
@@ -268,6 +268,35 @@ func (s *webservice) postCaptureStar(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/capture/tasks", http.StatusSeeOther)
 }
 
+func (s *webservice) postCaptureRecurrence(w http.ResponseWriter, r *http.Request) {
+	noteID, err := uuid.Parse(chi.URLParam(r, "noteID"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	n, err := s.app.Notes.FindOne(noteID.String())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rule := strings.TrimSpace(r.FormValue("rule"))
+	var cmd evoke.Command
+	switch {
+	case rule != "":
+		cmd = commands.SetNoteRecurrence{NoteID: noteID, Rule: rule}
+	case n.Recurrence != "":
+		cmd = commands.ClearNoteRecurrence{NoteID: noteID}
+	}
+	if cmd != nil {
+		if err := s.app.Commander.Send(cmd); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	http.Redirect(w, r, "/capture/tasks", http.StatusSeeOther)
+}
+
 func starButton(n note.Note) g.Node {
 	star := "☆"
 	color := "black"
@@ -302,29 +331,68 @@ func scheduleButtons(n note.Note) g.Node {
 	)
 }
 
-func inlineEditForm(n note.Note) g.Node {
-	return h.Form(
-		h.Method("POST"),
-		h.Action(fmt.Sprintf("/note/%s/edit", n.ID)),
+func inlineEditForm(n note.Note, loc *time.Location) g.Node {
+	return h.Div(
 		h.Style("display:none"),
 		g.Attr("data-show", fmt.Sprintf("$editNote === '%s'", n.ID)),
-		h.Textarea(
-			h.Name("body"),
-			h.Rows("3"),
-			h.Style("width:100%; font-family:monospace; font-size:16px; box-sizing:border-box"),
-			g.Text(n.Text),
-		),
-		h.Div(h.Style("display:flex; gap:0.5em; margin-top:0.25em"),
-			h.Button(h.Type("submit"), g.Text("save")),
-			h.Button(h.Type("button"),
-				g.Attr("data-on:click", "$editNote = ''"),
-				g.Text("cancel"),
+		h.Form(
+			h.Method("POST"),
+			h.Action(fmt.Sprintf("/note/%s/edit", n.ID)),
+			h.Textarea(
+				h.Name("body"),
+				h.Rows("3"),
+				h.Style("width:100%; font-family:monospace; font-size:16px; box-sizing:border-box"),
+				g.Text(n.Text),
+			),
+			h.Div(h.Style("display:flex; gap:0.5em; margin-top:0.25em"),
+				h.Button(h.Type("submit"), g.Text("save")),
+				h.Button(h.Type("button"),
+					g.Attr("data-on:click", "$editNote = ''"),
+					g.Text("cancel"),
+				),
 			),
 		),
+		g.If(n.Category == notesmeta.Task.Slug, dueForm(n, loc, "/capture/tasks")),
+		g.If(n.Category == notesmeta.Task.Slug, recurrenceForm(n)),
+	)
+}
+
+// recurrenceForm sets or, when left empty, clears the recurrence rule of a task
+func recurrenceForm(n note.Note) g.Node {
+	return h.Form(
+		h.Method("POST"),
+		h.Action(fmt.Sprintf("/capture/notes/%s/recurrence", n.ID)),
+		h.Style("display:flex; gap:0.5em; margin-top:0.25em; align-items:center"),
+		h.Label(h.For("rule-"+n.ID.String()), g.Text("repeat")),
+		h.Input(h.ID("rule-"+n.ID.String()), h.Name("rule"), h.Value(n.Recurrence),
+			h.Placeholder("daily, weekdays, every 3 days, weekly mon,thu, monthly 1, 2 days after done"),
+			h.AutoComplete("off"), h.Style("flex:1"),
+		),
+		h.Button(h.Type("submit"), g.Text("set")),
 	)
 }
 
-func captureOverdueSection(noteList []note.Note) g.Node {
+// dueTimeEl shows the time of day a task is due, if it is due before the
+// end of the day
+func dueTimeEl(n note.Note, loc *time.Location) g.Node {
+	if n.Due == nil {
+		return nil
+	}
+	due := time.Unix(*n.Due, 0).In(loc)
+	if due.Equal(notesmeta.Midnight(due)) {
+		return nil
+	}
+	return h.Span(h.Style("color:gray; font-size:70%; margin-left:0.5em"), g.Text(due.Format("Mon 15:04")))
+}
+
+func recurrenceEl(n note.Note) g.Node {
+	if n.Recurrence == "" {
+		return nil
+	}
+	return h.Span(h.Style("color:gray; font-size:70%; margin-left:0.5em"), g.Text("↻ "+n.Recurrence))
+}
+
+func captureOverdueSection(noteList []note.Note, loc *time.Location) g.Node {
 	if len(noteList) == 0 {
 		return nil
 	}
@@ -335,17 +403,19 @@ func captureOverdueSection(noteList []note.Note) g.Node {
 				return h.Div(h.Class("note-item"),
 					h.Span(
 						g.Attr("data-show", fmt.Sprintf("$editNote !== '%s'", n.ID)),
-						h.Span(g.Text(n.Text)),
+						h.Span(g.Text(n.DisplayText())),
+						dueTimeEl(n, loc),
+						recurrenceEl(n),
 						noteActionsVisible(n, true),
 					),
-					inlineEditForm(n),
+					inlineEditForm(n, loc),
 				)
 			}),
 		),
 	)
 }
 
-func captureNotnowSection(noteList []note.Note) g.Node {
+func captureNotnowSection(noteList []note.Note, loc *time.Location) g.Node {
 	if len(noteList) == 0 {
 		return nil
 	}
@@ -354,8 +424,13 @@ func captureNotnowSection(noteList []note.Note) g.Node {
 		h.Div(h.Class("note-list"),
 			g.Map(noteList, func(n note.Note) g.Node {
 				return h.Div(h.Class("note-item"),
-					h.Span(g.Text(n.Text)),
+					h.Span(g.Text(n.DisplayText())),
+					suggestionEl(n),
 					scheduleButtons(n),
+					h.Details(h.Style("display:inline-block; margin-left:0.5em"),
+						h.Summary(h.Style("color:gray"), g.Text("pick a date")),
+						dueForm(n, loc, "/capture/tasks"),
+					),
 				)
 			}),
 		),
@@ -365,7 +440,7 @@ func captureNotnowSection(noteList []note.Note) g.Node {
 func captureNoteList(noteList []note.Note) g.Node {
 	return h.Div(h.Class("note-list"),
 		g.Map(noteList, func(n note.Note) g.Node {
-			return h.Div(h.Class("note-item"), g.Text(n.Text))
+			return h.Div(h.Class("note-item"), g.Text(n.Text), linkCardEl(n), suggestionEl(n))
 		}),
 	)
 }
@@ -413,7 +488,7 @@ func noteActionsVisible(n note.Note, visible bool) g.Node {
 	)
 }
 
-func captureDoneSection(noteList []note.Note) g.Node {
+func captureDoneSection(noteList []note.Note, loc *time.Location) g.Node {
 	if len(noteList) == 0 {
 		return nil
 	}
@@ -424,17 +499,19 @@ func captureDoneSection(noteList []note.Note) g.Node {
 				return h.Div(h.Class("note-item"),
 					h.Span(
 						g.Attr("data-show", fmt.Sprintf("$editNote !== '%s'", n.ID)),
-						h.Span(g.Attr("data-on:click", fmt.Sprintf("$activeNote = $activeNote === '%s' ? '' : '%s'", n.ID, n.ID)), h.Style("cursor:pointer"), g.Text(n.Text)),
+						h.Span(g.Attr("data-on:click", fmt.Sprintf("$activeNote = $activeNote === '%s' ? '' : '%s'", n.ID, n.ID)), h.Style("cursor:pointer"), g.Text(n.DisplayText())),
+						dueTimeEl(n, loc),
+						recurrenceEl(n),
 						noteActions(n),
 					),
-					inlineEditForm(n),
+					inlineEditForm(n, loc),
 				)
 			}),
 		),
 	)
 }
 
-func captureSomedaySection(noteList []note.Note) g.Node {
+func captureSomedaySection(noteList []note.Note, loc *time.Location) g.Node {
 	if len(noteList) == 0 {
 		return nil
 	}
@@ -445,17 +522,19 @@ func captureSomedaySection(noteList []note.Note) g.Node {
 				return h.Div(h.Class("note-item"),
 					h.Span(
 						g.Attr("data-show", fmt.Sprintf("$editNote !== '%s'", n.ID)),
-						h.Span(g.Attr("data-on:click", fmt.Sprintf("$activeNote = $activeNote === '%s' ? '' : '%s'", n.ID, n.ID)), h.Style("cursor:pointer"), g.Text(n.Text)),
+						h.Span(g.Attr("data-on:click", fmt.Sprintf("$activeNote = $activeNote === '%s' ? '' : '%s'", n.ID, n.ID)), h.Style("cursor:pointer"), g.Text(n.DisplayText())),
+						dueTimeEl(n, loc),
+						recurrenceEl(n),
 						noteActions(n),
 					),
-					inlineEditForm(n),
+					inlineEditForm(n, loc),
 				)
 			}),
 		),
 	)
 }
 
-func captureTaskSection(heading string, noteList []note.Note, showStar bool) g.Node {
+func captureTaskSection(heading string, noteList []note.Note, showStar bool, loc *time.Location) g.Node {
 	if len(noteList) == 0 {
 		return nil
 	}
@@ -467,10 +546,12 @@ func captureTaskSection(heading string, noteList []note.Note, showStar bool) g.N
 					h.Span(
 						g.Attr("data-show", fmt.Sprintf("$editNote !== '%s'", n.ID)),
 						g.If(showStar, starButton(n)),
-						h.Span(g.Attr("data-on:click", fmt.Sprintf("$activeNote = $activeNote === '%s' ? '' : '%s'", n.ID, n.ID)), h.Style("cursor:pointer"), g.Text(n.Text)),
+						h.Span(g.Attr("data-on:click", fmt.Sprintf("$activeNote = $activeNote === '%s' ? '' : '%s'", n.ID, n.ID)), h.Style("cursor:pointer"), g.Text(n.DisplayText())),
+						dueTimeEl(n, loc),
+						recurrenceEl(n),
 						noteActions(n),
 					),
-					inlineEditForm(n),
+					inlineEditForm(n, loc),
 				)
 			}),
 		),
@@ -478,10 +559,26 @@ func captureTaskSection(heading string, noteList []note.Note, showStar bool) g.N
 }
 
 func (s *webservice) settingsIndex(w http.ResponseWriter, r *http.Request) {
+	s.renderSettings(w, r, "")
+}
+
+// renderSettings renders the settings page, newToken is shown once after
+// an api token is created
+func (s *webservice) renderSettings(w http.ResponseWriter, r *http.Request, newToken string) {
+	owner := getUserInfo(r).Id
+	tokens, err := s.app.Users.FindAllAPITokens(owner)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	prefs := s.app.Users.Preferences(owner)
+
 	capturePage(g.Group{
 		captureNavWithRequest(r, "/capture/tasks"),
 		h.Div(h.Style("padding:1em"),
-			h.A(h.Href("/logout"), g.Text("logout")),
+			preferencesSection(prefs),
+			apiTokensSection(tokens, newToken),
+			h.P(h.A(h.Href("/logout"), g.Text("logout"))),
 		),
 	}).Render(w)
 }
@@ -0,0 +1,98 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rcy/whatever/catalog/notesmeta"
+	"github.com/rcy/whatever/commands"
+	"github.com/rcy/whatever/projections/note"
+	g "maragu.dev/gomponents"
+	h "maragu.dev/gomponents/html"
+)
+
+// postNoteDue sets the due date of a task from either free text such as
+// "next tue at 3pm" or a date and optional time picked in the form, or
+// clears it
+func (s *webservice) postNoteDue(w http.ResponseWriter, r *http.Request) {
+	noteID, ok := s.resolveNoteID(w, r)
+	if !ok {
+		return
+	}
+
+	var err error
+	if r.FormValue("clear") != "" {
+		err = s.app.Commander.Send(commands.ClearNoteDue{NoteID: noteID})
+	} else {
+		var due time.Time
+		due, err = parseDueForm(r, s.calendar(r))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err = s.app.Commander.Send(commands.SetNoteDue{NoteID: noteID, Due: due})
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	http.Redirect(w, r, sanitizeRedirect(r.FormValue("next")), http.StatusSeeOther)
+}
+
+func parseDueForm(r *http.Request, cal notesmeta.Calendar) (time.Time, error) {
+	if when := strings.TrimSpace(r.FormValue("when")); when != "" {
+		return notesmeta.ParseDue(when, cal, cal.Now())
+	}
+
+	date := r.FormValue("date")
+	if date == "" {
+		return time.Time{}, fmt.Errorf("pick a date")
+	}
+	day, err := time.ParseInLocation(time.DateOnly, date, cal.Location)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date: %w", err)
+	}
+	clock := r.FormValue("time")
+	if clock == "" {
+		// due by the end of the day
+		return day.AddDate(0, 0, 1), nil
+	}
+	t, err := time.ParseInLocation(time.DateOnly+" 15:04", date+" "+clock, cal.Location)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time: %w", err)
+	}
+	if t.Equal(notesmeta.Midnight(t)) {
+		return time.Time{}, notesmeta.ErrMidnightDue
+	}
+	return t, nil
+}
+
+// dueForm picks the due date of a task, then returns to next
+func dueForm(n note.Note, loc *time.Location, next string) g.Node {
+	var date, clock string
+	if n.Due != nil {
+		due := time.Unix(*n.Due, 0).In(loc)
+		if due.Equal(notesmeta.Midnight(due)) {
+			date = due.AddDate(0, 0, -1).Format(time.DateOnly)
+		} else {
+			date = due.Format(time.DateOnly)
+			clock = due.Format("15:04")
+		}
+	}
+	return h.Form(
+		h.Method("POST"),
+		h.Action(fmt.Sprintf("/note/%s/due", n.ID)),
+		h.Style("display:flex; flex-wrap:wrap; gap:0.5em; margin-top:0.25em; align-items:center"),
+		h.Input(h.Type("hidden"), h.Name("next"), h.Value(next)),
+		h.Span(g.Text("due")),
+		h.Input(h.Name("when"), h.Placeholder("next tue, in 3 days, dec 1 at 3pm"), h.AutoComplete("off")),
+		h.Span(g.Text("or")),
+		h.Input(h.Type("date"), h.Name("date"), h.Value(date)),
+		h.Input(h.Type("time"), h.Name("time"), h.Value(clock)),
+		h.Button(h.Type("submit"), g.Text("set")),
+		g.If(n.Due != nil, h.Button(h.Type("submit"), h.Name("clear"), h.Value("1"), g.Text("clear"))),
+	)
+}
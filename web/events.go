@@ -0,0 +1,144 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/rcy/evoke"
+	"github.com/rcy/whatever/eventlog"
+	g "maragu.dev/gomponents"
+	h "maragu.dev/gomponents/html"
+)
+
+const eventsPageSize = 100
+
+// eventsIndex is a paginated browser of the event log, newest first. The
+// filters and the page cursor are query params so pages can be linked to.
+func (s *webservice) eventsIndex(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	now := time.Now()
+
+	filter := eventlog.Filter{
+		AggregatePrefix: query.Get("aggregate"),
+		Type:            query.Get("type"),
+	}
+	var err error
+	if since := query.Get("since"); since != "" {
+		filter.Since, err = eventlog.ParseTime(since, now)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if until := query.Get("until"); until != "" {
+		filter.Until, err = eventlog.ParseTime(until, now)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	var before int64
+	if b := query.Get("before"); b != "" {
+		before, err = strconv.ParseInt(b, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid before", http.StatusBadRequest)
+			return
+		}
+	}
+
+	recs, err := s.app.EventDebugger.DebugEvents()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	types := eventlog.Types(recs)
+	slices.Sort(types)
+	matched := filter.Apply(recs)
+
+	// page back from the cursor
+	end := len(matched)
+	if before > 0 {
+		end, _ = slices.BinarySearchFunc(matched, before, func(rec evoke.RecordedEvent, seq int64) int {
+			return int(rec.Sequence - seq)
+		})
+	}
+	start := max(0, end-eventsPageSize)
+	page := slices.Clone(matched[start:end])
+	slices.Reverse(page)
+
+	link := func(params map[string]string) string {
+		q := url.Values{}
+		for k, v := range query {
+			if k != "before" {
+				q[k] = v
+			}
+		}
+		for k, v := range params {
+			if v == "" {
+				q.Del(k)
+			} else {
+				q.Set(k, v)
+			}
+		}
+		return "/events?" + q.Encode()
+	}
+
+	var pager []g.Node
+	if end < len(matched) {
+		newer := ""
+		if newEnd := end + eventsPageSize; newEnd < len(matched) {
+			newer = fmt.Sprint(matched[newEnd].Sequence)
+		}
+		pager = append(pager, h.A(h.Href(link(map[string]string{"before": newer})), g.Text("← newer")))
+	}
+	if start > 0 {
+		pager = append(pager, h.A(h.Href(link(map[string]string{"before": fmt.Sprint(matched[start].Sequence)})), g.Text("older →")))
+	}
+	pagerEl := h.Div(h.Style("display:flex; gap:1em"),
+		h.Span(g.Textf("%d events", len(matched))),
+		g.Group(pager),
+	)
+
+	capturePage(g.Group{
+		captureNavWithRequest(r, "/capture/tasks"),
+		h.Div(h.Style("padding:1em; display:flex; flex-direction:column; gap:1em"),
+			h.Form(h.Method("GET"), h.Action("/events"), h.Style("display:flex; gap:0.5em; flex-wrap:wrap"),
+				h.Input(h.Name("aggregate"), h.Placeholder("aggregate id prefix"), h.Value(query.Get("aggregate"))),
+				h.Select(h.Name("type"),
+					h.Option(h.Value(""), g.Text("all types")),
+					g.Map(types, func(t string) g.Node {
+						return h.Option(h.Value(t), g.Text(t), g.If(t == query.Get("type"), h.Selected()))
+					}),
+				),
+				h.Input(h.Name("since"), h.Placeholder("since (2025-09-06, 2h, 7d)"), h.Value(query.Get("since"))),
+				h.Input(h.Name("until"), h.Placeholder("until"), h.Value(query.Get("until"))),
+				h.Button(h.Type("submit"), g.Text("filter")),
+				h.A(h.Href("/events"), g.Text("clear")),
+			),
+			pagerEl,
+			h.Table(h.Style("border-collapse:collapse; font-size:0.8em"),
+				g.Map(page, func(rec evoke.RecordedEvent) g.Node {
+					data, err := json.Marshal(rec.Event)
+					if err != nil {
+						data = []byte(err.Error())
+					}
+					recordedAt := time.Unix(rec.RecordedAt, 0)
+					aggregateID := rec.AggregateID.String()
+					return h.Tr(h.Style("border-bottom:1px solid #eee; vertical-align:top"),
+						h.Td(g.Textf("%d", rec.Sequence)),
+						h.Td(h.Title(recordedAt.UTC().Format(time.RFC3339)), h.Style("white-space:nowrap"), g.Text(ago(recordedAt))),
+						h.Td(h.A(h.Href(link(map[string]string{"aggregate": aggregateID})), h.Title(aggregateID), g.Text(aggregateID[0:7]))),
+						h.Td(h.A(h.Href(link(map[string]string{"type": rec.EventType})), g.Text(rec.EventType))),
+						h.Td(h.Code(h.Style("word-break:break-all"), g.Text(string(data)))),
+					)
+				}),
+			),
+			pagerEl,
+		),
+	}).Render(w)
+}
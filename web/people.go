@@ -0,0 +1,307 @@
+package web
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rcy/evoke"
+	"github.com/rcy/whatever/api"
+	"github.com/rcy/whatever/catalog/notesmeta"
+	"github.com/rcy/whatever/commands"
+	"github.com/rcy/whatever/projections/note"
+	g "maragu.dev/gomponents"
+	h "maragu.dev/gomponents/html"
+)
+
+// updatePerson sends a command for each detail of the person with handle
+// that differs from the current value. Nil details are left alone.
+func (s *webservice) updatePerson(owner string, handle string, displayName *string, notes *string) error {
+	handle, err := s.resolveHandle(owner, handle)
+	if err != nil {
+		return err
+	}
+	current, err := s.app.Notes.FindPerson(owner, handle)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	personID := commands.PersonID(owner, handle)
+	var cmds []evoke.Command
+	if displayName != nil && strings.TrimSpace(*displayName) != current.DisplayName {
+		cmds = append(cmds, commands.SetPersonDisplayName{PersonID: personID, Owner: owner, Handle: handle, DisplayName: *displayName})
+	}
+	if notes != nil && strings.TrimSpace(*notes) != current.Notes {
+		cmds = append(cmds, commands.SetPersonNotes{PersonID: personID, Owner: owner, Handle: handle, Notes: *notes})
+	}
+	for _, cmd := range cmds {
+		if err := s.app.Commander.Send(cmd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergePerson makes handle an alias of the person into is, or is an alias of
+func (s *webservice) mergePerson(owner string, handle string, into string) error {
+	handle, err := notesmeta.NormalizeHandle(handle)
+	if err != nil {
+		return err
+	}
+	target, err := s.resolveHandle(owner, into)
+	if err != nil {
+		return err
+	}
+	if target == handle {
+		return fmt.Errorf("cannot merge @%s into its own alias @%s", handle, strings.TrimPrefix(into, "@"))
+	}
+	return s.app.Commander.Send(commands.MergePerson{PersonID: commands.PersonID(owner, handle), Owner: owner, Handle: handle, Into: target})
+}
+
+func (s *webservice) unmergePerson(owner string, handle string) error {
+	handle, err := notesmeta.NormalizeHandle(handle)
+	if err != nil {
+		return err
+	}
+	return s.app.Commander.Send(commands.UnmergePerson{PersonID: commands.PersonID(owner, handle), Owner: owner, Handle: handle})
+}
+
+// resolveHandle normalizes handle and returns the person it is an alias of
+func (s *webservice) resolveHandle(owner string, handle string) (string, error) {
+	handle, err := notesmeta.NormalizeHandle(handle)
+	if err != nil {
+		return "", err
+	}
+	return s.app.Notes.ResolveHandle(owner, handle)
+}
+
+func (s *webservice) notesPeople(w http.ResponseWriter, r *http.Request) {
+	owner := getUserInfo(r)
+	handleParam := chi.URLParam(r, "handle")
+
+	if handleParam != "" {
+		handle, err := s.resolveHandle(owner.Id, handleParam)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if handle != handleParam {
+			http.Redirect(w, r, "/dsnotes/people/"+handle, http.StatusSeeOther)
+			return
+		}
+	}
+
+	people, err := s.app.Notes.People(owner.Id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var notes []note.Note
+	if handleParam == "" {
+		notes, err = s.app.Notes.FindAllWithMention(owner.Id)
+	} else {
+		notes, err = s.app.Notes.FindAllByPerson(owner.Id, handleParam)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var card g.Node
+	for _, p := range people {
+		if p.Handle == handleParam {
+			card = personEl(p, s.calendar(r).Location)
+		}
+	}
+	if handleParam != "" && card == nil {
+		card = personEl(note.Person{Handle: handleParam}, s.calendar(r).Location)
+	}
+
+	content, err := s.page(r, "people", "", h.Div(
+		h.Div(h.Style("background: pink; padding: 5px; display:flex; justify-content: space-between;"),
+			h.Div(h.Style("display: flex; gap: 5px; flex-wrap: wrap"),
+				g.Map(people, func(p note.Person) g.Node {
+					var style g.Node
+					if p.Handle == handleParam {
+						style = h.Style("font-weight: bold")
+					}
+					return h.Div(h.A(style, g.Textf("%s (%d)", p.Name(), p.Mentions), h.Href("/dsnotes/people/"+p.Handle)))
+				}),
+			),
+			h.Div(h.A(g.Text("all"), h.Href("/dsnotes/people"))),
+		),
+		card,
+		g.Map(notes, func(note note.Note) g.Node {
+			return noteEl(note)
+		})))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	content.Render(w)
+}
+
+// personEl shows what is known about a person, with forms to change it
+func personEl(p note.Person, loc *time.Location) g.Node {
+	base := "/dsnotes/people/" + p.Handle
+	lastMentioned := "never"
+	if p.LastMentioned != nil {
+		lastMentioned = time.Unix(*p.LastMentioned, 0).In(loc).Format("Mon Jan 2 2006")
+	}
+	return h.Div(h.Style("padding: 0.5em 0; display:flex; flex-direction:column; gap:0.5em"),
+		h.Div(
+			h.Strong(g.Text(p.Name())),
+			g.If(p.DisplayName != "", h.Span(h.Style("color:gray"), g.Text(" @"+p.Handle))),
+		),
+		g.If(len(p.Aliases) > 0, h.Div(h.Style("display:flex; gap:0.5em; align-items:center"),
+			g.Text("also"),
+			g.Map(p.Aliases, func(alias string) g.Node {
+				return h.Form(h.Method("POST"), h.Action("/dsnotes/people/"+alias+"/unmerge"), h.Style("display:inline"),
+					g.Text("@"+alias),
+					h.Button(h.Type("submit"), h.Style("color:gray; padding:0 0.25em"), h.Title("unmerge"), g.Text("×")),
+				)
+			}),
+		)),
+		h.Div(h.Style("color:gray"),
+			g.Textf("last mentioned %s · %d open tasks · %d notes", lastMentioned, p.OpenTasks, p.Mentions),
+		),
+		h.Form(h.Method("POST"), h.Action(base), h.Style("display:flex; flex-direction:column; gap:0.25em; max-width:30em"),
+			h.Input(h.Name("displayName"), h.Value(p.DisplayName), h.Placeholder("display name"), h.AutoComplete("off")),
+			h.Textarea(h.Name("notes"), h.Rows("3"), h.Placeholder("contact notes"), g.Text(p.Notes)),
+			h.Div(h.Button(h.Type("submit"), g.Text("save"))),
+		),
+		h.Form(h.Method("POST"), h.Action(base+"/merge"), h.Style("display:flex; gap:0.25em; align-items:center"),
+			g.Text("merge into @"),
+			h.Input(h.Name("into"), h.Placeholder("handle"), h.AutoComplete("off")),
+			h.Button(h.Type("submit"), g.Text("merge")),
+		),
+	)
+}
+
+func (s *webservice) postPerson(w http.ResponseWriter, r *http.Request) {
+	displayName := r.FormValue("displayName")
+	notes := r.FormValue("notes")
+	err := s.updatePerson(getUserInfo(r).Id, chi.URLParam(r, "handle"), &displayName, &notes)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Redirect(w, r, "/dsnotes/people/"+url.PathEscape(chi.URLParam(r, "handle")), http.StatusSeeOther)
+}
+
+func (s *webservice) postMergePerson(w http.ResponseWriter, r *http.Request) {
+	into := r.FormValue("into")
+	err := s.mergePerson(getUserInfo(r).Id, chi.URLParam(r, "handle"), into)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Redirect(w, r, "/dsnotes/people/"+url.PathEscape(strings.TrimPrefix(into, "@")), http.StatusSeeOther)
+}
+
+func (s *webservice) postUnmergePerson(w http.ResponseWriter, r *http.Request) {
+	err := s.unmergePerson(getUserInfo(r).Id, chi.URLParam(r, "handle"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Redirect(w, r, "/dsnotes/people/"+url.PathEscape(chi.URLParam(r, "handle")), http.StatusSeeOther)
+}
+
+func newAPIPerson(p note.Person) api.Person {
+	ap := api.Person{
+		Handle:      p.Handle,
+		DisplayName: p.DisplayName,
+		Notes:       p.Notes,
+		Aliases:     p.Aliases,
+		Mentions:    p.Mentions,
+		OpenTasks:   p.OpenTasks,
+	}
+	if p.LastMentioned != nil {
+		t := time.Unix(*p.LastMentioned, 0).UTC()
+		ap.LastMentioned = &t
+	}
+	return ap
+}
+
+func (s *webservice) apiListPeople(w http.ResponseWriter, r *http.Request) {
+	people, err := s.app.Notes.People(getUserInfo(r).Id)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "internal", err)
+		return
+	}
+	out := make([]api.Person, len(people))
+	for i, p := range people {
+		out[i] = newAPIPerson(p)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"people": out})
+}
+
+// apiWritePerson responds with the person handle is, or is an alias of
+func (s *webservice) apiWritePerson(w http.ResponseWriter, r *http.Request, handle string) {
+	owner := getUserInfo(r).Id
+	handle, err := s.resolveHandle(owner, handle)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "bad_request", err)
+		return
+	}
+	p, err := s.app.Notes.FindPerson(owner, handle)
+	if errors.Is(err, sql.ErrNoRows) {
+		// never mentioned, but a valid handle
+		p = note.Person{Handle: handle}
+	} else if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "internal", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newAPIPerson(p))
+}
+
+func (s *webservice) apiGetPerson(w http.ResponseWriter, r *http.Request) {
+	s.apiWritePerson(w, r, chi.URLParam(r, "handle"))
+}
+
+func (s *webservice) apiUpdatePerson(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		DisplayName *string `json:"displayName"`
+		Notes       *string `json:"notes"`
+	}
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+	err := s.updatePerson(getUserInfo(r).Id, chi.URLParam(r, "handle"), body.DisplayName, body.Notes)
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "rejected", err)
+		return
+	}
+	s.apiWritePerson(w, r, chi.URLParam(r, "handle"))
+}
+
+func (s *webservice) apiMergePerson(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Into string `json:"into"`
+	}
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+	err := s.mergePerson(getUserInfo(r).Id, chi.URLParam(r, "handle"), body.Into)
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "rejected", err)
+		return
+	}
+	s.apiWritePerson(w, r, chi.URLParam(r, "handle"))
+}
+
+func (s *webservice) apiUnmergePerson(w http.ResponseWriter, r *http.Request) {
+	err := s.unmergePerson(getUserInfo(r).Id, chi.URLParam(r, "handle"))
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, "rejected", err)
+		return
+	}
+	s.apiWritePerson(w, r, chi.URLParam(r, "handle"))
+}
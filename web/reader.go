@@ -0,0 +1,52 @@
+package web
+
+import (
+	"strings"
+	"time"
+
+	"github.com/rcy/whatever/projections/note"
+	g "maragu.dev/gomponents"
+	h "maragu.dev/gomponents/html"
+)
+
+// readerEls shows the archived copy of each linked page that has one, as
+// plain text that is comfortable to read
+func (s *webservice) readerEls(links []note.Link, loc *time.Location) g.Node {
+	var nodes g.Group
+	for _, link := range links {
+		if link.Archive == "" {
+			continue
+		}
+		text, err := s.app.Notes.ArchiveText(link)
+		if err != nil {
+			// the blob store may not have been copied along with the events
+			text = "The archived copy could not be read: " + err.Error()
+		}
+		nodes = append(nodes, readerEl(link, text, loc))
+	}
+	return nodes
+}
+
+func readerEl(link note.Link, text string, loc *time.Location) g.Node {
+	title := link.Title
+	if title == "" {
+		title = link.URL
+	}
+	archived := ""
+	if link.ArchivedAt != nil {
+		archived = "archived " + time.Unix(*link.ArchivedAt, 0).In(loc).Format("Mon Jan 2 2006")
+	}
+	return h.Details(h.Class("reader"), h.Style("margin:1em 0"),
+		h.Summary(g.Text("reader view: "+title)),
+		h.Article(h.Style("max-width:36em; margin:0.5em auto; font-family:Georgia, serif; font-size:110%; line-height:1.5"),
+			h.H2(g.Text(title)),
+			h.Div(h.Style("color:gray; font-size:80%"),
+				h.A(h.Href(link.URL), g.Text(link.URL)),
+				g.If(archived != "", g.Text(" · "+archived)),
+			),
+			g.Map(strings.Split(text, "\n"), func(p string) g.Node {
+				return h.P(g.Text(p))
+			}),
+		),
+	)
+}
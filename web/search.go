@@ -0,0 +1,91 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+
+	"github.com/rcy/whatever/api"
+	"github.com/rcy/whatever/projections/note"
+	g "maragu.dev/gomponents"
+	h "maragu.dev/gomponents/html"
+)
+
+func (s *webservice) captureSearch(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.FormValue("q"))
+
+	var results []note.SearchResult
+	var searchErr error
+	if query != "" {
+		results, searchErr = s.app.Notes.Search(getUserInfo(r).Id, query)
+	}
+
+	capturePage(g.Group{
+		captureNavWithRequest(r, "/capture/reference"),
+		h.Div(h.Class("note-list"),
+			g.If(searchErr != nil, h.Div(h.Style("color:red"), g.Text(fmt.Sprint(searchErr)))),
+			g.If(query != "" && searchErr == nil && len(results) == 0, h.Div(h.Style("color:gray"), g.Text("no notes found"))),
+			g.Map(results, func(res note.SearchResult) g.Node {
+				return h.Div(h.Class("note-item"),
+					h.A(h.Href("/note/"+res.ID.String()), h.Style("color:inherit; text-decoration:none"), snippetEl(res)),
+					h.Div(h.Style("color:gray; font-size:70%"),
+						g.Text(res.Category+"/"+res.Subcategory),
+						g.If(res.Deleted, g.Text(" · deleted")),
+					),
+				)
+			}),
+		),
+	}).Render(w)
+}
+
+// snippetEl shows the best matching part of a search result with the
+// matching words highlighted, or the whole note when there is no snippet
+func snippetEl(res note.SearchResult) g.Node {
+	if res.Snippet == "" {
+		return g.Text(res.DisplayText())
+	}
+	var nodes g.Group
+	rest := res.Snippet
+	for rest != "" {
+		before, after, found := strings.Cut(rest, note.SnippetStart)
+		nodes = append(nodes, g.Text(before))
+		if !found {
+			break
+		}
+		match, after, _ := strings.Cut(after, note.SnippetEnd)
+		nodes = append(nodes, h.Mark(g.Text(match)))
+		rest = after
+	}
+	return nodes
+}
+
+// snippetHTML escapes a search snippet, marking the matching words with
+// <mark>
+func snippetHTML(snippet string) string {
+	snippet = html.EscapeString(snippet)
+	snippet = strings.ReplaceAll(snippet, note.SnippetStart, "<mark>")
+	return strings.ReplaceAll(snippet, note.SnippetEnd, "</mark>")
+}
+
+func (s *webservice) apiSearch(w http.ResponseWriter, r *http.Request) {
+	results, err := s.app.Notes.Search(getUserInfo(r).Id, r.URL.Query().Get("q"))
+	var queryErr *note.QueryError
+	if errors.As(err, &queryErr) {
+		writeAPIError(w, http.StatusBadRequest, "bad_request", err)
+		return
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "internal", err)
+		return
+	}
+	out := make([]api.SearchResult, len(results))
+	for i, res := range results {
+		out[i] = api.SearchResult{
+			Note:    newAPINote(res.Note, res.Deleted),
+			Snippet: snippetHTML(res.Snippet),
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"results": out})
+}
@@ -0,0 +1,45 @@
+package web
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAPISearch(t *testing.T) {
+	_, handler := newTestServer(t)
+	alice := asOwner(t, "alice")
+
+	for _, text := range []string{"buy oat milk", "buy bread", "call the bank"} {
+		status := apiRequest(t, handler, alice, "POST", "/notes", map[string]string{"text": text, "category": "task"}, nil)
+		if status != http.StatusCreated {
+			t.Fatalf("create: %d", status)
+		}
+	}
+
+	var found struct {
+		Results []struct {
+			Note    testNote `json:"note"`
+			Snippet string   `json:"snippet"`
+		}
+	}
+	status := apiRequest(t, handler, alice, "GET", "/search?q=buy+-oat", nil, &found)
+	if status != http.StatusOK || len(found.Results) != 1 || found.Results[0].Note.Text != "buy bread" {
+		t.Fatalf("search: %d %+v", status, found)
+	}
+	if found.Results[0].Snippet != "<mark>buy</mark> bread" {
+		t.Errorf("snippet %q", found.Results[0].Snippet)
+	}
+
+	status = apiRequest(t, handler, asOwner(t, "bob"), "GET", "/search?q=buy", nil, &found)
+	if status != http.StatusOK || len(found.Results) != 0 {
+		t.Errorf("search as someone else: %d %+v", status, found)
+	}
+
+	for _, query := range []string{"-oat", "is:pinned", "due:soon"} {
+		var got testError
+		status := apiRequest(t, handler, alice, "GET", "/search?q="+query, nil, &got)
+		if status != http.StatusBadRequest || got.Error.Code != "bad_request" {
+			t.Errorf("search %q: got %d %+v, want 400", query, status, got)
+		}
+	}
+}
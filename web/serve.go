@@ -1,8 +1,8 @@
 package web
 
 import (
+	"database/sql"
 	_ "embed"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
@@ -16,7 +16,6 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/google/uuid"
 	"github.com/kkdai/youtube/v2"
-	"github.com/rcy/evoke"
 	"github.com/rcy/whatever/app"
 	"github.com/rcy/whatever/catalog/notesmeta"
 	"github.com/rcy/whatever/commands"
@@ -84,6 +83,11 @@ func Server(app *app.App, cfg Config) (*chi.Mux, error) {
 	r.Get("/auth/callback", svc.authCallbackHandler)
 	r.Get("/logout", svc.logoutHandler)
 
+	r.Route("/api/v1", func(r chi.Router) {
+		r.Use(svc.apiAuthMiddleware)
+		svc.apiRouter(r)
+	})
+
 	r.Group(func(r chi.Router) {
 		r.Use(svc.authMiddleware)
 
@@ -92,16 +96,23 @@ func Server(app *app.App, cfg Config) (*chi.Mux, error) {
 		})
 
 		r.Get("/settings", svc.settingsIndex)
+		r.Post("/settings/preferences", svc.postPreferences)
+		r.With(sessionOnly).Post("/settings/tokens", svc.postCreateAPIToken)
+		r.With(sessionOnly).Post("/settings/tokens/{tokenID}/revoke", svc.postRevokeAPIToken)
 		r.Get("/capture", svc.captureIndex)
 		r.Get("/capture/tasks", svc.captureTasksIndex)
 		r.Post("/capture/tasks", svc.postCaptureTask)
 		r.Get("/capture/reference", svc.captureReferenceIndex)
+		r.Get("/capture/search", svc.captureSearch)
 		r.Post("/capture/reference", svc.postCaptureReference)
 		r.Post("/capture/trans/{noteID}/{event}", svc.postCaptureTransition)
 		r.Post("/capture/notes/{noteID}/star", svc.postCaptureStar)
+		r.Post("/capture/notes/{noteID}/recurrence", svc.postCaptureRecurrence)
 
 		r.Get("/note/{id}", svc.showNote)
 		r.Post("/note/{id}/edit", svc.postEditNote)
+		r.Post("/note/{id}/due", svc.postNoteDue)
+		r.Post("/note/{id}/enrich", svc.postEnrichNote)
 
 		r.Get("/events", svc.eventsIndex)
 
@@ -111,6 +122,14 @@ func Server(app *app.App, cfg Config) (*chi.Mux, error) {
 
 		r.Get("/dsnotes/people", svc.notesPeople)
 		r.Get("/dsnotes/people/{handle}", svc.notesPeople)
+		r.Post("/dsnotes/people/{handle}", svc.postPerson)
+		r.Post("/dsnotes/people/{handle}/merge", svc.postMergePerson)
+		r.Post("/dsnotes/people/{handle}/unmerge", svc.postUnmergePerson)
+
+		r.Get("/dsnotes/tags", svc.notesTags)
+		r.Get("/dsnotes/tags/{tag}", svc.notesTags)
+		r.Post("/note/{id}/tags", svc.postAddNoteTag)
+		r.Post("/note/{id}/tags/{tag}/remove", svc.postRemoveNoteTag)
 
 		r.Post("/dsnotes", svc.postNotesHandler)
 		r.Post("/refile/{noteID}/{category}", svc.postRefileNote)
@@ -139,13 +158,8 @@ func (s *webservice) postNotesHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if signals.Body != "" {
-		err := s.app.Commander.Send(commands.CreateNote{
-			Owner:       userInfo.Id,
-			NoteID:      uuid.New(),
-			Text:        signals.Body,
-			Category:    notesmeta.Inbox.Slug,
-			Subcategory: notesmeta.Inbox.Inbox().Slug,
-		})
+		captureCategory := notesmeta.Categories.Get(s.app.Users.Preferences(userInfo.Id).CaptureCategory)
+		_, err := s.app.CaptureNote(userInfo.Id, signals.Body, captureCategory.Slug, captureCategory.Inbox().Slug)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
@@ -214,31 +228,43 @@ func (s *webservice) inboxHeader(owner string) (g.Node, error) {
 	), nil
 }
 
-func (s *webservice) eventsIndex(w http.ResponseWriter, r *http.Request) {
-	events, err := s.app.EventDebugger.DebugEvents()
-	if err != nil {
+// resolveNoteID expands the id url param, which may be a unique prefix, to
+// the id of one of the current user's notes. On failure an error response
+// has been written and ok is false.
+func (s *webservice) resolveNoteID(w http.ResponseWriter, r *http.Request) (id uuid.UUID, ok bool) {
+	id, err := s.app.Notes.ResolveID(getUserInfo(r).Id, chi.URLParam(r, "id"))
+	var ambiguous *note.AmbiguousIDError
+	switch {
+	case errors.As(err, &ambiguous):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return uuid.Nil, false
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return uuid.Nil, false
+	case errors.Is(err, note.ErrInvalidID):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return uuid.Nil, false
+	case err != nil:
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return uuid.Nil, false
 	}
-	slices.Reverse(events)
-
-	h.HTML(
-		h.Body(
-			h.H1(g.Text("events")),
-			g.Map(events, func(ev evoke.RecordedEvent) g.Node {
-				data, err := json.Marshal(ev)
-				if err != nil {
-					return h.Div(h.Style("color: red"), g.Text(err.Error()))
-				}
-				return h.Div(g.Text(string(data)))
-			}),
-		),
-	).Render(w)
+	return id, true
 }
 
 func (s *webservice) showNote(w http.ResponseWriter, r *http.Request) {
-	noteID := chi.URLParam(r, "id")
-	note, err := s.app.Notes.FindOne(noteID)
+	noteID, ok := s.resolveNoteID(w, r)
+	if !ok {
+		return
+	}
+	if noteID.String() != chi.URLParam(r, "id") {
+		http.Redirect(w, r, "/note/"+noteID.String(), http.StatusSeeOther)
+		return
+	}
+
+	note, err := s.app.Notes.FindOne(noteID.String())
+	if errors.Is(err, sql.ErrNoRows) {
+		note, err = s.app.Notes.FindOneDeleted(noteID.String())
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -248,7 +274,25 @@ func (s *webservice) showNote(w http.ResponseWriter, r *http.Request) {
 	// show some buttons
 	// edit / delete / delete
 
-	links, err := noteLinksEl(note)
+	enriched, err := s.app.Notes.FindLinks(note.ID.String())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	links, err := noteLinksEl(note, enriched)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	tags, err := s.app.Notes.NoteTags(note.ID.String())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	history, err := s.app.Notes.TaskHistory(note.ID.String())
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -264,6 +308,7 @@ func (s *webservice) showNote(w http.ResponseWriter, r *http.Request) {
 
 	page := h.Div(
 		noteEl(note),
+		noteTagsEl(note, tags),
 		// h.Form(
 		// 	g.Attr("data-on:submit", fmt.Sprintf("@post('/note/%s/comment', {contentType: 'form'})", note.ID)),
 		// 	h.Textarea(
@@ -273,7 +318,10 @@ func (s *webservice) showNote(w http.ResponseWriter, r *http.Request) {
 		// 	),
 		// 	h.Button(g.Text("submit")),
 		// ),
+		g.If(note.Category == notesmeta.Task.Slug, dueForm(note, s.calendar(r).Location, "/note/"+note.ID.String())),
 		links,
+		s.readerEls(enriched, s.calendar(r).Location),
+		taskHistoryEl(note, history, s.calendar(r).Location),
 		actions,
 		//youtubeDownloadButton(note),
 	)
@@ -286,21 +334,34 @@ func (s *webservice) showNote(w http.ResponseWriter, r *http.Request) {
 	content.Render(w)
 }
 
-func (s *webservice) postEditNote(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
-	noteID, err := uuid.Parse(id)
+func (s *webservice) postEnrichNote(w http.ResponseWriter, r *http.Request) {
+	noteID, ok := s.resolveNoteID(w, r)
+	if !ok {
+		return
+	}
+
+	err := s.app.Commander.Send(commands.RequestNoteEnrichment{NoteID: noteID, URL: r.FormValue("url")})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	http.Redirect(w, r, "/note/"+noteID.String(), http.StatusSeeOther)
+}
+
+func (s *webservice) postEditNote(w http.ResponseWriter, r *http.Request) {
+	noteID, ok := s.resolveNoteID(w, r)
+	if !ok {
+		return
+	}
+
 	body := r.FormValue("body")
 	if body == "" {
 		http.Error(w, "body is required", http.StatusBadRequest)
 		return
 	}
 
-	err = s.app.Commander.Send(commands.UpdateNoteText{NoteID: noteID, Text: body})
+	err := s.app.Commander.Send(commands.UpdateNoteText{NoteID: noteID, Text: body})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -309,24 +370,69 @@ func (s *webservice) postEditNote(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/capture/tasks", http.StatusSeeOther)
 }
 
-func noteLinksEl(note note.Note) (g.Node, error) {
-	links := getLinks(note.Text)
+func noteLinksEl(n note.Note, enriched []note.Link) (g.Node, error) {
+	links := getLinks(n.Text)
 
 	return h.Div(
 		g.Map(links, func(link string) g.Node {
+			i := slices.IndexFunc(enriched, func(l note.Link) bool { return l.URL == link })
+			var status g.Node
+			if i >= 0 {
+				status = linkStatusEl(enriched[i])
+			}
 			if strings.Contains(link, "youtu") {
 				embed, err := youtubeEmbed(link)
 				if err != nil {
 					return h.Div(g.Text("error: " + err.Error()))
 				}
 
-				return h.Div(embed)
+				return h.Div(embed, status)
 			} else {
-				return h.Div(g.Text(link))
+				return h.Div(g.Text(link), status)
 			}
 		})), nil
 }
 
+// linkStatusEl shows how enrichment of a link went, with a button to look
+// it up again
+func linkStatusEl(l note.Link) g.Node {
+	style := h.Style("color:gray; font-size:80%")
+	retry := func(label string) g.Node {
+		return h.Form(h.Method("POST"), h.Action("/note/"+l.NoteID.String()+"/enrich"), h.Style("display:inline"),
+			h.Input(h.Type("hidden"), h.Name("url"), h.Value(l.URL)),
+			h.Button(h.Type("submit"), h.Class("link"), g.Text(label)),
+		)
+	}
+	switch l.Status {
+	case "enriching":
+		return h.Div(style, g.Text("enriching..."))
+	case "failure":
+		reason := "enrichment failed"
+		if l.Error != "" {
+			reason += ": " + l.Error
+		}
+		return h.Div(style, g.Text(reason+" "), retry("retry enrichment"))
+	}
+	var archive string
+	switch l.ArchiveStatus {
+	case "archiving":
+		archive = " · archiving..."
+	case "failure":
+		archive = " · archive failed"
+		if l.ArchiveError != "" {
+			archive += ": " + l.ArchiveError
+		}
+	case "archived":
+		archive = " · archived"
+	}
+	return h.Div(style,
+		g.Text(l.Title),
+		g.If(l.SiteName != "", g.Text(" · "+l.SiteName)),
+		g.Text(archive+" "),
+		retry("re-enrich"),
+	)
+}
+
 func youtubeEmbed(link string) (g.Node, error) {
 	videoID, err := youtube.ExtractVideoID(link)
 	if err != nil {
@@ -369,7 +475,7 @@ func (s *webservice) notesIndex(w http.ResponseWriter, r *http.Request) {
 
 	timeframe := chi.URLParam(r, "timeframe")
 	if timeframe != "" {
-		start, end, err := notesmeta.TimeframeRange(timeframe)
+		start, end, err := notesmeta.TimeframeRange(timeframe, s.calendar(r))
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -398,44 +504,39 @@ func (s *webservice) notesIndex(w http.ResponseWriter, r *http.Request) {
 	content.Render(w)
 }
 
-func (s *webservice) notesPeople(w http.ResponseWriter, r *http.Request) {
+func (s *webservice) notesTags(w http.ResponseWriter, r *http.Request) {
 	owner := getUserInfo(r)
-	handleParam := chi.URLParam(r, "handle")
+	tagParam := chi.URLParam(r, "tag")
 
-	people, err := s.app.Notes.FindAllPeople(owner.Id)
+	tags, err := s.app.Notes.TagCounts(owner.Id)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	var notes []note.Note
-	if handleParam == "" {
-		notes, err = s.app.Notes.FindAllWithMention(owner.Id)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
+	if tagParam == "" {
+		notes, err = s.app.Notes.FindAllWithTag(owner.Id)
 	} else {
-		notes, err = s.app.Notes.FindAllByPerson(owner.Id, handleParam)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
+		notes, err = s.app.Notes.FindAllByTag(owner.Id, tagParam)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	content, err := s.page(r, "people", "", h.Div(
+	content, err := s.page(r, "tags", "", h.Div(
 		h.Div(h.Style("background: pink; padding: 5px; display:flex; justify-content: space-between;"),
-			h.Div(h.Style("display: flex; gap: 5px;"),
-				g.Map(people, func(handle string) g.Node {
-					text := fmt.Sprintf("%s", g.Text(handle))
+			h.Div(h.Style("display: flex; gap: 5px; flex-wrap: wrap"),
+				g.Map(tags, func(tag note.TagCount) g.Node {
 					var style g.Node
-					if handle == handleParam {
+					if tag.Tag == tagParam {
 						style = h.Style("font-weight: bold")
 					}
-					return h.Div(h.A(style, g.Text(text), h.Href(fmt.Sprintf("/dsnotes/people/%s", handle))))
+					return h.Div(h.A(style, g.Textf("#%s (%d)", tag.Tag, tag.Count), h.Href("/dsnotes/tags/"+url.PathEscape(tag.Tag))))
 				}),
 			),
-			h.Div(h.A(g.Text("all"), h.Href(fmt.Sprintf("/dsnotes/people")))),
+			h.Div(h.A(g.Text("all"), h.Href("/dsnotes/tags"))),
 		),
 		g.Map(notes, func(note note.Note) g.Node {
 			return noteEl(note)
@@ -447,6 +548,50 @@ func (s *webservice) notesPeople(w http.ResponseWriter, r *http.Request) {
 	content.Render(w)
 }
 
+func (s *webservice) postAddNoteTag(w http.ResponseWriter, r *http.Request) {
+	noteID, ok := s.resolveNoteID(w, r)
+	if !ok {
+		return
+	}
+	err := s.app.Commander.Send(commands.AddNoteTag{NoteID: noteID, Tag: r.FormValue("tag")})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Redirect(w, r, "/note/"+noteID.String(), http.StatusSeeOther)
+}
+
+func (s *webservice) postRemoveNoteTag(w http.ResponseWriter, r *http.Request) {
+	noteID, ok := s.resolveNoteID(w, r)
+	if !ok {
+		return
+	}
+	err := s.app.Commander.Send(commands.RemoveNoteTag{NoteID: noteID, Tag: chi.URLParam(r, "tag")})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Redirect(w, r, "/note/"+noteID.String(), http.StatusSeeOther)
+}
+
+// noteTagsEl lists the tags of a note, with a form to add more
+func noteTagsEl(n note.Note, tags []string) g.Node {
+	return h.Div(h.Style("display:flex; flex-wrap:wrap; gap:0.5em; align-items:center; margin:0.5em 0"),
+		g.Map(tags, func(tag string) g.Node {
+			return h.Span(
+				h.A(g.Text("#"+tag), h.Href("/dsnotes/tags/"+url.PathEscape(tag))),
+				h.Form(h.Method("POST"), h.Action(fmt.Sprintf("/note/%s/tags/%s/remove", n.ID, url.PathEscape(tag))), h.Style("display:inline"),
+					h.Button(h.Type("submit"), h.Style("color:gray; padding:0 0.25em"), h.Title("remove tag"), g.Text("×")),
+				),
+			)
+		}),
+		h.Form(h.Method("POST"), h.Action(fmt.Sprintf("/note/%s/tags", n.ID)), h.Style("display:inline-flex; gap:0.25em"),
+			h.Input(h.Name("tag"), h.Placeholder("add tag"), h.AutoComplete("off"), g.Attr("size", "10")),
+			h.Button(h.Type("submit"), g.Text("tag")),
+		),
+	)
+}
+
 func (s *webservice) page(r *http.Request, category string, subcategory string, node g.Node) (g.Node, error) {
 	headerEl, err := s.header(r, category, subcategory)
 	if err != nil {
@@ -673,7 +818,9 @@ func greenHeader(category string, subcategory string, categoryCounts []note.Cate
 					} else {
 						return h.Div(h.A(g.Text(text), h.Href("/dsnotes/"+c.Slug)))
 					}
-				}))))
+				}),
+				h.Div(h.A(g.If(category == "tags", h.Style("font-weight: bold")), g.Text("Tags"), h.Href("/dsnotes/tags"))),
+			)))
 }
 
 func pinkHeader(categorySlug string, subcategory string, categoryCounts []note.CategoryCount, subcategoryCounts []note.SubcategoryCount) g.Node {
@@ -734,7 +881,9 @@ func noteEl(note note.Note) g.Node {
 						return h.Span(g.Text(fmt.Sprintf(" %dd", until)))
 					}),
 				)),
+			suggestionEl(note),
 		),
+		linkCardEl(note),
 		h.Div(h.Style("color: gray; font-size: 70%; margin-top: -3px;"),
 			h.Div(h.Style("display:flex; gap:2px"),
 				refile(note),
@@ -743,6 +892,77 @@ func noteEl(note note.Note) g.Node {
 	)
 }
 
+// linkCardEl shows what enrichment found at the link in a note
+func linkCardEl(n note.Note) g.Node {
+	if n.LinkTitle == "" && n.LinkThumbnail == "" {
+		return nil
+	}
+	href := n.LinkURL
+	if links := getLinks(n.Text); href == "" && len(links) > 0 {
+		href = links[0]
+	}
+	site := n.LinkSiteName
+	if site == "" {
+		site, _ = getDomain(href)
+	}
+	return h.A(h.Href(href), h.Target("_blank"), h.Rel("noopener"), h.Class("link-card"),
+		h.Style("display:flex; gap:0.5em; margin:0.25em 0; padding:0.25em; border:1px solid #ddd; border-radius:4px; text-decoration:none; color:inherit; max-width:40em; overflow:hidden"),
+		g.If(n.LinkThumbnail != "", h.Img(h.Src(n.LinkThumbnail), h.Alt(""), g.Attr("loading", "lazy"), h.Style("width:6em; height:4em; object-fit:cover; flex:none"))),
+		h.Div(h.Style("min-width:0"),
+			h.Div(h.Style("font-weight:bold; overflow:hidden; text-overflow:ellipsis; white-space:nowrap"), g.Text(n.LinkTitle)),
+			g.If(n.LinkDescription != "", h.Div(h.Style("font-size:80%; max-height:2.6em; overflow:hidden"), g.Text(n.LinkDescription))),
+			h.Div(h.Style("font-size:70%; color:gray"),
+				g.Text(site),
+				g.If(n.LinkContentType != "", g.Text(" · "+n.LinkContentType)),
+			),
+		),
+	)
+}
+
+// Show when a task was completed, deferred and reopened
+func taskHistoryEl(n note.Note, history []note.TaskEvent, loc *time.Location) g.Node {
+	if len(history) == 0 {
+		return nil
+	}
+	day := func(ts *int64) string {
+		if ts == nil {
+			return "someday"
+		}
+		return notesmeta.FormatDue(time.Unix(*ts, 0), loc)
+	}
+	return h.Div(h.Style("color:gray; font-size:80%; margin:1em 0"),
+		h.Div(g.Textf("deferred %d times, reopened %d times", n.DeferredCount, n.ReopenedCount)),
+		h.Ul(g.Map(history, func(e note.TaskEvent) g.Node {
+			text := time.Unix(e.Ts, 0).In(loc).Format("Mon Jan 2 15:04") + " " + e.Kind
+			switch e.Kind {
+			case "deferred":
+				text += " " + day(e.From) + " → " + day(e.To)
+			case "recurred":
+				text += " → " + day(e.To)
+			}
+			return h.Li(g.Text(text))
+		})),
+	)
+}
+
+// Show the classification suggested by the classifier, if any
+func suggestionEl(n note.Note) g.Node {
+	if n.SuggestedCategory == "" {
+		return nil
+	}
+	cat := notesmeta.Categories.Get(n.SuggestedCategory)
+	text := "suggested: " + cat.DisplayName
+	if n.SuggestedSubcategory != "" {
+		text += " › " + cat.Subcategories.Get(n.SuggestedSubcategory).DisplayName
+	}
+	if n.SuggestedTimeframe == "someday" {
+		text += " › Someday"
+	} else if ok, tf := notesmeta.TimeframeLookup(n.SuggestedTimeframe); ok {
+		text += " › " + tf.DisplayName
+	}
+	return h.Span(h.Style("color:gray; font-size:70%; margin-left:0.5em"), g.Text(text))
+}
+
 func noteCategoryDisplay(n note.Note) string {
 	cat := notesmeta.Categories.Get(n.Category)
 	subcat := cat.Subcategories.Get(n.Subcategory)
@@ -758,7 +978,7 @@ func deletedNoteEl(note note.Note) g.Node {
 	return h.Div(h.ID(noteID(note)),
 		h.Div(h.Style("color: gray; text-decoration: line-through"),
 			h.A(h.Href(noteLink(note)),
-				linkifyNode(note.Status+" "+note.Text)),
+				linkifyNode(note.Status+" "+note.DisplayText())),
 		),
 		h.Div(h.Style("color: gray; font-size: 70%; margin-top: -3px"),
 			h.Div(h.Style("display:flex; gap:2px"),
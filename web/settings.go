@@ -0,0 +1,150 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rcy/evoke"
+	"github.com/rcy/whatever/api"
+	"github.com/rcy/whatever/catalog/notesmeta"
+	"github.com/rcy/whatever/commands"
+	"github.com/rcy/whatever/projections/user"
+	g "maragu.dev/gomponents"
+	h "maragu.dev/gomponents/html"
+)
+
+// updatePreferences sends a command for each preference in prefs that
+// differs from the current value
+func (s *webservice) updatePreferences(owner string, prefs api.Preferences) error {
+	current := s.app.Users.Preferences(owner)
+	userID := commands.UserID(owner)
+
+	var cmds []evoke.Command
+	if prefs.DisplayName != nil && strings.TrimSpace(*prefs.DisplayName) != current.DisplayName {
+		cmds = append(cmds, commands.SetUserDisplayName{UserID: userID, Owner: owner, DisplayName: *prefs.DisplayName})
+	}
+	if prefs.TimeZone != nil && strings.TrimSpace(*prefs.TimeZone) != current.TimeZone {
+		cmds = append(cmds, commands.SetUserTimeZone{UserID: userID, Owner: owner, TimeZone: *prefs.TimeZone})
+	}
+	if prefs.WeekStart != nil {
+		weekStart, err := notesmeta.ParseWeekday(*prefs.WeekStart)
+		if err != nil {
+			return err
+		}
+		if weekStart != time.Weekday(current.WeekStart) {
+			cmds = append(cmds, commands.SetUserWeekStart{UserID: userID, Owner: owner, WeekStart: weekStart})
+		}
+	}
+	if prefs.CaptureCategory != nil && *prefs.CaptureCategory != current.CaptureCategory {
+		cmds = append(cmds, commands.SetUserCaptureCategory{UserID: userID, Owner: owner, Category: *prefs.CaptureCategory})
+	}
+	if prefs.ClassifierEnabled != nil && *prefs.ClassifierEnabled != current.ClassifierEnabled {
+		cmds = append(cmds, commands.SetUserClassifierEnabled{UserID: userID, Owner: owner, Enabled: *prefs.ClassifierEnabled})
+	}
+
+	for _, cmd := range cmds {
+		if err := s.app.Commander.Send(cmd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// me returns the api representation of a user's preferences
+func me(u user.User) api.Me {
+	cal := u.Calendar()
+	return api.Me{
+		ID:                u.Owner,
+		DisplayName:       u.DisplayName,
+		Email:             u.Email,
+		TimeZone:          cal.Location.String(),
+		WeekStart:         strings.ToLower(cal.WeekStart.String()),
+		CaptureCategory:   u.CaptureCategory,
+		ClassifierEnabled: u.ClassifierEnabled,
+	}
+}
+
+func (s *webservice) postPreferences(w http.ResponseWriter, r *http.Request) {
+	owner := getUserInfo(r).Id
+	displayName := r.FormValue("displayName")
+	timeZone := r.FormValue("timeZone")
+	weekStart := r.FormValue("weekStart")
+	captureCategory := r.FormValue("captureCategory")
+	classifierEnabled := r.FormValue("classifierEnabled") == "on"
+
+	prefs := api.Preferences{
+		TimeZone:          &timeZone,
+		WeekStart:         &weekStart,
+		CaptureCategory:   &captureCategory,
+		ClassifierEnabled: &classifierEnabled,
+	}
+	// an empty display name keeps the one from the google account
+	if strings.TrimSpace(displayName) != "" {
+		prefs.DisplayName = &displayName
+	}
+
+	err := s.updatePreferences(owner, prefs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	http.Redirect(w, r, "/settings", http.StatusSeeOther)
+}
+
+func preferencesSection(u user.User) g.Node {
+	cal := u.Calendar()
+	row := func(label string, input g.Node, hint string) g.Node {
+		return h.Label(h.Style("display:flex; flex-direction:column; gap:0.25em"),
+			h.Span(g.Text(label)),
+			input,
+			g.If(hint != "", h.Small(h.Style("color:gray"), g.Text(hint))),
+		)
+	}
+
+	weekdays := []time.Weekday{}
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		weekdays = append(weekdays, d)
+	}
+
+	return h.Div(
+		h.H3(g.Text("Preferences")),
+		h.Form(
+			h.Method("POST"),
+			h.Action("/settings/preferences"),
+			h.Style("display:flex; flex-direction:column; gap:0.75em; max-width:24em"),
+			row("Display name",
+				h.Input(h.Name("displayName"), h.Value(u.DisplayName), h.AutoComplete("off")),
+				""),
+			row("Time zone",
+				h.Div(h.Style("display:flex; gap:0.5em"),
+					h.Input(h.ID("timeZone"), h.Name("timeZone"), h.Value(cal.Location.String()), h.Placeholder(notesmeta.DefaultTimeZone), h.Required(), h.AutoComplete("off")),
+					h.Button(h.Type("button"),
+						g.Attr("onclick", "document.getElementById('timeZone').value = Intl.DateTimeFormat().resolvedOptions().timeZone"),
+						g.Text("use this device's"),
+					),
+				),
+				"Used to work out which day tasks are due."),
+			row("Week starts on",
+				h.Select(h.Name("weekStart"),
+					g.Map(weekdays, func(d time.Weekday) g.Node {
+						return h.Option(h.Value(strings.ToLower(d.String())), g.If(d == cal.WeekStart, h.Selected()), g.Text(d.String()))
+					}),
+				),
+				"Used for this week and next week."),
+			row("Capture into",
+				h.Select(h.Name("captureCategory"),
+					g.Map(notesmeta.RefileCategories, func(c notesmeta.Category) g.Node {
+						return h.Option(h.Value(c.Slug), g.If(c.Slug == u.CaptureCategory, h.Selected()), g.Text(c.DisplayName))
+					}),
+				),
+				"Where new notes go when no category is given."),
+			h.Label(h.Style("display:flex; gap:0.5em; align-items:center"),
+				h.Input(h.Type("checkbox"), h.Name("classifierEnabled"), g.If(u.ClassifierEnabled, h.Checked())),
+				g.Text("Classify new notes automatically"),
+			),
+			h.Div(h.Button(h.Type("submit"), g.Text("save"))),
+		),
+	)
+}
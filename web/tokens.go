@@ -0,0 +1,155 @@
+package web
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/rcy/whatever/commands"
+	"github.com/rcy/whatever/projections/user"
+	googleoauth "google.golang.org/api/oauth2/v2"
+	g "maragu.dev/gomponents"
+	h "maragu.dev/gomponents/html"
+)
+
+const apiTokenPrefix = "wtk_"
+
+var errReadOnlyToken = errors.New("token is read-only")
+
+// newAPIToken returns a new random token and the hash to store for it
+func newAPIToken() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token := apiTokenPrefix + base64.RawURLEncoding.EncodeToString(buf)
+	return token, hashAPIToken(token), nil
+}
+
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// bearerToken returns the token in the Authorization header, if any
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return "", false
+	}
+	scheme, token, _ := strings.Cut(header, " ")
+	if !strings.EqualFold(scheme, "bearer") {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+// authenticate returns the user making the request, from an api token in
+// the Authorization header or from the session cookie. Read-only tokens are
+// refused for requests that could change anything.
+func (s *webservice) authenticate(r *http.Request) (googleoauth.Userinfo, error) {
+	token, ok := bearerToken(r)
+	if !ok {
+		return s.sessions.currentUser(r)
+	}
+
+	apiToken, err := s.app.Users.FindAPITokenByHash(hashAPIToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return googleoauth.Userinfo{}, errors.New("invalid api token")
+	}
+	if err != nil {
+		return googleoauth.Userinfo{}, err
+	}
+	if apiToken.ReadOnly && r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return googleoauth.Userinfo{}, errReadOnlyToken
+	}
+	return googleoauth.Userinfo{Id: apiToken.Owner}, nil
+}
+
+func (s *webservice) postCreateAPIToken(w http.ResponseWriter, r *http.Request) {
+	owner := getUserInfo(r).Id
+
+	token, hash, err := newAPIToken()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = s.app.Commander.Send(commands.CreateAPIToken{
+		TokenID:  uuid.New(),
+		Owner:    owner,
+		Name:     r.FormValue("name"),
+		Hash:     hash,
+		ReadOnly: r.FormValue("readonly") != "",
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// the token is only ever shown here
+	s.renderSettings(w, r, token)
+}
+
+func (s *webservice) postRevokeAPIToken(w http.ResponseWriter, r *http.Request) {
+	tokenID, err := uuid.Parse(chi.URLParam(r, "tokenID"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = s.app.Commander.Send(commands.RevokeAPIToken{
+		TokenID: tokenID,
+		Owner:   getUserInfo(r).Id,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	http.Redirect(w, r, "/settings", http.StatusSeeOther)
+}
+
+func apiTokensSection(tokens []user.APIToken, newToken string) g.Node {
+	return h.Div(
+		h.H3(g.Text("API tokens")),
+		g.If(newToken != "",
+			h.P(
+				g.Text("New token, copy it now, it will not be shown again: "),
+				h.Code(h.Style("user-select:all; background:#eee; padding:0.25em"), g.Text(newToken)),
+			),
+		),
+		h.Div(h.Class("note-list"), h.Style("padding:0"),
+			g.Map(tokens, func(t user.APIToken) g.Node {
+				return h.Div(h.Class("note-item"),
+					h.Span(g.Text(t.Name)),
+					g.If(t.ReadOnly, h.Span(h.Style("color:gray"), g.Text(" read-only"))),
+					h.Span(h.Style("color:gray"), g.Text(" created "+ago(time.Unix(t.CreatedAt, 0)))),
+					h.Form(
+						h.Method("POST"),
+						h.Action(fmt.Sprintf("/settings/tokens/%s/revoke", t.ID)),
+						h.Style("display:inline"),
+						h.Button(h.Type("submit"), g.Text("revoke")),
+					),
+				)
+			}),
+		),
+		h.Form(
+			h.Method("POST"),
+			h.Action("/settings/tokens"),
+			h.Style("display:flex; gap:0.5em; align-items:center; margin-top:0.5em"),
+			h.Input(h.Name("name"), h.Placeholder("token name"), h.Required(), h.AutoComplete("off")),
+			h.Label(h.Input(h.Type("checkbox"), h.Name("readonly")), g.Text(" read-only")),
+			h.Button(h.Type("submit"), g.Text("create token")),
+		),
+	)
+}
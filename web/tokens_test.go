@@ -0,0 +1,101 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rcy/whatever/commands"
+)
+
+func withToken(token string) func(*http.Request) {
+	return func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) }
+}
+
+func TestAPITokens(t *testing.T) {
+	a, handler := newTestServer(t)
+
+	createToken := func(readOnly bool) (uuid.UUID, string) {
+		token, hash, err := newAPIToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+		id := uuid.New()
+		err = a.Commander.Send(commands.CreateAPIToken{TokenID: id, Owner: "alice", Name: "test", Hash: hash, ReadOnly: readOnly})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id, token
+	}
+	id, token := createToken(false)
+	_, readOnly := createToken(true)
+
+	var created testNote
+	status := apiRequest(t, handler, withToken(token), "POST", "/notes", map[string]string{"text": "from a script", "category": "task"}, &created)
+	if status != http.StatusCreated {
+		t.Fatalf("create with token: %d", status)
+	}
+
+	tests := []struct {
+		name   string
+		auth   func(*http.Request)
+		method string
+		path   string
+		body   any
+		status int
+	}{
+		{"token reads", withToken(token), "GET", "/notes/" + created.ID, nil, http.StatusOK},
+		{"read-only token reads", withToken(readOnly), "GET", "/notes/" + created.ID, nil, http.StatusOK},
+		{"read-only token cannot write", withToken(readOnly), "PATCH", "/notes/" + created.ID, map[string]string{"text": "changed"}, http.StatusForbidden},
+		{"unknown token", withToken("not a token"), "GET", "/notes", nil, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := apiRequest(t, handler, tt.auth, tt.method, tt.path, tt.body, nil)
+			if status != tt.status {
+				t.Errorf("got %d, want %d", status, tt.status)
+			}
+		})
+	}
+
+	err := a.Commander.Send(commands.RevokeAPIToken{TokenID: id, Owner: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	status = apiRequest(t, handler, withToken(token), "GET", "/notes", nil, nil)
+	if status != http.StatusUnauthorized {
+		t.Errorf("revoked token: got %d, want 401", status)
+	}
+}
+
+func TestTokensAreManagedFromSessions(t *testing.T) {
+	a, handler := newTestServer(t)
+	token, hash, err := newAPIToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = a.Commander.Send(commands.CreateAPIToken{TokenID: uuid.New(), Owner: "alice", Name: "test", Hash: hash})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(auth func(*http.Request)) int {
+		form := url.Values{"name": {"another"}}
+		req := httptest.NewRequest("POST", "/settings/tokens", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		auth(req)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if status := post(withToken(token)); status != http.StatusForbidden {
+		t.Errorf("with a token: got %d, want 403", status)
+	}
+	if status := post(asOwner(t, "alice")); status != http.StatusOK {
+		t.Errorf("with a session: got %d, want 200", status)
+	}
+}
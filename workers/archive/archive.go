@@ -0,0 +1,111 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rcy/evoke"
+	"github.com/rcy/whatever/blobs"
+	"github.com/rcy/whatever/commands"
+	"github.com/rcy/whatever/events"
+	"github.com/rcy/whatever/jobs"
+)
+
+const JobKind = "archive"
+
+// pages bigger than this are cut off
+const maxPageSize = 5 << 20
+
+type worker struct {
+	cmdSender evoke.CommandSender
+	queue     *jobs.Queue
+	blobs     *blobs.Store
+	client    *http.Client
+}
+
+func NewWorker(cmdSender evoke.CommandSender, queue *jobs.Queue, blobStore *blobs.Store) *worker {
+	return &worker{
+		cmdSender: cmdSender,
+		queue:     queue,
+		blobs:     blobStore,
+		client:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Enqueue enqueues a job for the event in rec
+func (w worker) Enqueue(rec evoke.RecordedEvent) error {
+	evt, ok := rec.Event.(events.NoteArchiveRequested)
+	if !ok {
+		return fmt.Errorf("not a NoteArchiveRequested event")
+	}
+
+	return w.queue.Enqueue(JobKind, rec.Sequence, evt)
+}
+
+// Work runs an archive job enqueued by Enqueue. The readable text of the
+// page is saved in the blob store, and the note is only marked as failed
+// once the job has run out of retries.
+func (w worker) Work(ctx context.Context, job jobs.Job) error {
+	var evt events.NoteArchiveRequested
+	err := job.Unmarshal(&evt)
+	if err != nil {
+		return err
+	}
+
+	hash, err := w.archive(ctx, evt.URL)
+	if err != nil {
+		if job.LastAttempt() {
+			sendErr := w.cmdSender.Send(commands.FailNoteArchive{
+				NoteID:   evt.NoteID,
+				URL:      evt.URL,
+				FailedAt: time.Now(),
+				Reason:   err.Error(),
+			})
+			if sendErr != nil {
+				return sendErr
+			}
+		}
+		return err
+	}
+
+	return w.cmdSender.Send(commands.CompleteNoteArchive{
+		NoteID:     evt.NoteID,
+		URL:        evt.URL,
+		Hash:       hash,
+		ArchivedAt: time.Now(),
+	})
+}
+
+// archive fetches the page at link and stores its readable text
+func (w worker) archive(ctx context.Context, link string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HTTP error: %s", resp.Status)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if contentType != "" && !strings.Contains(contentType, "html") {
+		return "", fmt.Errorf("not a web page: %s", contentType)
+	}
+
+	text, err := Readable(io.LimitReader(resp.Body, maxPageSize))
+	if err != nil {
+		return "", fmt.Errorf("extract text: %w", err)
+	}
+	if text == "" {
+		return "", fmt.Errorf("no readable text on page")
+	}
+	return w.blobs.Put([]byte(text))
+}
@@ -2,73 +2,213 @@ package classify
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
-	"strings"
 
-	"github.com/openai/openai-go"
 	"github.com/rcy/evoke"
+	"github.com/rcy/whatever/catalog/notesmeta"
 	"github.com/rcy/whatever/commands"
 	"github.com/rcy/whatever/events"
+	"github.com/rcy/whatever/jobs"
+	"github.com/rcy/whatever/projections/note"
 )
 
-type Worker struct {
-	cmdSender evoke.CommandSender
+const JobKind = "classify"
+
+// Results below this confidence are only suggested, the note stays where
+// it is
+const minConfidence = 0.6
+
+// Result is where a classifier thinks a note belongs
+type Result struct {
+	Category    string  // "task" or "reference"
+	Subcategory string  // reference subcategory, eg "idea" or "quote"; empty if unsure
+	Timeframe   string  // task timeframe slug, eg "today", or "someday"; empty if none
+	Confidence  float64 // 0 to 1
 }
 
-func NewWorker(cmdSender evoke.CommandSender) *Worker {
-	return &Worker{cmdSender: cmdSender}
+func (r Result) String() string {
+	s := r.Category
+	if r.Subcategory != "" {
+		s += "/" + r.Subcategory
+	}
+	if r.Timeframe != "" {
+		s += "/" + r.Timeframe
+	}
+	return fmt.Sprintf("%s (%.2f)", s, r.Confidence)
 }
 
-func (w *Worker) Handle(e evoke.Event, replay bool) error {
-	evt, ok := e.(events.NoteCreated)
-	if !ok {
-		return fmt.Errorf("not a NoteCreated event")
+// Confident reports whether the result is good enough to file the note
+func (r Result) Confident() bool {
+	return r.Confidence >= minConfidence
+}
+
+// normalize drops anything in the result that notesmeta doesn't know about
+func (r Result) normalize() (Result, error) {
+	switch r.Category {
+	case notesmeta.Task.Slug:
+		r.Subcategory = ""
+		if r.Timeframe != "someday" {
+			if ok, _ := notesmeta.TimeframeLookup(r.Timeframe); !ok {
+				r.Timeframe = ""
+			}
+		}
+	case notesmeta.Note.Slug:
+		r.Timeframe = ""
+		if ok, _ := notesmeta.Note.Inbox().Transitions.Get(r.Subcategory); !ok {
+			r.Subcategory = ""
+		}
+	default:
+		return Result{}, fmt.Errorf("unexpected category: %q", r.Category)
 	}
+	r.Confidence = min(max(r.Confidence, 0), 1)
+	return r, nil
+}
 
-	if evt.Category != "inbox" {
+// transitionEvent returns the subcategory transition for the result, if any
+func (r Result) transitionEvent() string {
+	if r.Subcategory != "" {
+		return r.Subcategory
+	}
+	if r.Timeframe == "someday" {
+		return r.Timeframe
+	}
+	if ok, tf := notesmeta.TimeframeLookup(r.Timeframe); ok {
+		return tf.EventName
+	}
+	return ""
+}
+
+// Classifier decides where a note belongs
+type Classifier interface {
+	Classify(ctx context.Context, text string) (Result, error)
+}
+
+// New returns the classifier named by kind, either "openai" or "rules".
+// baseURL and model only apply to "openai" and may be empty to use the
+// defaults.
+func New(kind string, baseURL string, model string) (Classifier, error) {
+	switch kind {
+	case "openai":
+		return NewOpenAI(baseURL, model), nil
+	case "rules":
+		return Rules{}, nil
+	}
+	return nil, fmt.Errorf("unknown classifier: %q", kind)
+}
+
+// Classify runs classifier on text and checks the result against notesmeta
+func Classify(ctx context.Context, classifier Classifier, text string) (Result, error) {
+	result, err := classifier.Classify(ctx, text)
+	if err != nil {
+		return Result{}, err
+	}
+	return result.normalize()
+}
+
+// File sends the commands that move an unfiled note into the place given
+// by result. Notes that have been filed already, or that were put in a
+// different category by the user, are left alone. Results that are not
+// confident are recorded as a suggestion only.
+func File(cmdSender evoke.CommandSender, n note.Note, result Result) error {
+	noteID := n.ID
+	category := n.Category
+	if n.Subcategory != notesmeta.Categories.Get(category).Inbox().Slug {
+		return nil
+	}
+	if category != notesmeta.Inbox.Slug && category != result.Category {
 		return nil
 	}
 
-	go func() {
-		category, err := Categorize(evt.Text)
-		if err != nil {
-			fmt.Println("classify error:", err)
-			return
-		}
+	if !result.Confident() {
+		return cmdSender.Send(commands.SuggestNoteClassification{
+			NoteID:      noteID,
+			Category:    result.Category,
+			Subcategory: result.Subcategory,
+			Timeframe:   result.Timeframe,
+			Confidence:  result.Confidence,
+		})
+	}
 
-		w.cmdSender.MustSend(commands.SetNoteCategory{
-			NoteID:   evt.NoteID,
-			Category: category,
+	if category == notesmeta.Inbox.Slug {
+		err := cmdSender.Send(commands.SetNoteCategory{
+			NoteID:   noteID,
+			Category: result.Category,
 			Actor:    "ai",
 		})
-	}()
+		if err != nil {
+			return err
+		}
+	}
 
-	return nil
+	event := result.transitionEvent()
+	if event == "" {
+		return nil
+	}
+	return cmdSender.Send(commands.TransitionNoteSubcategory{
+		NoteID:          noteID,
+		TransitionEvent: event,
+		Actor:           "ai",
+	})
 }
 
-// Categorize calls gpt-4o-mini and returns "task" or "reference".
-func Categorize(text string) (string, error) {
-	client := openai.NewClient()
+// Preferences tells the worker which users want their notes classified
+type Preferences interface {
+	ClassifierEnabled(owner string) bool
+}
 
-	prompt := fmt.Sprintf(
-		"Classify the following as 'task' (something to do/action item) or 'reference' (something to remember/record). Reply with only: task or reference\n\nText: %s",
-		text,
-	)
+type Worker struct {
+	cmdSender  evoke.CommandSender
+	queue      *jobs.Queue
+	notes      *note.Projection
+	prefs      Preferences
+	classifier Classifier
+}
 
-	msg, err := client.Chat.Completions.New(context.Background(), openai.ChatCompletionNewParams{
-		Model: openai.ChatModelGPT4oMini,
-		Messages: []openai.ChatCompletionMessageParamUnion{
-			openai.UserMessage(prompt),
-		},
-	})
+func NewWorker(cmdSender evoke.CommandSender, queue *jobs.Queue, notes *note.Projection, prefs Preferences, classifier Classifier) *Worker {
+	return &Worker{cmdSender: cmdSender, queue: queue, notes: notes, prefs: prefs, classifier: classifier}
+}
+
+// Enqueue enqueues a job for the event in rec
+func (w *Worker) Enqueue(rec evoke.RecordedEvent) error {
+	evt, ok := rec.Event.(events.NoteCreated)
+	if !ok {
+		return fmt.Errorf("not a NoteCreated event")
+	}
+
+	// only classify notes that have not been filed yet
+	if evt.Subcategory != notesmeta.Categories.Get(evt.Category).Inbox().Slug {
+		return nil
+	}
+	if !w.prefs.ClassifierEnabled(evt.Owner) {
+		return nil
+	}
+
+	return w.queue.Enqueue(JobKind, rec.Sequence, evt)
+}
+
+// Work runs a classify job enqueued by Enqueue
+func (w *Worker) Work(ctx context.Context, job jobs.Job) error {
+	var evt events.NoteCreated
+	err := job.Unmarshal(&evt)
 	if err != nil {
-		return "", err
+		return err
 	}
 
-	category := strings.TrimSpace(msg.Choices[0].Message.Content)
-	if category != "task" && category != "reference" {
-		return "", fmt.Errorf("unexpected response: %q", category)
+	// the note may have been filed or deleted since the job was enqueued
+	n, err := w.notes.FindOne(evt.NoteID.String())
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	result, err := Classify(ctx, w.classifier, n.Text)
+	if err != nil {
+		return err
 	}
 
-	return category, nil
+	return File(w.cmdSender, n, result)
 }
@@ -0,0 +1,127 @@
+package classify
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRulesClassify(t *testing.T) {
+	tests := []struct {
+		text string
+		want Result
+	}{
+		{"buy milk", Result{Category: "task", Confidence: 0.8}},
+		{"Call the bank tomorrow", Result{Category: "task", Timeframe: "tomorrow", Confidence: 0.8}},
+		{"need to renew passport next week", Result{Category: "task", Timeframe: "nextweek", Confidence: 0.8}},
+		{"don't forget the recycling tonight", Result{Category: "task", Timeframe: "today", Confidence: 0.8}},
+		{"https://www.youtube.com/watch?v=I8jfn8k8vpM", Result{Category: "reference", Subcategory: "watch", Confidence: 0.9}},
+		{"https://open.spotify.com/album/1", Result{Category: "reference", Subcategory: "listen", Confidence: 0.9}},
+		{"https://example.com/post", Result{Category: "reference", Subcategory: "bookmark", Confidence: 0.7}},
+		{"an article about sourdough", Result{Category: "reference", Subcategory: "read", Confidence: 0.7}},
+		{"grateful for the sunshine", Result{Category: "reference", Subcategory: "gratitude", Confidence: 0.7}},
+		{"idea: a bike rack for the balcony", Result{Category: "reference", Subcategory: "idea", Confidence: 0.7}},
+		{`"Simplicity is prerequisite for reliability"`, Result{Category: "reference", Subcategory: "quote", Confidence: 0.7}},
+		{"I realized mornings are better", Result{Category: "reference", Subcategory: "reflection", Confidence: 0.7}},
+		{"garden party someday", Result{Category: "task", Timeframe: "someday", Confidence: 0.5}},
+		{"the sky is blue", Result{Category: "reference", Confidence: 0.4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, err := Rules{}.Classify(context.Background(), tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if _, err := got.normalize(); err != nil {
+				t.Errorf("normalize: %s", err)
+			}
+		})
+	}
+}
+
+func TestResultNormalize(t *testing.T) {
+	tests := []struct {
+		name    string
+		result  Result
+		want    Result
+		wantErr bool
+	}{
+		{
+			name:   "task keeps known timeframe",
+			result: Result{Category: "task", Timeframe: "thisweek", Confidence: 0.9},
+			want:   Result{Category: "task", Timeframe: "thisweek", Confidence: 0.9},
+		},
+		{
+			name:   "task keeps someday",
+			result: Result{Category: "task", Timeframe: "someday", Confidence: 0.7},
+			want:   Result{Category: "task", Timeframe: "someday", Confidence: 0.7},
+		},
+		{
+			name:   "task drops unknown timeframe and subcategory",
+			result: Result{Category: "task", Subcategory: "idea", Timeframe: "fortnight", Confidence: 0.7},
+			want:   Result{Category: "task", Confidence: 0.7},
+		},
+		{
+			name:   "reference keeps known subcategory",
+			result: Result{Category: "reference", Subcategory: "quote", Confidence: 0.8},
+			want:   Result{Category: "reference", Subcategory: "quote", Confidence: 0.8},
+		},
+		{
+			name:   "reference drops unknown subcategory and timeframe",
+			result: Result{Category: "reference", Subcategory: "recipe", Timeframe: "today", Confidence: 0.8},
+			want:   Result{Category: "reference", Confidence: 0.8},
+		},
+		{
+			name:   "confidence is clamped above",
+			result: Result{Category: "reference", Confidence: 1.5},
+			want:   Result{Category: "reference", Confidence: 1},
+		},
+		{
+			name:   "confidence is clamped below",
+			result: Result{Category: "task", Confidence: -0.2},
+			want:   Result{Category: "task", Confidence: 0},
+		},
+		{
+			name:    "unknown category",
+			result:  Result{Category: "inbox", Confidence: 0.9},
+			wantErr: true,
+		},
+		{
+			name:    "empty category",
+			result:  Result{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.result.normalize()
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("got %v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, kind := range []string{"openai", "rules"} {
+		_, err := New(kind, "", "")
+		if err != nil {
+			t.Errorf("New(%q): %s", kind, err)
+		}
+	}
+	_, err := New("magic", "", "")
+	if err == nil {
+		t.Error("New(magic): want error")
+	}
+}
@@ -0,0 +1,92 @@
+package classify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"github.com/rcy/whatever/catalog/notesmeta"
+)
+
+const defaultModel = openai.ChatModelGPT4oMini
+
+// OpenAI classifies with a chat completions endpoint. Any server that speaks
+// the OpenAI API works, including local ones. The api key is read from
+// OPENAI_API_KEY.
+type OpenAI struct {
+	client openai.Client
+	model  openai.ChatModel
+}
+
+func NewOpenAI(baseURL string, model string) *OpenAI {
+	opts := []option.RequestOption{}
+	if baseURL != "" {
+		opts = append(opts, option.WithBaseURL(baseURL))
+	}
+	c := &OpenAI{client: openai.NewClient(opts...), model: defaultModel}
+	if model != "" {
+		c.model = model
+	}
+	return c
+}
+
+var prompt = func() string {
+	var subcategories []string
+	for _, t := range notesmeta.Note.Inbox().Transitions {
+		subcategories = append(subcategories, t.Event)
+	}
+	var timeframes []string
+	for _, tf := range notesmeta.TimeframeList {
+		timeframes = append(timeframes, tf.Slug)
+	}
+	timeframes = append(timeframes, "someday")
+
+	return fmt.Sprintf(`Classify the note below as a "task" (something to do/action item) or a "reference" (something to remember/record).
+For a reference, pick the best subcategory from: %s.
+For a task, pick a timeframe from: %s, but only if the note says when it should be done.
+Reply with only a json object like {"category": "reference", "subcategory": "idea", "timeframe": "", "confidence": 0.8}
+where confidence is between 0 and 1.
+
+Note: %%s`, strings.Join(subcategories, ", "), strings.Join(timeframes, ", "))
+}()
+
+// Classify asks the model for a json classification
+func (c *OpenAI) Classify(ctx context.Context, text string) (Result, error) {
+	msg, err := c.client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
+		Model: c.model,
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.UserMessage(fmt.Sprintf(prompt, text)),
+		},
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	if len(msg.Choices) == 0 {
+		return Result{}, fmt.Errorf("no choices in response")
+	}
+
+	content := strings.TrimSpace(msg.Choices[0].Message.Content)
+	content = strings.TrimPrefix(content, "```json")
+	content = strings.Trim(content, "` \n")
+
+	var reply struct {
+		Category    string  `json:"category"`
+		Subcategory string  `json:"subcategory"`
+		Timeframe   string  `json:"timeframe"`
+		Confidence  float64 `json:"confidence"`
+	}
+	err = json.Unmarshal([]byte(content), &reply)
+	if err != nil {
+		return Result{}, fmt.Errorf("unexpected response: %q", content)
+	}
+
+	return Result{
+		Category:    strings.ToLower(reply.Category),
+		Subcategory: strings.ToLower(reply.Subcategory),
+		Timeframe:   strings.ToLower(reply.Timeframe),
+		Confidence:  reply.Confidence,
+	}, nil
+}
@@ -0,0 +1,111 @@
+package classify
+
+import (
+	"context"
+	"regexp"
+	"strings"
+)
+
+// Rules classifies with keyword matching. It is deterministic and needs no
+// network.
+type Rules struct{}
+
+var taskVerbs = []string{
+	"buy", "call", "email", "text", "book", "schedule", "pay", "fix", "clean",
+	"finish", "send", "write", "ask", "pick", "get", "make", "order", "return",
+	"renew", "cancel", "submit", "check", "update", "install", "replace",
+	"reply", "prepare", "plan", "organize", "file", "print", "sign", "visit",
+	"go", "take", "bring", "move", "sell", "mail", "post", "register", "todo",
+}
+
+var taskPhrases = regexp.MustCompile(`(?i)\b(need to|have to|must|should|don't forget|remember to|to do|todo|asap|by (today|tonight|tomorrow|monday|tuesday|wednesday|thursday|friday|saturday|sunday))\b`)
+
+var urlOnly = regexp.MustCompile(`^\S+://\S+$`)
+
+// Timeframe keywords, checked in order
+var timeframeRules = []struct {
+	re        *regexp.Regexp
+	timeframe string
+}{
+	{regexp.MustCompile(`(?i)\b(today|tonight|this (morning|afternoon|evening))\b`), "today"},
+	{regexp.MustCompile(`(?i)\btomorrow\b`), "tomorrow"},
+	{regexp.MustCompile(`(?i)\bnext week\b`), "nextweek"},
+	{regexp.MustCompile(`(?i)\b(this week|this weekend)\b`), "thisweek"},
+	{regexp.MustCompile(`(?i)\bnext month\b`), "nextmonth"},
+	{regexp.MustCompile(`(?i)\bthis month\b`), "thismonth"},
+	{regexp.MustCompile(`(?i)\b(someday|eventually|one day)\b`), "someday"},
+}
+
+// Reference subcategory keywords, checked in order
+var subcategoryRules = []struct {
+	re          *regexp.Regexp
+	subcategory string
+}{
+	{regexp.MustCompile(`(?i)(youtube\.com|youtu\.be|vimeo\.com|netflix\.com|\bwatch\b|\bmovie\b|\bfilm\b|\bdocumentary\b)`), "watch"},
+	{regexp.MustCompile(`(?i)(spotify\.com|soundcloud\.com|bandcamp\.com|\bpodcast\b|\blisten\b|\balbum\b|\bsong\b)`), "listen"},
+	{regexp.MustCompile(`(?i)(\bread\b|\bbook\b|\barticle\b|\bessay\b|\bpaper\b|goodreads\.com)`), "read"},
+	{regexp.MustCompile(`(?i)\b(grateful|thankful|gratitude|thank you)\b`), "gratitude"},
+	{regexp.MustCompile(`(?i)^(idea\b|what if\b|maybe we could\b)`), "idea"},
+	{regexp.MustCompile(`(^["“].+["”]|\s[-—–]\s?[A-Z][a-z]+)`), "quote"},
+	{regexp.MustCompile(`(?i)\b(i noticed|i saw|today i)\b`), "observation"},
+	{regexp.MustCompile(`(?i)\b(i feel|i think|i realized|i learned)\b`), "reflection"},
+}
+
+// Classify matches text against the keyword lists. Plain statements with
+// no clues get a low confidence so they stay in the inbox.
+func (Rules) Classify(ctx context.Context, text string) (Result, error) {
+	text = strings.TrimSpace(text)
+
+	if urlOnly.MatchString(text) {
+		result := Result{Category: "reference", Subcategory: "bookmark", Confidence: 0.7}
+		if subcategory := matchSubcategory(text); subcategory == "watch" || subcategory == "listen" {
+			result.Subcategory = subcategory
+			result.Confidence = 0.9
+		}
+		return result, nil
+	}
+
+	timeframe := matchTimeframe(text)
+	if taskPhrases.MatchString(text) || isTaskVerb(text) {
+		return Result{Category: "task", Timeframe: timeframe, Confidence: 0.8}, nil
+	}
+
+	if subcategory := matchSubcategory(text); subcategory != "" {
+		return Result{Category: "reference", Subcategory: subcategory, Confidence: 0.7}, nil
+	}
+
+	if timeframe != "" {
+		return Result{Category: "task", Timeframe: timeframe, Confidence: 0.5}, nil
+	}
+
+	return Result{Category: "reference", Confidence: 0.4}, nil
+}
+
+func isTaskVerb(text string) bool {
+	first, _, _ := strings.Cut(strings.ToLower(text), " ")
+	first = strings.Trim(first, ".,:;!?")
+	for _, verb := range taskVerbs {
+		if first == verb {
+			return true
+		}
+	}
+	return false
+}
+
+func matchTimeframe(text string) string {
+	for _, rule := range timeframeRules {
+		if rule.re.MatchString(text) {
+			return rule.timeframe
+		}
+	}
+	return ""
+}
+
+func matchSubcategory(text string) string {
+	for _, rule := range subcategoryRules {
+		if rule.re.MatchString(text) {
+			return rule.subcategory
+		}
+	}
+	return ""
+}
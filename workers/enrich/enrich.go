@@ -1,55 +1,103 @@
 package enrich
 
 import (
+	"cmp"
+	"context"
 	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/alfarisi/urlmeta"
 	"github.com/rcy/evoke"
 	"github.com/rcy/whatever/commands"
 	"github.com/rcy/whatever/events"
+	"github.com/rcy/whatever/jobs"
 )
 
+const JobKind = "enrich"
+
 type worker struct {
 	cmdSender evoke.CommandSender
+	queue     *jobs.Queue
 }
 
-func NewWorker(cmdSender evoke.CommandSender) *worker {
-	return &worker{cmdSender: cmdSender}
+func NewWorker(cmdSender evoke.CommandSender, queue *jobs.Queue) *worker {
+	return &worker{cmdSender: cmdSender, queue: queue}
 }
 
-func (w worker) Handle(e evoke.Event, replay bool) error {
-	evt, ok := e.(events.NoteEnrichmentRequested)
+// Enqueue enqueues a job for the event in rec
+func (w worker) Enqueue(rec evoke.RecordedEvent) error {
+	evt, ok := rec.Event.(events.NoteEnrichmentRequested)
 	if !ok {
 		return fmt.Errorf("not a NoteEnrichmentRequested event")
 	}
 
-	go func() {
-		fmt.Println("enriching...", evt)
+	return w.queue.Enqueue(JobKind, rec.Sequence, evt)
+}
+
+// Work runs an enrich job enqueued by Enqueue. The note is only marked as
+// failed once the job has run out of retries.
+func (w worker) Work(ctx context.Context, job jobs.Job) error {
+	var evt events.NoteEnrichmentRequested
+	err := job.Unmarshal(&evt)
+	if err != nil {
+		return err
+	}
 
-		meta, err := urlmeta.Extract(evt.Text)
-		if err != nil {
-			w.cmdSender.MustSend(commands.FailNoteEnrichment{
+	// the requests are cancelled with ctx, so they cannot outlive the lease
+	client := urlmeta.NewClient(urlmeta.WithHTTPClient(&http.Client{
+		Timeout:   10 * time.Second,
+		Transport: contextTransport{ctx},
+	}))
+	// older requests have the whole note text, starting with the link
+	meta, err := client.Extract(cmp.Or(evt.URL, evt.Text))
+	if err != nil {
+		if job.LastAttempt() {
+			sendErr := w.cmdSender.Send(commands.FailNoteEnrichment{
 				NoteID:   evt.NoteID,
+				URL:      evt.URL,
 				FailedAt: time.Now(),
+				Reason:   err.Error(),
 			})
-			return
-		}
-
-		var thumb string
-		if meta.OEmbed != nil {
-			thumb = meta.OEmbed.ThumbnailURL
+			if sendErr != nil {
+				return sendErr
+			}
 		}
+		return err
+	}
 
-		w.cmdSender.MustSend(commands.CompleteNoteEnrichment{
-			NoteID:      evt.NoteID,
-			CompletedAt: time.Now(),
-			Title:       meta.Title,
-			Thumb:       thumb,
-		})
+	cmd := commands.CompleteNoteEnrichment{
+		NoteID:       evt.NoteID,
+		URL:          evt.URL,
+		CompletedAt:  time.Now(),
+		Title:        meta.Title,
+		Description:  meta.Description,
+		SiteName:     cmp.Or(meta.SiteName, meta.ProviderName),
+		CanonicalURL: cmp.Or(meta.CanonicalURL, meta.URL),
+		ContentType:  meta.Type,
+	}
+	if len(meta.Images) > 0 {
+		cmd.Thumb = meta.Images[0].URL
+	}
+	if meta.OEmbed != nil {
+		cmd.Thumb = cmp.Or(meta.OEmbed.ThumbnailURL, cmd.Thumb)
+		cmd.SiteName = cmp.Or(cmd.SiteName, meta.OEmbed.ProviderName)
+		cmd.ContentType = cmp.Or(cmd.ContentType, meta.OEmbed.Type)
+	}
 
-		fmt.Println("enriching...done", evt)
-	}()
+	err = w.cmdSender.Send(cmd)
+	if err != nil {
+		return err
+	}
 
 	return nil
 }
+
+// contextTransport sends requests with ctx, for clients that do not take one
+type contextTransport struct {
+	ctx context.Context
+}
+
+func (t contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return http.DefaultTransport.RoundTrip(req.WithContext(t.ctx))
+}